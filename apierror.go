@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes sent in API error responses. They are part of the API and don't
+// change once released, unlike the messages.
+const (
+	codeInvalidJSON      = "INVALID_JSON"       // The request body isn't valid JSON
+	codeInvalidRequest   = "INVALID_REQUEST"    // A parameter or field is missing or has a bad value
+	codeInvalidPageID    = "INVALID_PAGE_ID"    // The page parameter isn't a number
+	codeInvalidURL       = "INVALID_URL"        // A URL was rejected, or isn't one of the bookmarks
+	codeInvalidShortcut  = "INVALID_SHORTCUT"   // A shortcut doesn't match the shortcut pattern
+	codeInvalidSettings  = "INVALID_SETTINGS"   // Settings failed validation
+	codeInvalidFile      = "INVALID_FILE"       // An uploaded or imported file is missing, of the wrong type or malformed
+	codeChecksumMismatch = "CHECKSUM_MISMATCH"  // An imported backup doesn't match its checksums.txt
+	codePageNotFound     = "PAGE_NOT_FOUND"     // No page has the given ID
+	codeCategoryNotFound = "CATEGORY_NOT_FOUND" // The page has no category with the given ID
+	codeCategoryInUse    = "CATEGORY_IN_USE"    // A category to delete still has bookmarks
+	codeBookmarkNotFound = "BOOKMARK_NOT_FOUND" // The page has no such bookmark
+	codeNotFound         = "NOT_FOUND"          // Anything else that doesn't exist, such as a font or language
+	codeURLNotAllowed    = "URL_NOT_ALLOWED"    // The URL points at a host the server may not contact
+	codeConflict         = "CONFLICT"           // The data changed since the client read it (If-Match)
+	codeReadOnly         = "READ_ONLY"          // The server runs with READ_ONLY=true
+	codeTooLarge         = "TOO_LARGE"          // The upload exceeds its size limit
+	codeUpstreamError    = "UPSTREAM_ERROR"     // A remote server couldn't be reached or answered with an error
+	codeInternalError    = "INTERNAL_ERROR"     // Something failed on the server, see its log
+)
+
+// apiError is the body of an error response: {"error": {"code": ..., "message": ...}}
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSONError replies with an error response. The message is meant for people
+// and may change, clients should branch on the code.
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]apiError{"error": {Code: code, Message: message}})
+}
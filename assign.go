@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// assignCategory sets the category of the bookmarks whose URL is in urls to
+// categoryID, which must be one of categories. URLs match like duplicates do,
+// ignoring case and trailing slashes. The bookmarks are changed in place and the
+// changed ones returned.
+func assignCategory(categories []Category, bookmarks []Bookmark, categoryID string, urls []string) ([]Bookmark, error) {
+	found := false
+	for _, category := range categories {
+		found = found || category.ID == categoryID
+	}
+	if !found {
+		return nil, errCategoryNotFound
+	}
+
+	wanted := make(map[string]bool)
+	for _, url := range urls {
+		wanted[bookmarkURLKey(url)] = true
+	}
+
+	var changed []Bookmark
+	for i := range bookmarks {
+		if bookmarks[i].Category == categoryID || !wanted[bookmarkURLKey(bookmarks[i].URL)] {
+			continue
+		}
+		bookmarks[i].Category = categoryID
+		changed = append(changed, bookmarks[i])
+	}
+	return changed, nil
+}
+
+// AssignCategory moves the bookmarks of ?page= whose URL is in
+// {"urls": [...], "category": "..."} into that category, which must exist on the
+// page. URLs match like duplicates do, ignoring case and trailing slashes.
+// Returns the number of bookmarks that changed.
+func (h *Handlers) AssignCategory(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	var request struct {
+		URLs     []string `json:"urls"`
+		Category string   `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+	if len(request.URLs) == 0 || request.Category == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "urls and category are required")
+		return
+	}
+
+	changed, err := h.store.AssignCategory(pageID, request.Category, request.URLs)
+	switch {
+	case errors.Is(err, errPageNotFound):
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	case errors.Is(err, errCategoryNotFound):
+		writeJSONError(w, http.StatusBadRequest, codeCategoryNotFound, "Category not found on this page")
+		return
+	case err != nil:
+		log.Printf("AssignCategory: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving bookmarks")
+		return
+	}
+
+	if len(changed) > 0 {
+		entries := make([]auditEntry, 0, len(changed))
+		for _, bookmark := range changed {
+			entries = append(entries, auditEntry{Action: "update", Page: pageID, Name: bookmark.Name, URL: bookmark.URL})
+		}
+		h.events.Publish("bookmarks", pageID)
+		h.audit.Record(r, entries...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "changed": len(changed)})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileBatch writes several files so that either all of them are replaced or none
+// are. Every file is written to a temporary file next to it first, and the
+// temporary files are only renamed into place once all writes have succeeded.
+type fileBatch struct {
+	paths []string          // Destination paths, in the order they're renamed
+	temps map[string]string // Destination path -> temporary file
+}
+
+func newFileBatch() *fileBatch {
+	return &fileBatch{temps: make(map[string]string)}
+}
+
+// Write stages data to be written to path on Commit
+func (b *fileBatch) Write(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if previous, ok := b.temps[path]; ok {
+		os.Remove(previous)
+	} else {
+		b.paths = append(b.paths, path)
+	}
+	b.temps[path] = temp.Name()
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so a crash
+// partway leaves the previous content
+func writeFileAtomic(path string, data []byte) error {
+	batch := newFileBatch()
+	if err := batch.Write(path, data); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// Rollback discards the staged files
+func (b *fileBatch) Rollback() {
+	for _, temp := range b.temps {
+		os.Remove(temp)
+	}
+	b.paths, b.temps = nil, make(map[string]string)
+}
+
+// Commit moves the staged files into place in the order they were written. When a
+// rename fails, the files already replaced get their previous content back and
+// the ones that didn't exist before are removed.
+func (b *fileBatch) Commit() error {
+	defer b.Rollback()
+
+	previous := make(map[string][]byte)
+	for _, path := range b.paths {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			previous[path] = data
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("reading %s: %v", path, err)
+		}
+	}
+
+	for i, path := range b.paths {
+		if err := os.Rename(b.temps[path], path); err != nil {
+			err = fmt.Errorf("replacing %s: %v", path, err)
+			return errors.Join(err, restoreFiles(b.paths[:i], previous))
+		}
+		delete(b.temps, path)
+	}
+	return nil
+}
+
+// restoreFiles puts back the previous content of paths, removing those that
+// have none
+func restoreFiles(paths []string, previous map[string][]byte) error {
+	restore := newFileBatch()
+	var errs []error
+	for _, path := range paths {
+		data, existed := previous[path]
+		if !existed {
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, fmt.Errorf("removing %s: %v", path, err))
+			}
+			continue
+		}
+		if err := restore.Write(path, data); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %v", path, err))
+			continue
+		}
+		if err := os.Rename(restore.temps[path], path); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %v", path, err))
+			continue
+		}
+		delete(restore.temps, path)
+	}
+	restore.Rollback()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// auditMaxSize is the size at which audit.log is rotated to audit.log.1
+const auditMaxSize = 5 << 20 // 5MB
+
+// auditEntry is one line of the audit log
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"` // "add", "update", "delete", "delete-page", "visit", "import" or "reset"
+	Page   int       `json:"page,omitempty"`
+	Name   string    `json:"name,omitempty"`
+	URL    string    `json:"url,omitempty"`
+	User   string    `json:"user,omitempty"` // Set by an authenticating reverse proxy
+}
+
+// auditLog is an append-only JSON lines record of bookmark changes
+type auditLog struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func newAuditLog(path string) *auditLog {
+	return &auditLog{path: path}
+}
+
+// auditUser returns the user name an authenticating reverse proxy passed along
+func auditUser(r *http.Request) string {
+	for _, header := range []string{"Remote-User", "X-Forwarded-User", "X-Auth-Request-User"} {
+		if user := r.Header.Get(header); user != "" {
+			return user
+		}
+	}
+	return ""
+}
+
+// Record appends entries made by the request r
+func (a *auditLog) Record(r *http.Request, entries ...auditEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if info, err := os.Stat(a.path); err == nil && info.Size() >= auditMaxSize {
+		os.Rename(a.path, a.path+".1")
+	}
+
+	os.MkdirAll(filepath.Dir(a.path), 0755)
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Warning: could not write audit log: %v", err)
+		return
+	}
+	defer file.Close()
+
+	now := time.Now().UTC()
+	user := auditUser(r)
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		entry.Time = now
+		entry.User = user
+		encoder.Encode(entry)
+	}
+}
+
+// Recent returns up to limit entries, newest first, optionally only those of pageID
+func (a *auditLog) Recent(limit, pageID int) []auditEntry {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var entries []auditEntry
+	for _, path := range []string{a.path + ".1", a.path} {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry auditEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+				continue
+			}
+			if pageID == 0 || entry.Page == pageID {
+				entries = append(entries, entry)
+			}
+		}
+		file.Close()
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	recent := make([]auditEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		recent = append(recent, entries[i])
+	}
+	return recent
+}
+
+// bookmarkChanges lists the bookmarks added, changed and removed when a page's
+// bookmarks go from previous to current. Bookmarks are matched by name and URL,
+// so editing either one shows up as a delete and an add.
+func bookmarkChanges(pageID int, previous, current []Bookmark) []auditEntry {
+	key := func(bookmark Bookmark) string { return bookmark.Name + "\x00" + bookmark.URL }
+
+	remaining := make(map[string][]Bookmark)
+	for _, bookmark := range previous {
+		remaining[key(bookmark)] = append(remaining[key(bookmark)], bookmark)
+	}
+
+	var entries []auditEntry
+	for _, bookmark := range current {
+		k := key(bookmark)
+		entry := auditEntry{Page: pageID, Name: bookmark.Name, URL: bookmark.URL}
+		if matches := remaining[k]; len(matches) > 0 {
+			remaining[k] = matches[1:]
+			if reflect.DeepEqual(matches[0], bookmark) {
+				continue
+			}
+			entry.Action = "update"
+		} else {
+			entry.Action = "add"
+		}
+		entries = append(entries, entry)
+	}
+	for _, bookmark := range previous {
+		k := key(bookmark)
+		if len(remaining[k]) > 0 {
+			remaining[k] = remaining[k][1:]
+			entries = append(entries, auditEntry{Action: "delete", Page: pageID, Name: bookmark.Name, URL: bookmark.URL})
+		}
+	}
+	return entries
+}
+
+// Audit returns recent audit log entries, newest first. ?limit= caps the count
+// (1-1000, default 100) and ?page= keeps only the entries of one page.
+func (h *Handlers) Audit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		value, err := strconv.Atoi(limitStr)
+		if err != nil || value < 1 || value > 1000 {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid limit, must be between 1 and 1000")
+			return
+		}
+		limit = value
+	}
+
+	pageID := 0
+	if pageIDStr := r.URL.Query().Get("page"); pageIDStr != "" {
+		value, err := strconv.Atoi(pageIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		pageID = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.audit.Recent(limit, pageID))
+}
@@ -2,32 +2,97 @@ package main
 
 import (
 	"archive/zip"
-	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
-// validateBookmarkURL checks if the bookmark URL has a safe scheme (http or https)
-func validateBookmarkURL(bookmarkURL string) error {
+// blockedSchemes can run code in the dashboard's origin and are never allowed
+var blockedSchemes = map[string]bool{"javascript": true, "data": true, "vbscript": true}
+
+// allowedSchemes returns the extra schemes listed in the ALLOWED_SCHEMES environment variable
+func allowedSchemes() map[string]bool {
+	schemes := make(map[string]bool)
+	for _, scheme := range strings.Split(os.Getenv("ALLOWED_SCHEMES"), ",") {
+		scheme = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(scheme), ":"))
+		if scheme != "" {
+			schemes[scheme] = true
+		}
+	}
+	return schemes
+}
+
+// backupSchemaVersion is the version of the data file layout written to backup
+// manifests. Bump it when the data model changes incompatibly.
+const backupSchemaVersion = 1
+
+// backupManifest describes a backup archive, stored in it as manifest.json
+type backupManifest struct {
+	Version       string `json:"version"`       // App version that created the backup
+	SchemaVersion int    `json:"schemaVersion"` // Data layout version, see backupSchemaVersion
+	ExportedAt    string `json:"exportedAt"`    // RFC 3339 timestamp
+	PageCount     int    `json:"pageCount"`     // Number of page files in the backup
+}
+
+// validateBookmarkURL checks that the bookmark URL is well formed and has a safe scheme.
+// Only http and https are accepted unless allowCustomSchemes is set or the scheme is
+// listed in ALLOWED_SCHEMES; when ALLOWED_SCHEMES is set it also limits which custom
+// schemes allowCustomSchemes accepts.
+func validateBookmarkURL(bookmarkURL string, allowCustomSchemes bool) error {
 	if bookmarkURL == "" {
 		return nil // Allow empty URLs
 	}
 
+	if strings.ContainsFunc(bookmarkURL, unicode.IsSpace) {
+		return fmt.Errorf("URL must not contain spaces")
+	}
+	if strings.ContainsFunc(bookmarkURL, unicode.IsControl) {
+		return fmt.Errorf("URL must not contain control characters")
+	}
+
 	parsedURL, err := url.Parse(bookmarkURL)
 	if err != nil {
 		return fmt.Errorf("invalid URL format")
 	}
 
-	// Only allow http and https schemes
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return fmt.Errorf("URL scheme '%s' is not allowed. Only http and https are permitted", parsedURL.Scheme)
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if scheme != "http" && scheme != "https" {
+		if scheme == "" {
+			return fmt.Errorf("URL must include a scheme")
+		}
+		if blockedSchemes[scheme] {
+			return fmt.Errorf("URL scheme '%s' is not allowed", scheme)
+		}
+
+		extra := allowedSchemes()
+		if !extra[scheme] && (!allowCustomSchemes || len(extra) > 0) {
+			return fmt.Errorf("URL scheme '%s' is not allowed. Only http and https are permitted", scheme)
+		}
+
+		// Custom schemes (file://, steam://, obsidian://...) don't need a host
+		return nil
+	}
+
+	if parsedURL.Hostname() == "" {
+		return fmt.Errorf("URL must include a host")
+	}
+
+	// Embedded credentials are allowed but end up in plain text in the data files
+	if parsedURL.User != nil {
+		log.Printf("Warning: bookmark URL %s contains embedded credentials", parsedURL.Redacted())
 	}
 
 	return nil
@@ -42,17 +107,20 @@ func (h *Handlers) isValidImportFilename(filename string) bool {
 	if strings.Contains(filename, "\\") {
 		return false
 	}
-	// Allow / only in icons/ prefix
-	if strings.Contains(filename, "/") && !strings.HasPrefix(filename, "icons/") {
+	// Allow / only in icons/ and fonts/ prefixes
+	if strings.Contains(filename, "/") && !strings.HasPrefix(filename, "icons/") && !strings.HasPrefix(filename, "fonts/") {
 		return false
 	}
 
 	// Allow only specific filenames with their extensions
 	allowedFiles := []string{
+		"manifest.json",
+		checksumsFile,
 		"settings.json",
 		"colors.json",
 		"pages.json",
 		"finders.json",
+		"fonts.json",
 		"favicon.ico",
 		"favicon.png",
 		"favicon.jpg",
@@ -71,12 +139,8 @@ func (h *Handlers) isValidImportFilename(filename string) bool {
 	}
 
 	// Check if it's a bookmarks file (bookmarks- followed by digits and .json)
-	if strings.HasPrefix(filename, "bookmarks-") && strings.HasSuffix(filename, ".json") {
-		// Extract the number part
-		numberPart := strings.TrimPrefix(strings.TrimSuffix(filename, ".json"), "bookmarks-")
-		if _, err := strconv.Atoi(numberPart); err == nil {
-			return true
-		}
+	if _, ok := bookmarksFilePageID(filename); ok {
+		return true
 	}
 
 	// Check if it's an image file in root data directory
@@ -100,23 +164,102 @@ func (h *Handlers) isValidImportFilename(filename string) bool {
 		}
 	}
 
+	// Check if it's an uploaded font (fonts/ followed by a font file)
+	if strings.HasPrefix(filename, "fonts/") && strings.Count(filename, "/") == 1 {
+		if _, ok := fontFormats[strings.ToLower(filepath.Ext(filename))]; ok {
+			return true
+		}
+	}
+
 	return false
 }
 
 // Import handles the import of backup files
 func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
+	// "overwrite" (default) replaces existing files, "merge" combines bookmark files with the existing pages
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "overwrite"
+	}
+	if mode != "overwrite" && mode != "merge" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid import mode")
+		return
+	}
+
 	// Parse multipart form
 	err := r.ParseMultipartForm(32 << 20) // 32MB max
 	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Failed to parse form")
 		return
 	}
 
 	files := r.MultipartForm.File["files"]
 	if len(files) == 0 {
-		http.Error(w, "No files provided", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "No files provided")
+		return
+	}
+
+	// Page files imported on their own (without pages.json, so not a full backup)
+	// that collide with an existing page are added as a new page instead of
+	// replacing it, unless remap=false
+	remap := r.URL.Query().Get("remap") != "false"
+	for _, fileHeader := range files {
+		if strings.ReplaceAll(fileHeader.Filename, "\\", "/") == "pages.json" {
+			remap = false
+		}
+	}
+
+	// Check the files against the checksums of the backup before anything is
+	// written, unless verify=false (e.g. for backups edited by hand)
+	if r.URL.Query().Get("verify") != "false" {
+		if err := verifyImportChecksums(files); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeChecksumMismatch, fmt.Sprintf("Backup failed verification: %v", err))
+			return
+		}
+	}
+
+	// Check the page files before anything is written, so a malformed or
+	// oversized one doesn't leave a half-done import. Shortcuts are checked
+	// against the settings in the backup when it has them.
+	settings, err := importedSettings(files, h.settingsFor(r))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid settings.json")
+		return
+	}
+	if err := validateFontPath(settings.CustomFontPath); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid settings.json: %v", err))
+		return
+	}
+	if err := validateImportedFonts(files); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid fonts.json: %v", err))
 		return
 	}
+	for _, fileHeader := range files {
+		filename := strings.ReplaceAll(fileHeader.Filename, "\\", "/")
+		if _, ok := bookmarksFilePageID(filename); !ok {
+			continue
+		}
+		content, err := readFileHeader(fileHeader)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to read file")
+			return
+		}
+		incoming, err := decodeImportedPage(content, h.importLimit)
+		if err != nil {
+			if errors.Is(err, errTooManyBookmarks) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("%s has more than %d bookmarks", filename, h.importLimit))
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid page file %s: %v", filename, err))
+			return
+		}
+		if err := validateShortcuts(incoming.Bookmarks, settings); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidShortcut, fmt.Sprintf("Invalid page file %s: %v", filename, err))
+			return
+		}
+	}
+
+	var warnings, notes []string
 
 	// Process each file
 	for _, fileHeader := range files {
@@ -130,13 +273,13 @@ func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
 		// Validate filename to prevent path traversal and ensure only allowed files
 		if !h.isValidImportFilename(filename) {
 			fmt.Printf("Invalid filename: %s\n", filename)
-			http.Error(w, fmt.Sprintf("Invalid filename: %s", filename), http.StatusBadRequest)
+			writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid filename: %s", filename))
 			return
 		}
 
 		file, err := fileHeader.Open()
 		if err != nil {
-			http.Error(w, "Failed to open file", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to open file")
 			return
 		}
 		defer file.Close()
@@ -144,7 +287,7 @@ func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
 		// Read file content
 		content, err := io.ReadAll(file)
 		if err != nil {
-			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to read file")
 			return
 		}
 
@@ -152,9 +295,62 @@ func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(filename, ".json") {
 			if !json.Valid(content) {
 				fmt.Printf("Invalid JSON in file: %s\n", filename)
-				http.Error(w, fmt.Sprintf("Invalid JSON content in file: %s", filename), http.StatusBadRequest)
+				writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid JSON content in file: %s", filename))
+				return
+			}
+		}
+
+		if filename == checksumsFile {
+			continue
+		}
+
+		// The manifest only describes the backup and isn't stored
+		if filename == "manifest.json" {
+			var manifest backupManifest
+			if err := json.Unmarshal(content, &manifest); err == nil && manifest.SchemaVersion > backupSchemaVersion {
+				warning := fmt.Sprintf("backup was created by version %s with data schema %d, newer than the supported schema %d",
+					manifest.Version, manifest.SchemaVersion, backupSchemaVersion)
+				log.Printf("Import warning: %s", warning)
+				warnings = append(warnings, warning)
+			}
+			continue
+		}
+
+		// Merge bookmark files into the existing page instead of replacing it
+		if mode == "merge" {
+			if pageID, ok := bookmarksFilePageID(filename); ok {
+				if err := h.mergeImportedPage(pageID, content); err != nil {
+					if errors.Is(err, errTooManyBookmarks) {
+						writeJSONError(w, http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("Merging %s would leave page %d with more than %d bookmarks", filename, pageID, h.importLimit))
+						return
+					}
+					writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Failed to merge file: %s", filename))
+					return
+				}
+				continue
+			}
+		}
+
+		if mode == "overwrite" && remap {
+			if pageID, ok := bookmarksFilePageID(filename); ok && h.store.PageExists(pageID) {
+				newID, err := h.importPageAsNew(content)
+				if err != nil {
+					writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Failed to import file: %s", filename))
+					return
+				}
+				notes = append(notes, fmt.Sprintf("Page %d was imported as page %d", pageID, newID))
+				continue
+			}
+		}
+
+		// Pages, settings, colors and finders go through the store so they reach
+		// the SQLite database too; only uploaded files are written to data/
+		if handled, err := h.importStoreFile(filename, content); handled {
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Failed to import file: %s", filename))
 				return
 			}
+			continue
 		}
 
 		// Determine destination path
@@ -184,33 +380,364 @@ func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
 		dir := filepath.Dir(destPath)
 		err = os.MkdirAll(dir, 0755)
 		if err != nil {
-			http.Error(w, "Failed to create directory", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to create directory")
 			return
 		}
 
 		// Write file
 		err = os.WriteFile(destPath, content, 0644)
 		if err != nil {
-			http.Error(w, "Failed to write file", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to write file")
 			return
 		}
 	}
 
+	h.events.Publish("import", 0)
+	h.audit.Record(r, auditEntry{Action: "import"})
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Import successful"))
+	for _, note := range notes {
+		w.Write([]byte("\n" + note))
+	}
+	for _, warning := range warnings {
+		w.Write([]byte("\nWarning: " + warning))
+	}
+}
+
+// importedSettings returns the settings from the settings.json among files, or
+// current when the import doesn't include one
+func importedSettings(files []*multipart.FileHeader, current Settings) (Settings, error) {
+	for _, fileHeader := range files {
+		if strings.ReplaceAll(fileHeader.Filename, "\\", "/") != "settings.json" {
+			continue
+		}
+		content, err := readFileHeader(fileHeader)
+		if err != nil {
+			return Settings{}, err
+		}
+		settings := getDefaultSettings()
+		if err := json.Unmarshal(content, &settings); err != nil {
+			return Settings{}, err
+		}
+		return settings, nil
+	}
+	return current, nil
+}
+
+// validateImportedFonts checks the entries of the fonts.json among files, which is
+// written to the data directory as is
+func validateImportedFonts(files []*multipart.FileHeader) error {
+	for _, fileHeader := range files {
+		if strings.ReplaceAll(fileHeader.Filename, "\\", "/") != "fonts.json" {
+			continue
+		}
+		content, err := readFileHeader(fileHeader)
+		if err != nil {
+			return err
+		}
+		var fonts []customFont
+		if err := json.Unmarshal(content, &fonts); err != nil {
+			return err
+		}
+		for _, font := range fonts {
+			if err := validateFont(font); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-// Backup creates a zip file with all data from the data directory
+// bookmarksFilePageID returns the page ID of a bookmarks-N.json filename
+func bookmarksFilePageID(filename string) (int, bool) {
+	if !strings.HasPrefix(filename, "bookmarks-") || !strings.HasSuffix(filename, ".json") {
+		return 0, false
+	}
+	pageID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filename, "bookmarks-"), ".json"))
+	if err != nil {
+		return 0, false
+	}
+	return pageID, true
+}
+
+// savePageWithCategories saves page with its categories and bookmarks. Categories
+// go first so SavePage keeps them instead of the defaults; nil keeps the defaults.
+func savePageWithCategories(store Store, page Page, categories []Category, bookmarks []Bookmark) {
+	if categories != nil {
+		store.SaveCategoriesByPage(page.ID, categories)
+	}
+	store.SavePage(page, bookmarks)
+}
+
+// importStoreFile saves an imported pages.json, settings.json, colors.json,
+// finders.json or bookmarks-N.json through the store, reporting false for any
+// other file
+func (h *Handlers) importStoreFile(filename string, content []byte) (bool, error) {
+	if pageID, ok := bookmarksFilePageID(filename); ok {
+		incoming, err := decodeImportedPage(content, h.importLimit)
+		if err != nil {
+			return true, err
+		}
+		page := incoming.Page
+		page.ID = pageID
+		if page.Name == "" {
+			page.Name = fmt.Sprintf("Page %d", pageID)
+		}
+		savePageWithCategories(h.store, page, incoming.Categories, incoming.Bookmarks)
+		if order := h.store.GetPageOrder(); !slices.Contains(order, pageID) {
+			h.store.SavePageOrder(append(order, pageID))
+		}
+		return true, nil
+	}
+
+	switch filename {
+	case "pages.json":
+		var pageOrder PageOrder
+		if err := json.Unmarshal(content, &pageOrder); err != nil {
+			return true, err
+		}
+		h.store.SavePageOrder(pageOrder.Order)
+	case "settings.json":
+		settings := getDefaultSettings()
+		if err := json.Unmarshal(content, &settings); err != nil {
+			return true, err
+		}
+		h.store.SaveSettings(settings)
+	case "colors.json":
+		colors := getDefaultColors()
+		if err := json.Unmarshal(content, &colors); err != nil {
+			return true, err
+		}
+		if colors.Custom == nil {
+			colors.Custom = make(map[string]ThemeColors)
+		}
+		h.store.SaveColors(colors)
+	case "finders.json":
+		var finders []Finder
+		if err := json.Unmarshal(content, &finders); err != nil {
+			return true, err
+		}
+		h.store.SaveFinders(finders)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// importPageAsNew adds an imported page file as a page with the next free ID at
+// the end of the page order, returning the new ID
+func (h *Handlers) importPageAsNew(content []byte) (int, error) {
+	var incoming PageWithBookmarks
+	if err := json.Unmarshal(content, &incoming); err != nil {
+		return 0, err
+	}
+
+	newID := 1
+	for _, page := range h.store.GetPages() {
+		newID = max(newID, page.ID+1)
+	}
+
+	page := incoming.Page
+	page.ID = newID
+	if page.Name == "" {
+		page.Name = fmt.Sprintf("Page %d", newID)
+	}
+	savePageWithCategories(h.store, page, incoming.Categories, incoming.Bookmarks)
+	h.store.SavePageOrder(append(h.store.GetPageOrder(), newID))
+	return newID, nil
+}
+
+// mergeImportedPage adds the categories and bookmarks of an imported page file to
+// the existing page. Categories are matched by ID and bookmarks by URL and shortcut,
+// so importing the same file twice doesn't create duplicates.
+func (h *Handlers) mergeImportedPage(pageID int, content []byte) error {
+	var incoming PageWithBookmarks
+	if err := json.Unmarshal(content, &incoming); err != nil {
+		return err
+	}
+
+	existing, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		// Nothing to merge with, import the page as is
+		page := incoming.Page
+		page.ID = pageID
+		if page.Name == "" {
+			page.Name = fmt.Sprintf("Page %d", pageID)
+		}
+		if len(incoming.Categories) > 0 {
+			h.store.SaveCategoriesByPage(pageID, incoming.Categories)
+		}
+		h.store.SavePage(page, incoming.Bookmarks)
+		return nil
+	}
+
+	categories := existing.Categories
+	knownCategories := make(map[string]bool)
+	for _, category := range categories {
+		knownCategories[category.ID] = true
+	}
+	for _, category := range incoming.Categories {
+		if !knownCategories[category.ID] {
+			knownCategories[category.ID] = true
+			categories = append(categories, category)
+		}
+	}
+
+	bookmarks := existing.Bookmarks
+	knownBookmarks := make(map[string]bool)
+	for _, bookmark := range bookmarks {
+		knownBookmarks[bookmarkURLKey(bookmark.URL)+"\x00"+bookmark.Shortcut] = true
+	}
+	for _, bookmark := range incoming.Bookmarks {
+		key := bookmarkURLKey(bookmark.URL) + "\x00" + bookmark.Shortcut
+		if !knownBookmarks[key] {
+			knownBookmarks[key] = true
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	if len(bookmarks) > h.importLimit {
+		return errTooManyBookmarks
+	}
+
+	savePageWithCategories(h.store, existing.Page, categories, bookmarks)
+	return nil
+}
+
+// backupKinds are the values accepted by the include parameter of Backup
+var backupKinds = []string{"bookmarks", "pages", "finders", "settings", "colors", "icons", "uploads"}
+
+// backupKind classifies a file in the data directory for selective backups
+func backupKind(relPath string) string {
+	relPath = filepath.ToSlash(relPath)
+	switch {
+	case strings.HasPrefix(relPath, "icons/"):
+		return "icons"
+	case relPath == "pages.json":
+		return "pages"
+	case relPath == "finders.json":
+		return "finders"
+	case relPath == "settings.json":
+		return "settings"
+	case relPath == "colors.json":
+		return "colors"
+	}
+	if _, ok := bookmarksFilePageID(relPath); ok {
+		return "bookmarks"
+	}
+	return "uploads"
+}
+
+// backupFilter builds the predicate selecting which data files go into a backup from
+// the optional pages (comma-separated page IDs) and include (comma-separated kinds)
+// query parameters. Without parameters every file is included.
+func (h *Handlers) backupFilter(query url.Values) (func(relPath string) bool, error) {
+	var includeKinds map[string]bool
+	if include := query.Get("include"); include != "" {
+		includeKinds = make(map[string]bool)
+		for _, kind := range strings.Split(include, ",") {
+			kind = strings.TrimSpace(kind)
+			valid := false
+			for _, known := range backupKinds {
+				if kind == known {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, fmt.Errorf("Unknown backup content: %s", kind)
+			}
+			includeKinds[kind] = true
+		}
+	}
+
+	var includePages map[int]bool
+	if pages := query.Get("pages"); pages != "" {
+		existingPages := make(map[int]bool)
+		for _, page := range h.store.GetPages() {
+			existingPages[page.ID] = true
+		}
+
+		includePages = make(map[int]bool)
+		for _, idStr := range strings.Split(pages, ",") {
+			pageID, err := strconv.Atoi(strings.TrimSpace(idStr))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid page ID: %s", idStr)
+			}
+			if !existingPages[pageID] {
+				return nil, fmt.Errorf("Page %d not found", pageID)
+			}
+			includePages[pageID] = true
+		}
+	}
+
+	return func(relPath string) bool {
+		kind := backupKind(relPath)
+		if includeKinds != nil && !includeKinds[kind] {
+			return false
+		}
+		if includePages != nil && kind == "bookmarks" {
+			pageID, _ := bookmarksFilePageID(filepath.ToSlash(relPath))
+			return includePages[pageID]
+		}
+		return true
+	}, nil
+}
+
+// Backup streams a zip file with the pages, settings, colors and finders of the
+// store and the uploaded files in the data directory, optionally limited to some
+// pages or kinds of data (see backupFilter)
 func (h *Handlers) Backup(w http.ResponseWriter, r *http.Request) {
-	// Create a buffer to write our archive to
-	buf := new(bytes.Buffer)
+	include, err := h.backupFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
 
-	// Create a new zip archive
-	zipWriter := zip.NewWriter(buf)
+	// The zip is written straight to the response, so the headers go out first
+	// and the size isn't known up front
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=thinkdashboard-backup.zip")
 
-	// Walk through the data directory
-	dataDir := "data"
-	err := filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+	// Part of the zip may already be sent, so the status can't change anymore.
+	// Aborting drops the connection, which the client sees as a failed download
+	// instead of a truncated zip.
+	if err := h.writeBackup(w, include); err != nil {
+		log.Printf("Backup failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// writeBackup writes a backup zip to out. The data of the store is exported to a
+// temporary directory in the JSON file layout first, so the backup is the same
+// with either storage backend; uploaded files come from the data directory.
+func (h *Handlers) writeBackup(out io.Writer, include func(relPath string) bool) error {
+	exportDir, err := os.MkdirTemp("", "thinkdashboard-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(exportDir)
+	copyStoreData(h.store, newFileStore(exportDir))
+
+	zipWriter := zip.NewWriter(out)
+	checksums := make(backupChecksums)
+	pageCount, err := h.addBackupFiles(zipWriter, checksums, exportDir, include)
+	if err == nil {
+		_, err = h.addBackupFiles(zipWriter, checksums, "data", func(relPath string) bool {
+			kind := backupKind(relPath)
+			return (kind == "icons" || kind == "uploads") && include(relPath)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return finishBackup(zipWriter, checksums, pageCount)
+}
+
+// addBackupFiles adds the importable files under dir for which include is true to
+// a backup zip, recording their checksums, and returns the number of page files
+func (h *Handlers) addBackupFiles(zipWriter *zip.Writer, checksums backupChecksums, dir string, include func(relPath string) bool) (int, error) {
+	pageCount := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -221,10 +748,18 @@ func (h *Handlers) Backup(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Create a relative path for the zip entry
-		relPath, err := filepath.Rel(dataDir, path)
+		relPath, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
+		// Only back up files that can be imported again, which leaves out
+		// things like preserved corrupt files and the SQLite database
+		if !h.isValidImportFilename(filepath.ToSlash(relPath)) || !include(relPath) {
+			return nil
+		}
+		if backupKind(relPath) == "bookmarks" {
+			pageCount++
+		}
 
 		// Create zip file entry
 		zipFile, err := zipWriter.Create(relPath)
@@ -240,27 +775,42 @@ func (h *Handlers) Backup(w http.ResponseWriter, r *http.Request) {
 		defer file.Close()
 
 		// Copy file content to zip
-		_, err = io.Copy(zipFile, file)
-		return err
+		hash := sha256.New()
+		if _, err = io.Copy(io.MultiWriter(zipFile, hash), file); err != nil {
+			return err
+		}
+		checksums[filepath.ToSlash(relPath)] = hash.Sum(nil)
+		return nil
 	})
+	return pageCount, err
+}
 
+// finishBackup adds the manifest and checksums.txt to a backup zip and closes it
+func finishBackup(zipWriter *zip.Writer, checksums backupChecksums, pageCount int) error {
+	// Describe the backup so imports can detect incompatible data
+	manifest, _ := json.MarshalIndent(backupManifest{
+		Version:       version,
+		SchemaVersion: backupSchemaVersion,
+		ExportedAt:    time.Now().UTC().Format(time.RFC3339),
+		PageCount:     pageCount,
+	}, "", "  ")
+	manifestFile, err := zipWriter.Create("manifest.json")
 	if err != nil {
-		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
-		return
+		return err
+	}
+	if _, err := manifestFile.Write(manifest); err != nil {
+		return err
 	}
+	sum := sha256.Sum256(manifest)
+	checksums["manifest.json"] = sum[:]
 
-	// Close the zip writer
-	err = zipWriter.Close()
+	// Written last, so it covers every other file
+	checksumsWriter, err := zipWriter.Create(checksumsFile)
 	if err != nil {
-		http.Error(w, "Failed to finalize backup", http.StatusInternalServerError)
-		return
+		return err
 	}
-
-	// Set headers for file download
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", "attachment; filename=thinkdashboard-backup.zip")
-	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
-
-	// Write the zip content to response
-	w.Write(buf.Bytes())
+	if _, err := checksumsWriter.Write(checksums.Bytes()); err != nil {
+		return err
+	}
+	return zipWriter.Close()
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// cleanBasePath normalizes a sub-path such as "dashboard/" to "/dashboard". "/" and
+// "" give "". Only plain path segments are accepted, since the result ends up in
+// HTML and in generated URLs.
+func cleanBasePath(value string) (string, bool) {
+	value = strings.Trim(strings.TrimSpace(value), "/")
+	if value == "" {
+		return "", true
+	}
+	for _, segment := range strings.Split(value, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", false
+		}
+		for _, c := range segment {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+				return "", false
+			}
+		}
+	}
+	return "/" + value, true
+}
+
+// loadBasePath reads BASE_PATH, the sub-path the dashboard is served under behind
+// a reverse proxy that passes the full path through
+func loadBasePath() string {
+	value := os.Getenv("BASE_PATH")
+	basePath, ok := cleanBasePath(value)
+	if !ok {
+		log.Printf("Warning: invalid BASE_PATH %q, serving from /", value)
+	}
+	return basePath
+}
+
+// withBasePath serves next under basePath, with the prefix removed from the
+// request path. The bare prefix redirects to the prefix with a trailing slash so
+// relative links resolve inside it. /health stays at the root for container
+// health checks.
+func withBasePath(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	stripped := http.StripPrefix(basePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == basePath:
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		case r.URL.Path == "/health":
+			next.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// basePathFor returns the prefix for links generated by the server: BASE_PATH, or
+// else the X-Forwarded-Prefix of a proxy that removes the prefix itself
+func (h *Handlers) basePathFor(r *http.Request) string {
+	if h.basePath != "" {
+		return h.basePath
+	}
+	basePath, ok := cleanBasePath(r.Header.Get("X-Forwarded-Prefix"))
+	if !ok {
+		return ""
+	}
+	return basePath
+}
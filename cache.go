@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cachingStore wraps a FileStore and memoizes parsed reads. Each entry is stamped
+// with the modification time and size of the files it was read from, so manual
+// edits to the data files are picked up on the next read. Writes through the
+// store clear the cache.
+type cachingStore struct {
+	*FileStore
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	stamp string
+	value interface{}
+}
+
+func newCachingStore(fs *FileStore) *cachingStore {
+	return &cachingStore{
+		FileStore: fs,
+		entries:   make(map[string]cacheEntry),
+	}
+}
+
+// fileStamp identifies the current version of the given files, or returns false
+// when any of them can't be read
+func fileStamp(paths ...string) (string, bool) {
+	var stamp strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(&stamp, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp.String(), true
+}
+
+// cachedRead returns the cached value for key while stamp is unchanged, otherwise
+// it calls read and caches the result. Values are cloned so callers can modify them.
+func cachedRead[T any](c *cachingStore, key, stamp string, read func() T, clone func(T) T) T {
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	c.mutex.Unlock()
+	if ok && entry.stamp == stamp {
+		return clone(entry.value.(T))
+	}
+
+	value := read()
+
+	c.mutex.Lock()
+	c.entries[key] = cacheEntry{stamp: stamp, value: value}
+	c.mutex.Unlock()
+
+	return clone(value)
+}
+
+func (c *cachingStore) invalidate() {
+	c.mutex.Lock()
+	c.entries = make(map[string]cacheEntry)
+	c.mutex.Unlock()
+}
+
+// cloneSlice copies s, keeping nil and empty slices distinct
+func cloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+func (c *cachingStore) pageFile(pageID int) string {
+	return fmt.Sprintf("%s/bookmarks-%d.json", c.dataDir, pageID)
+}
+
+func (c *cachingStore) GetBookmarksByPage(pageID int) []Bookmark {
+	stamp, ok := fileStamp(c.pageFile(pageID))
+	if !ok {
+		return c.FileStore.GetBookmarksByPage(pageID)
+	}
+	return cachedRead(c, fmt.Sprintf("bookmarks:%d", pageID), stamp, func() []Bookmark {
+		return c.FileStore.GetBookmarksByPage(pageID)
+	}, cloneSlice[Bookmark])
+}
+
+func (c *cachingStore) GetCategoriesByPage(pageID int) []Category {
+	stamp, ok := fileStamp(c.pageFile(pageID))
+	if !ok {
+		return c.FileStore.GetCategoriesByPage(pageID)
+	}
+	return cachedRead(c, fmt.Sprintf("categories:%d", pageID), stamp, func() []Category {
+		return c.FileStore.GetCategoriesByPage(pageID)
+	}, cloneSlice[Category])
+}
+
+func (c *cachingStore) GetPageWithBookmarks(pageID int) (PageWithBookmarks, error) {
+	stamp, ok := fileStamp(c.pageFile(pageID))
+	if !ok {
+		return c.FileStore.GetPageWithBookmarks(pageID)
+	}
+
+	// Failed reads aren't cached
+	var readErr error
+	key := fmt.Sprintf("page:%d", pageID)
+	pageWithBookmarks := cachedRead(c, key, stamp, func() PageWithBookmarks {
+		var p PageWithBookmarks
+		p, readErr = c.FileStore.GetPageWithBookmarks(pageID)
+		return p
+	}, func(p PageWithBookmarks) PageWithBookmarks {
+		p.Categories = cloneSlice(p.Categories)
+		p.Bookmarks = cloneSlice(p.Bookmarks)
+		return p
+	})
+	if readErr != nil {
+		c.mutex.Lock()
+		delete(c.entries, key)
+		c.mutex.Unlock()
+		return PageWithBookmarks{}, readErr
+	}
+	return pageWithBookmarks, nil
+}
+
+func (c *cachingStore) GetPages() []Page {
+	// Pages depend on every page file and the page order
+	files, _ := filepath.Glob(filepath.Join(c.dataDir, "bookmarks-*.json"))
+	stamp, ok := fileStamp(append(files, c.pageOrderFile)...)
+	if !ok {
+		return c.FileStore.GetPages()
+	}
+	return cachedRead(c, "pages", stamp, c.FileStore.GetPages, cloneSlice[Page])
+}
+
+func (c *cachingStore) GetPagesWithCounts() []PageSummary {
+	files, _ := filepath.Glob(filepath.Join(c.dataDir, "bookmarks-*.json"))
+	stamp, ok := fileStamp(append(files, c.pageOrderFile)...)
+	if !ok {
+		return c.FileStore.GetPagesWithCounts()
+	}
+	return cachedRead(c, "pageCounts", stamp, c.FileStore.GetPagesWithCounts, cloneSlice[PageSummary])
+}
+
+func (c *cachingStore) GetPageOrder() []int {
+	stamp, ok := fileStamp(c.pageOrderFile)
+	if !ok {
+		return c.FileStore.GetPageOrder()
+	}
+	return cachedRead(c, "order", stamp, c.FileStore.GetPageOrder, cloneSlice[int])
+}
+
+func (c *cachingStore) GetAllBookmarks() []Bookmark {
+	var allBookmarks []Bookmark
+	for _, page := range c.GetPages() {
+		allBookmarks = append(allBookmarks, c.GetBookmarksByPage(page.ID)...)
+	}
+	return allBookmarks
+}
+
+func (c *cachingStore) GetBookmarksRange(offset, limit int) ([]Bookmark, int) {
+	items := []Bookmark{}
+	total := 0
+	for _, page := range c.GetPages() {
+		for _, bookmark := range c.GetBookmarksByPage(page.ID) {
+			if total >= offset && len(items) < limit {
+				items = append(items, bookmark)
+			}
+			total++
+		}
+	}
+	return items, total
+}
+
+func (c *cachingStore) GetFinders() []Finder {
+	stamp, ok := fileStamp(filepath.Join(c.dataDir, "finders.json"))
+	if !ok {
+		return c.FileStore.GetFinders()
+	}
+	return cachedRead(c, "finders", stamp, c.FileStore.GetFinders, cloneSlice[Finder])
+}
+
+func (c *cachingStore) GetSettings() Settings {
+	stamp, ok := fileStamp(c.settingsFile)
+	if !ok {
+		return c.FileStore.GetSettings()
+	}
+	return cachedRead(c, "settings", stamp, c.FileStore.GetSettings, func(s Settings) Settings { return s })
+}
+
+func (c *cachingStore) GetColors() ColorTheme {
+	stamp, ok := fileStamp(c.colorsFile)
+	if !ok {
+		return c.FileStore.GetColors()
+	}
+	return cachedRead(c, "colors", stamp, c.FileStore.GetColors, func(colors ColorTheme) ColorTheme {
+		custom := make(map[string]ThemeColors, len(colors.Custom))
+		for name, theme := range colors.Custom {
+			custom[name] = theme
+		}
+		colors.Custom = custom
+		colors.Order = append([]string(nil), colors.Order...)
+		return colors
+	})
+}
+
+// Writes go to the file store and clear the cache, which covers changes made
+// within the file system's timestamp resolution
+
+func (c *cachingStore) SaveBookmarksByPage(pageID int, bookmarks []Bookmark) {
+	defer c.invalidate()
+	c.FileStore.SaveBookmarksByPage(pageID, bookmarks)
+}
+
+func (c *cachingStore) AddBookmarkToPage(pageID int, bookmark Bookmark) {
+	defer c.invalidate()
+	c.FileStore.AddBookmarkToPage(pageID, bookmark)
+}
+
+func (c *cachingStore) DeleteBookmarkFromPage(pageID int, bookmark Bookmark) error {
+	defer c.invalidate()
+	return c.FileStore.DeleteBookmarkFromPage(pageID, bookmark)
+}
+
+func (c *cachingStore) SaveCategoriesByPage(pageID int, categories []Category) {
+	defer c.invalidate()
+	c.FileStore.SaveCategoriesByPage(pageID, categories)
+}
+
+func (c *cachingStore) SetCategoryCollapsed(pageID int, categoryID string, collapsed bool) error {
+	defer c.invalidate()
+	return c.FileStore.SetCategoryCollapsed(pageID, categoryID, collapsed)
+}
+
+func (c *cachingStore) ReorderCategories(pageID int, ids []string) error {
+	defer c.invalidate()
+	return c.FileStore.ReorderCategories(pageID, ids)
+}
+
+func (c *cachingStore) SaveBookmarksIfVersion(pageID int, bookmarks []Bookmark, matches func(Page) bool) ([]Bookmark, error) {
+	defer c.invalidate()
+	return c.FileStore.SaveBookmarksIfVersion(pageID, bookmarks, matches)
+}
+
+func (c *cachingStore) MergePages(sourceID, targetID int) (PageWithBookmarks, error) {
+	defer c.invalidate()
+	return c.FileStore.MergePages(sourceID, targetID)
+}
+
+func (c *cachingStore) AssignCategory(pageID int, categoryID string, urls []string) ([]Bookmark, error) {
+	defer c.invalidate()
+	return c.FileStore.AssignCategory(pageID, categoryID, urls)
+}
+
+func (c *cachingStore) ClearPage(pageID int, categories bool) (PageWithBookmarks, error) {
+	defer c.invalidate()
+	return c.FileStore.ClearPage(pageID, categories)
+}
+
+func (c *cachingStore) RepairCategories(pageID int, fallback string) ([]Bookmark, error) {
+	defer c.invalidate()
+	return c.FileStore.RepairCategories(pageID, fallback)
+}
+
+func (c *cachingStore) IncrementVisit(pageID int, name, url string) error {
+	defer c.invalidate()
+	return c.FileStore.IncrementVisit(pageID, name, url)
+}
+
+func (c *cachingStore) DeleteCategory(pageID int, categoryID, reassignTo string) ([]Bookmark, error) {
+	defer c.invalidate()
+	return c.FileStore.DeleteCategory(pageID, categoryID, reassignTo)
+}
+
+func (c *cachingStore) SaveFinders(finders []Finder) {
+	defer c.invalidate()
+	c.FileStore.SaveFinders(finders)
+}
+
+func (c *cachingStore) SavePage(page Page, bookmarks []Bookmark) {
+	defer c.invalidate()
+	c.FileStore.SavePage(page, bookmarks)
+}
+
+func (c *cachingStore) DeletePage(pageID int) error {
+	defer c.invalidate()
+	return c.FileStore.DeletePage(pageID)
+}
+
+func (c *cachingStore) RenamePage(pageID int, name string) error {
+	defer c.invalidate()
+	return c.FileStore.RenamePage(pageID, name)
+}
+
+func (c *cachingStore) SetPageFavicon(pageID int, favicon string) error {
+	defer c.invalidate()
+	return c.FileStore.SetPageFavicon(pageID, favicon)
+}
+
+func (c *cachingStore) SavePageOrder(order []int) {
+	defer c.invalidate()
+	c.FileStore.SavePageOrder(order)
+}
+
+func (c *cachingStore) SavePages(pages []Page) error {
+	defer c.invalidate()
+	return c.FileStore.SavePages(pages)
+}
+
+func (c *cachingStore) SaveSettings(settings Settings) {
+	defer c.invalidate()
+	c.FileStore.SaveSettings(settings)
+}
+
+func (c *cachingStore) SaveColors(colors ColorTheme) {
+	defer c.invalidate()
+	c.FileStore.SaveColors(colors)
+}
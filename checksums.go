@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"sort"
+	"strings"
+)
+
+// checksumsFile lists the SHA-256 of every other file in a backup, in the format
+// of sha256sum so it can also be checked by hand with `sha256sum -c`
+const checksumsFile = "checksums.txt"
+
+// backupChecksums collects the checksums of the files written to a backup, by
+// file name
+type backupChecksums map[string][]byte
+
+// Bytes returns the content of checksums.txt, sorted by file name
+func (c backupChecksums) Bytes() []byte {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", hex.EncodeToString(c[name]), name)
+	}
+	return buf.Bytes()
+}
+
+// parseChecksums reads a checksums.txt into a map from file name to hex digest
+func parseChecksums(content []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(text, "  ")
+		name = strings.TrimPrefix(name, "*") // sha256sum's binary mode marker
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != 64 || name == "" {
+			return nil, fmt.Errorf("line %d of %s is not a checksum", line, checksumsFile)
+		}
+		sums[name] = strings.ToLower(sum)
+	}
+	return sums, scanner.Err()
+}
+
+// verifyImportChecksums checks the uploaded files against the checksums.txt among
+// them. Imports without one pass, as do the files of older backups.
+func verifyImportChecksums(files []*multipart.FileHeader) error {
+	var sums map[string]string
+	for _, fileHeader := range files {
+		if strings.ReplaceAll(fileHeader.Filename, "\\", "/") != checksumsFile {
+			continue
+		}
+		content, err := readFileHeader(fileHeader)
+		if err != nil {
+			return err
+		}
+		if sums, err = parseChecksums(content); err != nil {
+			return err
+		}
+	}
+	if sums == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, fileHeader := range files {
+		filename := strings.ReplaceAll(fileHeader.Filename, "\\", "/")
+		if filename == checksumsFile {
+			continue
+		}
+		expected, ok := sums[filename]
+		if !ok {
+			return fmt.Errorf("%s is not listed in %s", filename, checksumsFile)
+		}
+		content, err := readFileHeader(fileHeader)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != expected {
+			return fmt.Errorf("%s doesn't match its checksum", filename)
+		}
+		seen[filename] = true
+	}
+	for filename := range sums {
+		if !seen[filename] {
+			return fmt.Errorf("%s is listed in %s but missing", filename, checksumsFile)
+		}
+	}
+	return nil
+}
+
+// readFileHeader reads an uploaded file
+func readFileHeader(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// corsOrigins is the CORS_ORIGINS allowlist. A nil list allows any origin.
+type corsOrigins []string
+
+// loadCORSOrigins reads CORS_ORIGINS, a comma-separated list of origins such as
+// https://home.example.com, or "*" (the default) to allow any origin
+func loadCORSOrigins() corsOrigins {
+	value := strings.TrimSpace(os.Getenv("CORS_ORIGINS"))
+	if value == "" || value == "*" {
+		return nil
+	}
+	var origins corsOrigins
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for a request from
+// origin, or "" when it isn't allowed
+func (co corsOrigins) allowOrigin(origin string) string {
+	if co == nil {
+		return "*"
+	}
+	for _, allowed := range co {
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns written by ExportCSV and read by ImportCSV
+var csvColumns = []string{"name", "url", "shortcut", "category", "checkStatus", "subcategory"}
+
+// categoryResolver maps category names from imported files to the IDs of a
+// page's categories, creating categories that don't exist yet
+type categoryResolver struct {
+	categories []Category
+	byName     map[string]string
+	created    bool
+}
+
+func newCategoryResolver(categories []Category) *categoryResolver {
+	resolver := &categoryResolver{
+		categories: categories,
+		byName:     make(map[string]string),
+	}
+	for _, category := range categories {
+		resolver.byName[strings.ToLower(category.Name)] = category.ID
+		resolver.byName[strings.ToLower(category.ID)] = category.ID
+	}
+	return resolver
+}
+
+// resolve returns the ID of the category called name, or "" for an empty name
+func (cr *categoryResolver) resolve(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	if id, ok := cr.byName[strings.ToLower(name)]; ok {
+		return id
+	}
+
+	taken := make(map[string]bool)
+	for _, category := range cr.categories {
+		taken[category.ID] = true
+	}
+	id := newCategoryID(taken)
+	cr.categories = append(cr.categories, Category{ID: id, Name: name, OriginalID: id})
+	cr.byName[strings.ToLower(name)] = id
+	cr.created = true
+	return id
+}
+
+// importPageID reads the required page query parameter of the import and export
+// endpoints, writing an error response and returning false when it's invalid
+func (h *Handlers) importPageID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return 0, false
+	}
+	if !h.store.PageExists(pageID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return 0, false
+	}
+	return pageID, true
+}
+
+// importBody returns the uploaded file of a multipart request (field "file"), or
+// the request body itself
+func importBody(r *http.Request) (io.Reader, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	return r.Body, nil
+}
+
+// appendImportedBookmarks adds bookmarks to the end of a page, saving any
+// categories the resolver created first
+func (h *Handlers) appendImportedBookmarks(r *http.Request, pageID int, resolver *categoryResolver, bookmarks []Bookmark) {
+	if resolver.created {
+		h.store.SaveCategoriesByPage(pageID, resolver.categories)
+		h.events.Publish("categories", pageID)
+	}
+	if len(bookmarks) > 0 {
+		h.store.SaveBookmarksByPage(pageID, append(h.store.GetBookmarksByPage(pageID), bookmarks...))
+		h.events.Publish("bookmarks", pageID)
+		h.audit.Record(r, bookmarkChanges(pageID, nil, bookmarks)...)
+	}
+}
+
+// ExportCSV downloads a page's bookmarks as CSV, with category names instead of IDs
+func (h *Handlers) ExportCSV(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := h.importPageID(w, r)
+	if !ok {
+		return
+	}
+
+	categoryNames := make(map[string]string)
+	for _, category := range h.store.GetCategoriesByPage(pageID) {
+		categoryNames[category.ID] = category.Name
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=bookmarks-%d.csv", pageID))
+
+	writer := csv.NewWriter(w)
+	writer.Write(csvColumns)
+	for _, bookmark := range h.store.GetBookmarksByPage(pageID) {
+		category := bookmark.Category
+		if name, ok := categoryNames[category]; ok {
+			category = name
+		}
+		writer.Write([]string{
+			bookmark.Name,
+			bookmark.URL,
+			bookmark.Shortcut,
+			category,
+			strconv.FormatBool(bookmark.CheckStatus),
+			bookmark.Subcategory,
+		})
+	}
+	writer.Flush()
+}
+
+// ImportCSV appends the bookmarks of a CSV file to a page. Columns are name, url,
+// shortcut, category, checkStatus and subcategory, in that order unless a header row names them.
+// Categories are matched by name and created when missing.
+func (h *Handlers) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := h.importPageID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB max
+	body, err := importBody(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Failed to read file")
+		return
+	}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid CSV: %v", err))
+		return
+	}
+
+	// Use the header row for the column order when there is one
+	columns := make(map[string]int)
+	for i, column := range csvColumns {
+		columns[strings.ToLower(column)] = i
+	}
+	firstRow := 0
+	if len(records) > 0 && isCSVHeader(records[0]) {
+		columns = make(map[string]int)
+		for i, column := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+		firstRow = 1
+	}
+	field := func(record []string, column string) string {
+		if i, ok := columns[strings.ToLower(column)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	settings := h.settingsFor(r)
+	validateShortcut := shortcutValidator(settings)
+	resolver := newCategoryResolver(h.store.GetCategoriesByPage(pageID))
+	var bookmarks []Bookmark
+	for i, record := range records[firstRow:] {
+		line := firstRow + i + 1
+		name, bookmarkURL := field(record, "name"), field(record, "url")
+		if name == "" && bookmarkURL == "" {
+			continue // Skip blank lines
+		}
+		if err := validateBookmarkURL(bookmarkURL, settings.AllowCustomSchemes); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidURL, fmt.Sprintf("Invalid bookmark URL on line %d: %v", line, err))
+			return
+		}
+		shortcut := field(record, "shortcut")
+		if err := validateShortcut(shortcut); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidShortcut, fmt.Sprintf("Invalid shortcut on line %d: %v", line, err))
+			return
+		}
+
+		checkStatus, _ := strconv.ParseBool(field(record, "checkStatus"))
+		bookmarks = append(bookmarks, Bookmark{
+			Name:        name,
+			URL:         bookmarkURL,
+			Shortcut:    shortcut,
+			Category:    resolver.resolve(field(record, "category")),
+			Subcategory: field(record, "subcategory"),
+			CheckStatus: checkStatus,
+		})
+	}
+
+	h.appendImportedBookmarks(r, pageID, resolver, bookmarks)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "imported": len(bookmarks)})
+}
+
+// isCSVHeader reports whether record is a header row rather than a bookmark
+func isCSVHeader(record []string) bool {
+	for _, cell := range record {
+		cell = strings.ToLower(strings.TrimSpace(cell))
+		if cell == "name" || cell == "url" {
+			return true
+		}
+	}
+	return false
+}
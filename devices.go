@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// deviceCookie names the cookie identifying a device when PER_DEVICE_SETTINGS=true
+const deviceCookie = "device_id"
+
+var deviceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// deviceDir holds the per-device copies of settings and colors
+var deviceDir = filepath.Join("data", "devices")
+
+// deviceStore keeps the settings and colors of each device under
+// <dir>/<device id>/, so people sharing a dashboard can each have their own theme
+// and layout. A device's copy only holds the fields it changed; everything else
+// follows the global settings and colors.
+type deviceStore struct {
+	mutex sync.Mutex
+	dir   string
+}
+
+// loadDeviceStore returns the device store when PER_DEVICE_SETTINGS=true, or nil
+func loadDeviceStore() *deviceStore {
+	if strings.ToLower(os.Getenv("PER_DEVICE_SETTINGS")) != "true" {
+		return nil
+	}
+	return &deviceStore{dir: deviceDir}
+}
+
+// archive moves the copies of every device to target, so all devices use the
+// global settings and colors again
+func (d *deviceStore) archive(target string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := os.Rename(d.dir, target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// deviceID returns the valid device ID of the request, or ""
+func deviceID(r *http.Request) string {
+	cookie, err := r.Cookie(deviceCookie)
+	if err != nil || !deviceIDPattern.MatchString(cookie.Value) {
+		return ""
+	}
+	return cookie.Value
+}
+
+// deviceMiddleware gives devices without a device cookie a new one, which the
+// rest of the request already sees
+func deviceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if deviceID(r) == "" {
+			b := make([]byte, 16)
+			rand.Read(b)
+			cookie := &http.Cookie{
+				Name:     deviceCookie,
+				Value:    hex.EncodeToString(b),
+				Path:     "/",
+				MaxAge:   10 * 365 * 24 * 60 * 60,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			}
+			http.SetCookie(w, cookie)
+			r.AddCookie(cookie)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deviceOverrides returns the JSON fields of value that differ from global. A
+// device's copy keeps only these, so later changes to the other fields reach it.
+// Fields global has but value leaves out are kept as null.
+func deviceOverrides(value, global interface{}) (map[string]json.RawMessage, error) {
+	valueFields, err := jsonFields(value)
+	if err != nil {
+		return nil, err
+	}
+	globalFields, err := jsonFields(global)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]json.RawMessage)
+	for key, field := range valueFields {
+		if !bytes.Equal(field, globalFields[key]) {
+			overrides[key] = field
+		}
+	}
+	for key := range globalFields {
+		if _, ok := valueFields[key]; !ok {
+			overrides[key] = json.RawMessage("null")
+		}
+	}
+	return overrides, nil
+}
+
+// jsonFields returns the top-level fields of v encoded as JSON
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// loadOverrides returns global with the fields of the device's copy of a file
+// replacing its own. Fields are replaced whole, so maps such as the custom themes
+// aren't merged with the global ones.
+func loadOverrides[T any](d *deviceStore, id, name string, global T) T {
+	d.mutex.Lock()
+	data, err := os.ReadFile(filepath.Join(d.dir, id, name))
+	d.mutex.Unlock()
+	if err != nil {
+		return global
+	}
+
+	var overrides map[string]json.RawMessage
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return global
+	}
+	fields, err := jsonFields(global)
+	if err != nil {
+		return global
+	}
+	for key, field := range overrides {
+		fields[key] = field
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return global
+	}
+	var value T
+	if err := json.Unmarshal(merged, &value); err != nil {
+		return global
+	}
+	return value
+}
+
+// save stores the fields of value that differ from global as the device's copy
+func (d *deviceStore) save(id, name string, value, global interface{}) error {
+	overrides, err := deviceOverrides(value, global)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(d.dir, id), 0755); err != nil {
+		return err
+	}
+	data, err := marshalDataFile(overrides)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d.dir, id, name), data, 0644)
+}
+
+// settingsFor returns the settings of the request's device, or the global settings.
+// The device's copy only holds the fields it overrides, the rest follow the global
+// settings. UpdatedAt is the later of the two saves.
+func (h *Handlers) settingsFor(r *http.Request) Settings {
+	settings := h.store.GetSettings()
+	if id := deviceID(r); h.devices != nil && id != "" {
+		updatedAt := settings.UpdatedAt
+		settings = loadOverrides(h.devices, id, "settings.json", settings)
+		settings.UpdatedAt = max(settings.UpdatedAt, updatedAt)
+	}
+	return settings
+}
+
+// saveSettingsFor saves settings for the request's device, or globally
+func (h *Handlers) saveSettingsFor(r *http.Request, settings Settings) error {
+	if id := deviceID(r); h.devices != nil && id != "" {
+		settings.UpdatedAt = nowMillis()
+		return h.devices.save(id, "settings.json", settings, h.store.GetSettings())
+	}
+	h.store.SaveSettings(settings)
+	return nil
+}
+
+// colorsFor returns the colors of the request's device, or the global colors,
+// merged like settingsFor
+func (h *Handlers) colorsFor(r *http.Request) ColorTheme {
+	colors := h.store.GetColors()
+	if id := deviceID(r); h.devices != nil && id != "" {
+		updatedAt := colors.UpdatedAt
+		colors = loadOverrides(h.devices, id, "colors.json", colors)
+		colors.UpdatedAt = max(colors.UpdatedAt, updatedAt)
+	}
+	return colors
+}
+
+// saveColorsFor saves colors for the request's device, or globally
+func (h *Handlers) saveColorsFor(r *http.Request, colors ColorTheme) error {
+	if id := deviceID(r); h.devices != nil && id != "" {
+		colors.UpdatedAt = nowMillis()
+		return h.devices.save(id, "colors.json", colors, h.store.GetColors())
+	}
+	h.store.SaveColors(colors)
+	return nil
+}
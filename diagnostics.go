@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileDiagnostics describes the state of one file in the data directory
+type fileDiagnostics struct {
+	File               string         `json:"file"`
+	Size               int64          `json:"size"`
+	Modified           time.Time      `json:"modified"`
+	Valid              *bool          `json:"valid,omitempty"` // Whether the JSON parses (JSON files only)
+	Error              string         `json:"error,omitempty"`
+	Bookmarks          *int           `json:"bookmarks,omitempty"`          // Page files only
+	Categories         *int           `json:"categories,omitempty"`         // Page files only
+	DuplicateShortcuts map[string]int `json:"duplicateShortcuts,omitempty"` // Shortcut -> number of bookmarks using it
+	OrphanedCategories map[string]int `json:"orphanedCategories,omitempty"` // Missing category ID -> number of bookmarks using it
+}
+
+// unreadableFile is a data file that exists but can't be parsed
+type unreadableFile struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// Diagnostics reports the health of the data directory: every file with its size and
+// modification time, whether JSON files parse, per-page bookmark and category counts,
+// duplicate shortcuts, bookmarks pointing at missing categories, and the copies of
+// corrupt files preserved by the store
+func (h *Handlers) Diagnostics(w http.ResponseWriter, r *http.Request) {
+	dataDir := "data"
+	files := []fileDiagnostics{}
+	unreadable := []unreadableFile{}
+	corruptCopies := []string{}
+	iconCount, iconBytes := 0, int64(0)
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil && !os.IsNotExist(err) {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to read data directory")
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if name == "icons" {
+				iconCount, iconBytes = directoryUsage(filepath.Join(dataDir, name))
+			}
+			continue
+		}
+		if strings.Contains(name, ".corrupt-") {
+			corruptCopies = append(corruptCopies, name)
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		file := fileDiagnostics{File: name, Size: info.Size(), Modified: info.ModTime()}
+
+		if strings.HasSuffix(name, ".json") {
+			err := diagnoseJSONFile(filepath.Join(dataDir, name), &file)
+			valid := err == nil
+			file.Valid = &valid
+			if err != nil {
+				file.Error = err.Error()
+				unreadable = append(unreadable, unreadableFile{File: name, Error: err.Error()})
+			}
+		}
+
+		files = append(files, file)
+	}
+	sort.Strings(corruptCopies)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files":           files,
+		"icons":           map[string]interface{}{"count": iconCount, "size": iconBytes},
+		"unreadableFiles": unreadable,
+		"corruptCopies":   corruptCopies,
+	})
+}
+
+// diagnoseJSONFile parses a JSON data file, filling in the page checks for bookmark files
+func diagnoseJSONFile(path string, file *fileDiagnostics) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := bookmarksFilePageID(file.File); !ok {
+		var value interface{}
+		return json.Unmarshal(data, &value)
+	}
+
+	var page PageWithBookmarks
+	if err := json.Unmarshal(data, &page); err != nil {
+		return err
+	}
+
+	bookmarkCount, categoryCount := len(page.Bookmarks), len(page.Categories)
+	file.Bookmarks = &bookmarkCount
+	file.Categories = &categoryCount
+
+	shortcuts := make(map[string]int)
+	for _, bookmark := range page.Bookmarks {
+		if bookmark.Shortcut != "" {
+			shortcuts[strings.ToUpper(bookmark.Shortcut)]++
+		}
+	}
+	for shortcut, count := range shortcuts {
+		if count > 1 {
+			if file.DuplicateShortcuts == nil {
+				file.DuplicateShortcuts = make(map[string]int)
+			}
+			file.DuplicateShortcuts[shortcut] = count
+		}
+	}
+
+	for _, i := range findOrphanedBookmarks(page.Categories, page.Bookmarks) {
+		if file.OrphanedCategories == nil {
+			file.OrphanedCategories = make(map[string]int)
+		}
+		file.OrphanedCategories[page.Bookmarks[i].Category]++
+	}
+
+	return nil
+}
+
+// directoryUsage returns the number of files in dir and their total size
+func directoryUsage(dir string) (int, int64) {
+	count, size := 0, int64(0)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+			size += info.Size()
+		}
+		return nil
+	})
+	return count, size
+}
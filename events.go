@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// changeEvent describes a data change published to /api/events subscribers
+type changeEvent struct {
+	ID   int64  `json:"-"`
+	Type string `json:"type"`           // "bookmarks", "categories", "pages", "settings", "colors", "finders" or "import"
+	Page int    `json:"page,omitempty"` // Page ID for page-specific changes
+}
+
+// eventHistorySize is how many recent events are kept for Last-Event-ID replay
+const eventHistorySize = 100
+
+// eventHub is a small in-process pub/sub used to notify open dashboards of changes
+type eventHub struct {
+	mutex       sync.Mutex
+	nextID      int64
+	history     []changeEvent
+	subscribers map[chan changeEvent]struct{}
+	listeners   []func(changeEvent)
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		// Start from the current time so IDs keep increasing across restarts
+		nextID:      time.Now().UnixMilli(),
+		subscribers: make(map[chan changeEvent]struct{}),
+	}
+}
+
+// Listen registers fn to be called synchronously on every published event, for
+// in-process state derived from the data such as the shortcut index
+func (hub *eventHub) Listen(fn func(changeEvent)) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.listeners = append(hub.listeners, fn)
+}
+
+// Publish sends an event to every subscriber. Slow subscribers miss events
+// rather than blocking the save handler; they can catch up by reconnecting.
+func (hub *eventHub) Publish(eventType string, pageID int) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.nextID++
+	event := changeEvent{ID: hub.nextID, Type: eventType, Page: pageID}
+
+	hub.history = append(hub.history, event)
+	if len(hub.history) > eventHistorySize {
+		hub.history = hub.history[len(hub.history)-eventHistorySize:]
+	}
+
+	for _, fn := range hub.listeners {
+		fn(event)
+	}
+
+	for ch := range hub.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a new subscriber and returns it along with the events
+// published after lastID that it missed
+func (hub *eventHub) Subscribe(lastID int64) (chan changeEvent, []changeEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	ch := make(chan changeEvent, 16)
+	hub.subscribers[ch] = struct{}{}
+
+	var missed []changeEvent
+	if lastID > 0 {
+		for _, event := range hub.history {
+			if event.ID > lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return ch, missed
+}
+
+// Unsubscribe removes a subscriber registered with Subscribe
+func (hub *eventHub) Unsubscribe(ch chan changeEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	delete(hub.subscribers, ch)
+}
+
+// Events streams data change notifications as Server-Sent Events
+func (h *Handlers) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Streaming not supported")
+		return
+	}
+
+	// Browsers send Last-Event-ID when reconnecting
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	ch, missed := h.events.Subscribe(lastID)
+	defer h.events.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent := func(event changeEvent) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, data)
+	}
+
+	for _, event := range missed {
+		writeEvent(event)
+	}
+	flusher.Flush()
+
+	// Periodic comments keep proxies from closing an idle connection
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			writeEvent(event)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// faviconSizes are the square sizes made from an uploaded PNG favicon: browser
+// tabs (16, 32), iOS home screens (180) and web app icons (192, 512)
+var faviconSizes = []int{16, 32, 180, 192, 512}
+
+// faviconVariantDir holds the resized favicons, relative to the data directory
+const faviconVariantDir = "favicons"
+
+// maxFaviconSide is the largest width or height of a favicon that gets resized,
+// so a small file can't expand into a huge image in memory
+const maxFaviconSide = 4096
+
+// faviconVariantPath is where the favicon resized to size is kept, relative to the
+// data directory
+func faviconVariantPath(size int) string {
+	return fmt.Sprintf("%s/favicon-%d.png", faviconVariantDir, size)
+}
+
+// writeFaviconVariants replaces the resized copies of the favicon at faviconFile.
+// Only PNGs are resized, to every size up to their longest side so nothing is
+// enlarged; other formats just remove the copies of the previous favicon.
+func writeFaviconVariants(faviconFile string) error {
+	dir := filepath.Join("data", faviconVariantDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(faviconFile)
+	if err != nil {
+		return err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || format != "png" || config.Width > maxFaviconSide || config.Height > maxFaviconSide {
+		return nil
+	}
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil
+	}
+
+	bounds := img.Bounds()
+	side := max(bounds.Dx(), bounds.Dy())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, size := range faviconSizes {
+		if size > side {
+			break
+		}
+		// Fit the image in the square, centered, keeping its aspect ratio
+		width, height := bounds.Dx()*size/side, bounds.Dy()*size/side
+		offset := image.Pt((size-width)/2, (size-height)/2)
+		resized := image.NewNRGBA(image.Rect(0, 0, size, size))
+		xdraw.CatmullRom.Scale(resized, image.Rectangle{Min: offset, Max: offset.Add(image.Pt(width, height))}, img, bounds, draw.Src, nil)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, resized); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join("data", filepath.FromSlash(faviconVariantPath(size))), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// faviconVariantSizes returns the sizes the custom favicon in settings has been
+// resized to. Copies older than the favicon, left over after a theme pack or
+// backup replaced it, don't count.
+func faviconVariantSizes(settings Settings) []int {
+	if !settings.EnableCustomFavicon {
+		return nil
+	}
+	source, err := os.Stat(dataFileFromURL(settings.CustomFaviconPath))
+	if err != nil {
+		return nil
+	}
+	var sizes []int
+	for _, size := range faviconSizes {
+		info, err := os.Stat(filepath.Join("data", filepath.FromSlash(faviconVariantPath(size))))
+		if err == nil && !info.ModTime().Before(source.ModTime()) {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+// serveSiteFavicon serves the custom favicon in settings, or the default one.
+// With a size above 0 it serves the closest resized copy instead when there is
+// one: the smallest at least that large, else the largest.
+func (h *Handlers) serveSiteFavicon(w http.ResponseWriter, r *http.Request, settings Settings, size int) {
+	if settings.EnableCustomFavicon && settings.CustomFaviconPath != "" {
+		if sizes := faviconVariantSizes(settings); size > 0 && len(sizes) > 0 {
+			best := sizes[len(sizes)-1]
+			for i := len(sizes) - 1; i >= 0 && sizes[i] >= size; i-- {
+				best = sizes[i]
+			}
+			if serveFaviconFile(w, r, faviconVariantPath(best)) {
+				return
+			}
+		}
+		if serveFaviconFile(w, r, strings.TrimPrefix(settings.CustomFaviconPath, "/data/")) {
+			return
+		}
+	}
+	data, err := fs.ReadFile(h.files, "static/favicon.ico")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, "favicon.ico", time.Time{}, bytes.NewReader(data))
+}
+
+// faviconSize reads the optional ?size= of a favicon request, 0 when absent
+func faviconSize(r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("size")
+	if value == "" {
+		return 0, true
+	}
+	size, err := strconv.Atoi(value)
+	return size, err == nil && size > 0
+}
+
+// Favicon serves the dashboard's favicon, in the resized copy closest to ?size=
+// when the uploaded favicon was a PNG large enough to make one
+func (h *Handlers) Favicon(w http.ResponseWriter, r *http.Request) {
+	size, ok := faviconSize(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid size")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	h.serveSiteFavicon(w, r, h.settingsFor(r), size)
+}
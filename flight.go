@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// pingCall is a probe in progress that other requests for the same target wait on
+type pingCall struct {
+	done   chan struct{}
+	result pingResult
+}
+
+// pingGroup collapses concurrent identical pings into a single probe, so a burst
+// of dashboards loading at once doesn't open one connection per tab
+type pingGroup struct {
+	mutex sync.Mutex
+	calls map[string]*pingCall
+
+	// joined, when set, is called each time a request starts waiting on a running
+	// probe. Tests use it to know when every caller shares the probe.
+	joined func(key string)
+}
+
+func newPingGroup() *pingGroup {
+	return &pingGroup{calls: make(map[string]*pingCall)}
+}
+
+// pingKey identifies a probe of targetURL with options: pings share a probe only
+// when they would send the same request
+func pingKey(targetURL *url.URL, options pingOptions) string {
+	// Maps are encoded with sorted keys, so equal headers give equal keys
+	key, _ := json.Marshal([]any{targetURL.String(), options.Mode, options.StatusPath, options.SkipFastPing, options.UserAgent, options.Headers})
+	return string(key)
+}
+
+// Do runs probe for key unless a probe for the same key is already running, in
+// which case it waits for that one and returns its result. A probe that panics
+// counts as offline, so its waiters aren't left hanging.
+func (g *pingGroup) Do(key string, probe func() pingResult) (result pingResult) {
+	g.mutex.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		if g.joined != nil {
+			g.joined(key)
+		}
+		<-call.done
+		return call.result
+	}
+	call := &pingCall{done: make(chan struct{}), result: offlineResult()}
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Ping probe panicked: %v", err)
+		}
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(call.done)
+		result = call.result
+	}()
+	call.result = probe()
+	return call.result
+}
+
+// defaultPingConcurrency is the number of probes that may run at once unless
+// PING_MAX_CONCURRENCY says otherwise
+const defaultPingConcurrency = 32
+
+// pingLimiter caps the number of probes running at once across every ping path,
+// so a large dashboard can't open hundreds of connections together
+type pingLimiter chan struct{}
+
+// loadPingLimiter sizes the limiter from PING_MAX_CONCURRENCY
+func loadPingLimiter() pingLimiter {
+	size := defaultPingConcurrency
+	if value := os.Getenv("PING_MAX_CONCURRENCY"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			size = parsed
+		} else {
+			log.Printf("Warning: invalid PING_MAX_CONCURRENCY %q, using %d", value, size)
+		}
+	}
+	return make(pingLimiter, size)
+}
+
+// Acquire waits for a free slot
+func (l pingLimiter) Acquire() {
+	l <- struct{}{}
+}
+
+// Release frees the slot taken by Acquire
+func (l pingLimiter) Release() {
+	<-l
+}
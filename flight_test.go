@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPingGroupSharesProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	const callers = 20
+	var waiting sync.WaitGroup
+	waiting.Add(callers - 1)
+	group := newPingGroup()
+	group.joined = func(string) { waiting.Done() }
+
+	var dials atomic.Int32
+	dialer := targetPolicy{allowPrivate: true}.dialer(time.Second)
+	probe := func() pingResult {
+		// Hold the probe until every other caller is waiting on it
+		waiting.Wait()
+		dials.Add(1)
+		conn, err := dialer.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return offlineResult()
+		}
+		conn.Close()
+		return pingResult{Status: "online"}
+	}
+
+	var wg sync.WaitGroup
+	results := make([]pingResult, callers)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = group.Do("key", probe)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := dials.Load(); n != 1 {
+		t.Fatalf("dials = %d, want 1", n)
+	}
+	for i, result := range results {
+		if result.Status != "online" {
+			t.Errorf("results[%d].Status = %q, want online", i, result.Status)
+		}
+	}
+}
+
+func TestPingGroupRecoversPanic(t *testing.T) {
+	group := newPingGroup()
+	joined := make(chan struct{})
+	group.joined = func(string) { close(joined) }
+	started := make(chan struct{})
+	waiter := make(chan pingResult)
+	go func() {
+		<-started
+		waiter <- group.Do("key", func() pingResult { return pingResult{Status: "online"} })
+	}()
+
+	result := group.Do("key", func() pingResult {
+		close(started)
+		<-joined
+		panic("probe failed")
+	})
+	if result.Status != "offline" {
+		t.Errorf("Status = %q, want offline", result.Status)
+	}
+	select {
+	case result := <-waiter:
+		if result.Status == "" {
+			t.Errorf("waiter got an empty result")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not released")
+	}
+	if len(group.calls) != 0 {
+		t.Errorf("calls left in the group: %d", len(group.calls))
+	}
+}
+
+func TestPingKeyIncludesRequest(t *testing.T) {
+	target, _ := url.Parse("https://example.com/")
+	base := pingOptions{Mode: "get", Headers: map[string]string{"A": "1", "B": "2"}}
+	same := pingOptions{Mode: "get", Headers: map[string]string{"B": "2", "A": "1"}}
+	if pingKey(target, base) != pingKey(target, same) {
+		t.Error("equal headers gave different keys")
+	}
+
+	otherHeaders := base
+	otherHeaders.Headers = map[string]string{"A": "1", "B": "3"}
+	otherAgent := base
+	otherAgent.UserAgent = "monitor/1.0"
+	for _, options := range []pingOptions{otherHeaders, otherAgent} {
+		if pingKey(target, base) == pingKey(target, options) {
+			t.Errorf("options %+v share a key with %+v", options, base)
+		}
+	}
+}
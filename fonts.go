@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// customFont is an uploaded font listed in data/fonts.json
+type customFont struct {
+	ID   string `json:"id"`   // Content hash, also the file name in data/fonts
+	Name string `json:"name"` // Original file name without extension
+	Path string `json:"path"` // URL of the font file
+}
+
+// family is the CSS font-family name font.css declares for the font
+func (f customFont) family() string {
+	return "font-" + f.ID
+}
+
+// fontLibrary keeps the uploaded fonts in <dataDir>/fonts and their list in
+// <dataDir>/fonts.json
+type fontLibrary struct {
+	mutex   sync.Mutex
+	dataDir string
+}
+
+func newFontLibrary(dataDir string) *fontLibrary {
+	return &fontLibrary{dataDir: dataDir}
+}
+
+func (fl *fontLibrary) listFile() string {
+	return filepath.Join(fl.dataDir, "fonts.json")
+}
+
+// list must be called with the mutex held
+func (fl *fontLibrary) list() []customFont {
+	fonts := []customFont{}
+	data, err := os.ReadFile(fl.listFile())
+	if err != nil {
+		return fonts
+	}
+	var listed []customFont
+	json.Unmarshal(data, &listed)
+	// Entries edited by hand that don't point at a library file are left out
+	for _, font := range listed {
+		if validateFont(font) == nil {
+			fonts = append(fonts, font)
+		}
+	}
+	return fonts
+}
+
+// save must be called with the mutex held
+func (fl *fontLibrary) save(fonts []customFont) error {
+	data, err := marshalDataFile(fonts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fl.listFile(), data, 0644)
+}
+
+// List returns the uploaded fonts in upload order
+func (fl *fontLibrary) List() []customFont {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	return fl.list()
+}
+
+// Get returns the font with the given ID
+func (fl *fontLibrary) Get(id string) (customFont, bool) {
+	for _, font := range fl.List() {
+		if font.ID == id {
+			return font, true
+		}
+	}
+	return customFont{}, false
+}
+
+// Add stores a font file and lists it. Uploading the same file again returns
+// the existing font.
+func (fl *fontLibrary) Add(name, ext string, content []byte) (customFont, error) {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+
+	sum := sha256.Sum256(content)
+	id := hex.EncodeToString(sum[:8])
+	ext = strings.ToLower(ext)
+
+	fonts := fl.list()
+	for _, font := range fonts {
+		if font.ID == id {
+			return font, nil
+		}
+	}
+
+	fontsDir := filepath.Join(fl.dataDir, "fonts")
+	if err := os.MkdirAll(fontsDir, 0755); err != nil {
+		return customFont{}, err
+	}
+	if err := os.WriteFile(filepath.Join(fontsDir, id+ext), content, 0644); err != nil {
+		return customFont{}, err
+	}
+
+	font := customFont{ID: id, Name: name, Path: "/data/fonts/" + id + ext}
+	if err := fl.save(append(fonts, font)); err != nil {
+		return customFont{}, err
+	}
+	return font, nil
+}
+
+// Remove deletes a font and its file, reporting whether it existed
+func (fl *fontLibrary) Remove(id string) (bool, error) {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+
+	fonts := fl.list()
+	for i, font := range fonts {
+		if font.ID != id {
+			continue
+		}
+		if err := fl.save(append(fonts[:i:i], fonts[i+1:]...)); err != nil {
+			return true, err
+		}
+		os.Remove(filepath.Join(fl.dataDir, "fonts", path.Base(font.Path)))
+		return true, nil
+	}
+	return false, nil
+}
+
+// fontFormats maps font file extensions to their CSS format() names
+var fontFormats = map[string]string{
+	".woff2": "woff2",
+	".woff":  "woff",
+	".ttf":   "truetype",
+	".otf":   "opentype",
+}
+
+// fontIDPattern matches the IDs fontLibrary.Add gives fonts
+var fontIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// fontPathPattern matches the URLs of uploaded fonts: a library font under
+// /data/fonts/ or the legacy /data/font.<ext>
+var fontPathPattern = regexp.MustCompile(`^/data/(fonts/[0-9a-f]{16}|font)\.(woff2|woff|ttf|otf)$`)
+
+// validateFontPath checks a font URL from settings, which may be empty
+func validateFontPath(urlPath string) error {
+	if urlPath != "" && !fontPathPattern.MatchString(urlPath) {
+		return fmt.Errorf("%q is not an uploaded font", urlPath)
+	}
+	return nil
+}
+
+// validateFont checks a font list entry, whose path must be the file named after its ID
+func validateFont(font customFont) error {
+	if !fontIDPattern.MatchString(font.ID) {
+		return fmt.Errorf("%q is not a font ID", font.ID)
+	}
+	if !strings.HasPrefix(font.Path, "/data/fonts/"+font.ID+".") || !fontPathPattern.MatchString(font.Path) {
+		return fmt.Errorf("%q is not the file of font %s", font.Path, font.ID)
+	}
+	return nil
+}
+
+// cssString quotes s as a CSS string, escaping anything that could end it
+func cssString(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, c := range s {
+		switch {
+		case c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\%x ", c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// fontFaceRule returns an @font-face rule for the font at urlPath (under /data/),
+// served under basePath. The URL carries the file's modification time so it can be
+// cached for good.
+func fontFaceRule(family, urlPath, basePath string) string {
+	src := basePath + urlPath
+	if info, err := os.Stat(filepath.Join("data", strings.TrimPrefix(urlPath, "/data/"))); err == nil {
+		src = fmt.Sprintf("%s%s?v=%d", basePath, urlPath, info.ModTime().Unix())
+	}
+	format := ""
+	if name, ok := fontFormats[strings.ToLower(path.Ext(urlPath))]; ok {
+		format = fmt.Sprintf(" format(%s)", cssString(name))
+	}
+	return fmt.Sprintf("@font-face {\n    font-family: %s;\n    src: url(%s)%s;\n    font-display: swap;\n}\n", cssString(family), cssString(src), format)
+}
+
+// FontCSS serves an @font-face rule for every uploaded font and CSS variables for
+// the fonts assigned to headings, body text and monospaced text. The legacy custom
+// font setting still applies to the body when no body font is assigned.
+func (h *Handlers) FontCSS(w http.ResponseWriter, r *http.Request) {
+	settings := h.settingsFor(r)
+	fonts := h.fonts.List()
+	basePath := h.basePathFor(r)
+
+	w.Header().Set("Content-Type", "text/css")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	var css strings.Builder
+	families := make(map[string]string)
+	for _, font := range fonts {
+		css.WriteString(fontFaceRule(font.family(), font.Path, basePath))
+		families[font.ID] = font.family()
+	}
+
+	mainFamily := ""
+	if settings.EnableCustomFont && settings.CustomFontPath != "" && validateFontPath(settings.CustomFontPath) == nil {
+		mainFamily = "CustomFont"
+		for _, font := range fonts {
+			if font.Path == settings.CustomFontPath {
+				mainFamily = font.family()
+			}
+		}
+		if mainFamily == "CustomFont" {
+			css.WriteString(fontFaceRule(mainFamily, settings.CustomFontPath, basePath))
+		}
+	}
+	if family, ok := families[settings.BodyFont]; ok {
+		mainFamily = family
+	}
+
+	var variables, rules strings.Builder
+	if mainFamily != "" {
+		fmt.Fprintf(&variables, "    --font-family-main: %s, monospace;\n", cssString(mainFamily))
+	}
+	if family, ok := families[settings.HeadingFont]; ok {
+		fmt.Fprintf(&variables, "    --font-heading: %s, var(--font-family-main);\n", cssString(family))
+		rules.WriteString(".title, .category-title {\n    font-family: var(--font-heading);\n}\n")
+	}
+	if family, ok := families[settings.MonoFont]; ok {
+		fmt.Fprintf(&variables, "    --font-mono: %s, monospace;\n", cssString(family))
+		rules.WriteString(".bookmark-shortcut, .shortcut-search {\n    font-family: var(--font-mono);\n}\n")
+	}
+	if variables.Len() > 0 {
+		css.WriteString("\n:root {\n" + variables.String() + "}\n")
+	}
+	if rules.Len() > 0 {
+		css.WriteString("\n" + rules.String())
+	}
+
+	w.Write([]byte(css.String()))
+}
+
+// GetFonts lists the uploaded fonts
+func (h *Handlers) GetFonts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.fonts.List())
+}
+
+// DeleteFont removes an uploaded font and unassigns it from any font role
+func (h *Handlers) DeleteFont(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	font, _ := h.fonts.Get(id)
+	found, err := h.fonts.Remove(id)
+	if !found {
+		writeJSONError(w, http.StatusNotFound, codeNotFound, "Font not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error deleting font")
+		return
+	}
+
+	settings := h.store.GetSettings()
+	changed := false
+	for _, role := range []*string{&settings.HeadingFont, &settings.BodyFont, &settings.MonoFont} {
+		if *role == id {
+			*role = ""
+			changed = true
+		}
+	}
+	if settings.CustomFontPath == font.Path {
+		settings.CustomFontPath = ""
+		changed = true
+	}
+	if changed {
+		h.store.SaveSettings(settings)
+		h.events.Publish("settings", 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestCSSStringEscapes(t *testing.T) {
+	for input, want := range map[string]string{
+		"font-0123456789abcdef": `'font-0123456789abcdef'`,
+		`a');}body{x:url(`:      `'a\');}body{x:url('`,
+		`back\slash`:            `'back\\slash'`,
+		"line\nbreak":           `'line\a break'`,
+	} {
+		if got := cssString(input); got != want {
+			t.Errorf("cssString(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestValidateFont(t *testing.T) {
+	valid := customFont{ID: "0123456789abcdef", Path: "/data/fonts/0123456789abcdef.woff2"}
+	if err := validateFont(valid); err != nil {
+		t.Errorf("validateFont(%+v) = %v", valid, err)
+	}
+	for _, font := range []customFont{
+		{ID: "x}body{", Path: "/data/fonts/x.woff2"},
+		{ID: "0123456789abcdef", Path: "/data/fonts/fedcba9876543210.woff2"},
+		{ID: "0123456789abcdef", Path: "https://example.com/0123456789abcdef.woff2"},
+		{ID: "0123456789abcdef", Path: "/data/fonts/0123456789abcdef.woff2')"},
+	} {
+		if validateFont(font) == nil {
+			t.Errorf("validateFont(%+v) accepted an invalid font", font)
+		}
+	}
+}
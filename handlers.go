@@ -4,38 +4,88 @@ import (
 	"bytes"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"log"
+	"math/rand"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/gorilla/mux"
 )
 
 type Handlers struct {
-	store Store
-	files embed.FS
+	store       Store
+	files       embed.FS
+	pingPolicy  targetPolicy
+	events      *eventHub
+	updates     *updateChecker
+	shortcuts   *shortcutIndex
+	readOnly    bool
+	audit       *auditLog
+	corsOrigins corsOrigins
+	fonts       *fontLibrary
+	pings       *pingGroup
+	pingLimit   pingLimiter // Shared by every probe, see PING_MAX_CONCURRENCY
+	pingHistory *pingHistory
+	devices     *deviceStore // Per-device settings and colors, nil unless PER_DEVICE_SETTINGS=true
+	basePath    string       // Sub-path from BASE_PATH, "" when served from /
+	headHTML    bool         // Whether data/head.html goes into the dashboard, see CUSTOM_HEAD_HTML
+	routes      []routeInfo  // Registered routes, set once the router is complete
+	notifier    *statusNotifier
+	importLimit int // Bookmarks per imported page file, see MAX_BOOKMARKS_PER_PAGE
 }
 
 func NewHandlers(store Store, files embed.FS) *Handlers {
+	events := newEventHub()
+	policy := loadTargetPolicy()
 	return &Handlers{
-		store: store,
-		files: files,
+		store:       store,
+		files:       files,
+		pingPolicy:  policy,
+		events:      events,
+		updates:     newUpdateChecker(),
+		shortcuts:   newShortcutIndex(store, events),
+		readOnly:    readOnlyEnabled(),
+		audit:       newAuditLog(filepath.Join("data", "audit.log")),
+		corsOrigins: loadCORSOrigins(),
+		fonts:       newFontLibrary("data"),
+		pings:       newPingGroup(),
+		pingLimit:   loadPingLimiter(),
+		pingHistory: newPingHistory(filepath.Join("data", "ping-history.json")),
+		devices:     loadDeviceStore(),
+		basePath:    loadBasePath(),
+		headHTML:    customHeadEnabled(),
+		notifier:    newStatusNotifier(policy),
+		importLimit: loadMaxBookmarksPerPage(),
 	}
 }
 
 func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.ParseFS(h.files, "templates/dashboard.html")
 	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Template parsing error")
 		return
 	}
 
-	settings := h.store.GetSettings()
+	settings := h.settingsFor(r)
+	settings.Theme = h.effectiveTheme(r, settings)
+
+	data := h.templateData(r, settings)
+	data.CustomHead = h.customHead()
+	data.ThemeColor = currentThemeColors(h.colorsFor(r), settings.Theme).BackgroundPrimary
+	data.OGImageURL = h.absoluteURL(r, "/api/og-image")
+	data.FaviconSizes = faviconVariantSizes(settings)
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, settings); err != nil {
-		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	if err := tmpl.Execute(&buf, data); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Template execution error")
 		return
 	}
 
@@ -44,18 +94,32 @@ func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
+// pageTemplateData is what the dashboard and config templates render
+type pageTemplateData struct {
+	Settings
+	BasePath     string        // Base the page links are relative to
+	CustomHead   template.HTML // Operator HTML for the dashboard's <head>
+	ThemeColor   string        // Browser UI color, the theme's background
+	OGImageURL   string        // Absolute URL of the link preview image
+	FaviconSizes []int         // Sizes the custom favicon was resized to, see faviconVariantSizes
+}
+
+func (h *Handlers) templateData(r *http.Request, settings Settings) pageTemplateData {
+	return pageTemplateData{Settings: settings, BasePath: h.basePathFor(r)}
+}
+
 func (h *Handlers) Config(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.ParseFS(h.files, "templates/config.html")
 	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Template parsing error")
 		return
 	}
 
-	settings := h.store.GetSettings()
+	settings := h.settingsFor(r)
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, settings); err != nil {
-		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	if err := tmpl.Execute(&buf, h.templateData(r, settings)); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Template execution error")
 		return
 	}
 
@@ -64,14 +128,21 @@ func (h *Handlers) Config(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
-func (h *Handlers) setCORSHeaders(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+func (h *Handlers) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	if h.corsOrigins != nil {
+		// The response depends on the origin when it's echoed back
+		w.Header().Add("Vary", "Origin")
+	}
+	if origin := h.corsOrigins.allowOrigin(r.Header.Get("Origin")); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match, If-None-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
 }
 
 func (h *Handlers) GetBookmarks(w http.ResponseWriter, r *http.Request) {
-	h.setCORSHeaders(w)
+	h.setCORSHeaders(w, r)
 	if r.Method == "OPTIONS" {
 		return
 	}
@@ -79,15 +150,39 @@ func (h *Handlers) GetBookmarks(w http.ResponseWriter, r *http.Request) {
 	all := r.URL.Query().Get("all")
 	var bookmarks []Bookmark
 
-	if all == "true" {
+	// Hidden and out of schedule bookmarks are only listed on request, for managing them
+	includeHidden := r.URL.Query().Get("includeHidden") == "true"
+	respectSchedule := r.URL.Query().Get("respectSchedule")
+	applySchedule := respectSchedule == "true" || (respectSchedule == "" && !includeHidden && h.settingsFor(r).RespectSchedules)
+	now := time.Now()
+
+	if all == "true" && (r.URL.Query().Has("limit") || r.URL.Query().Has("offset")) {
+		h.getBookmarksPage(w, r, includeHidden, applySchedule, now)
+		return
+	} else if all == "true" {
 		// Get bookmarks from all pages
 		bookmarks = h.store.GetAllBookmarks()
 	} else if pageIDStr != "" {
 		pageID, err := strconv.Atoi(pageIDStr)
 		if err != nil {
-			http.Error(w, "Invalid page ID", http.StatusBadRequest)
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		// Tell a missing page apart from an empty one
+		if !h.store.PageExists(pageID) {
+			writeJSONError(w, http.StatusNotFound, codePageNotFound, "page not found")
 			return
 		}
+		if etag, ok := h.currentPageETag(pageID); ok {
+			// Schedules change what's visible over time, so the minute is part of
+			// the ETag when they're applied, as in All
+			if applySchedule {
+				etag = fmt.Sprintf(`%s-%s"`, strings.TrimSuffix(etag, `"`), now.Format("1504"))
+			}
+			if notModified(w, r, etag) {
+				return
+			}
+		}
 		bookmarks = h.store.GetBookmarksByPage(pageID)
 	} else {
 		// No page ID provided - return empty array
@@ -95,48 +190,284 @@ func (h *Handlers) GetBookmarks(w http.ResponseWriter, r *http.Request) {
 		bookmarks = []Bookmark{}
 	}
 
+	if !includeHidden {
+		bookmarks = visibleBookmarks(bookmarks)
+	}
+	if applySchedule {
+		bookmarks = scheduledBookmarks(bookmarks, now)
+	}
+	if filter := metaFilter(r.URL.Query()); len(filter) > 0 {
+		bookmarks = metaBookmarks(bookmarks, filter)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(bookmarks)
 }
 
+// visibleBookmarks returns the bookmarks that aren't hidden
+func visibleBookmarks(bookmarks []Bookmark) []Bookmark {
+	visible := make([]Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		if !bookmark.Hidden {
+			visible = append(visible, bookmark)
+		}
+	}
+	return visible
+}
+
+// getBookmarksPage returns one window of the bookmarks of all pages as
+// {"items": [...], "total": n, "offset": n, "limit": n}. Hidden and out of
+// schedule bookmarks are left out as in GetBookmarks before the window is taken,
+// so total counts only the listed ones.
+func (h *Handlers) getBookmarksPage(w http.ResponseWriter, r *http.Request, includeHidden, applySchedule bool, now time.Time) {
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		value, err := strconv.Atoi(limitStr)
+		if err != nil || value < 1 || value > 1000 {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid limit, must be between 1 and 1000")
+			return
+		}
+		limit = value
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		value, err := strconv.Atoi(offsetStr)
+		if err != nil || value < 0 {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid offset")
+			return
+		}
+		offset = value
+	}
+
+	var items []Bookmark
+	var total int
+	filter := metaFilter(r.URL.Query())
+	if includeHidden && !applySchedule && len(filter) == 0 {
+		items, total = h.store.GetBookmarksRange(offset, limit)
+	} else {
+		// The store can't filter, so the window is taken from the matches
+		matching := h.store.GetAllBookmarks()
+		if !includeHidden {
+			matching = visibleBookmarks(matching)
+		}
+		if applySchedule {
+			matching = scheduledBookmarks(matching, now)
+		}
+		if len(filter) > 0 {
+			matching = metaBookmarks(matching, filter)
+		}
+		total = len(matching)
+		items = matching[min(offset, total):min(offset+limit, total)]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// bookmarkError is a problem with one field of a bookmark, see validateBookmark
+type bookmarkError struct {
+	Field string // JSON name of the field, e.g. "statusUrl"
+	Label string // Name used in API error messages, e.g. "status URL"
+	Code  string // API error code
+	Err   error
+}
+
+func (e *bookmarkError) Error() string {
+	return fmt.Sprintf("Invalid %s: %v", e.Label, e.Err)
+}
+
+func (e *bookmarkError) Unwrap() error {
+	return e.Err
+}
+
+// validateBookmark checks the URLs, status path, shortcut and schedule of a
+// bookmark against settings. Every problem is returned as a *bookmarkError, joined
+// in field order, so errors.As finds the first one.
+func validateBookmark(b Bookmark, settings Settings) error {
+	var errs []error
+	check := func(field, label, code string, err error) {
+		if err != nil {
+			errs = append(errs, &bookmarkError{Field: field, Label: label, Code: code, Err: err})
+		}
+	}
+	check("url", "bookmark URL", codeInvalidURL, validateBookmarkURL(b.URL, settings.AllowCustomSchemes))
+	check("healthUrl", "health check URL", codeInvalidURL, validateBookmarkURL(b.HealthURL, false))
+	check("statusUrl", "status URL", codeInvalidURL, validateBookmarkURL(b.StatusURL, false))
+	check("statusPath", "status path", codeInvalidRequest, validateStatusPath(b.StatusPath))
+	check("statusWebhookUrl", "status webhook URL", codeInvalidURL, validateBookmarkURL(b.StatusWebhookURL, false))
+	check("shortcut", "shortcut", codeInvalidShortcut, shortcutValidator(settings)(b.Shortcut))
+	check("schedule", "schedule", codeInvalidRequest, validateSchedule(b))
+	return errors.Join(errs...)
+}
+
+// writeBookmarkError answers 400 with the first problem found by validateBookmark
+func writeBookmarkError(w http.ResponseWriter, err error) {
+	var invalid *bookmarkError
+	if errors.As(err, &invalid) {
+		writeJSONError(w, http.StatusBadRequest, invalid.Code, invalid.Error())
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+}
+
 func (h *Handlers) SaveBookmarks(w http.ResponseWriter, r *http.Request) {
-	h.setCORSHeaders(w)
+	h.setCORSHeaders(w, r)
 	if r.Method == "OPTIONS" {
 		return
 	}
 	pageIDStr := r.URL.Query().Get("page")
 	if pageIDStr == "" {
-		http.Error(w, "Page ID is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Page ID is required")
 		return
 	}
 
 	var bookmarks []Bookmark
 	if err := json.NewDecoder(r.Body).Decode(&bookmarks); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
-	// Validate each bookmark URL
+	settings := h.settingsFor(r)
 	for _, bookmark := range bookmarks {
-		if err := validateBookmarkURL(bookmark.URL); err != nil {
-			http.Error(w, fmt.Sprintf("Invalid bookmark URL: %v", err), http.StatusBadRequest)
+		if err := validateBookmark(bookmark, settings); err != nil {
+			writeBookmarkError(w, err)
 			return
 		}
 	}
 
 	pageID, err := strconv.Atoi(pageIDStr)
 	if err != nil {
-		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
 		return
 	}
 
-	h.store.SaveBookmarksByPage(pageID, bookmarks)
+	// Optional URL normalization and deduplication
+	normalize := r.URL.Query().Get("normalize") == "true"
+	stripTrailingSlash := r.URL.Query().Get("stripTrailingSlash") == "true"
+	dedupe := r.URL.Query().Get("dedupe") == "true"
+	if normalize || stripTrailingSlash {
+		for i := range bookmarks {
+			bookmarks[i].URL = normalizeBookmarkURL(bookmarks[i].URL, stripTrailingSlash)
+		}
+	}
+	if dedupe {
+		bookmarks = dedupeBookmarks(bookmarks)
+	}
+
+	// With If-Match the save only happens when the page hasn't changed since the
+	// client read it, so two tabs can't silently overwrite each other
+	var previous []Bookmark
+	if r.Header.Get("If-Match") != "" {
+		previous, err = h.store.SaveBookmarksIfVersion(pageID, bookmarks, func(page Page) bool {
+			return !ifMatchFails(r, pageETag(page))
+		})
+		if errors.Is(err, errVersionMismatch) {
+			if etag, ok := h.currentPageETag(pageID); ok {
+				w.Header().Set("ETag", etag)
+			}
+			writeJSONError(w, http.StatusConflict, codeConflict, "The page was changed since it was loaded, reload it and try again")
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving bookmarks")
+			return
+		}
+	} else {
+		previous = h.store.GetBookmarksByPage(pageID)
+		h.store.SaveBookmarksByPage(pageID, bookmarks)
+	}
+	h.events.Publish("bookmarks", pageID)
+	h.audit.Record(r, bookmarkChanges(pageID, previous, bookmarks)...)
+	if etag, ok := h.currentPageETag(pageID); ok {
+		w.Header().Set("ETag", etag)
+	}
 	w.Header().Set("Content-Type", "application/json")
+	if normalize || stripTrailingSlash || dedupe {
+		// Return the saved bookmarks so the client stays in sync
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "bookmarks": bookmarks})
+		return
+	}
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// bookmarkValidation lists the problems found with one bookmark
+type bookmarkValidation struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Errors []string `json:"errors"`
+}
+
+// GetPinnedBookmarks returns the pinned bookmarks of every page, in page order,
+// for the favorites bar
+func (h *Handlers) GetPinnedBookmarks(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	pinned := []Bookmark{}
+	for _, bookmark := range visibleBookmarks(h.store.GetAllBookmarks()) {
+		if bookmark.Pinned {
+			pinned = append(pinned, bookmark)
+		}
+	}
+	if h.settingsFor(r).RespectSchedules {
+		pinned = scheduledBookmarks(pinned, time.Now())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pinned)
+}
+
+// ValidateBookmarks checks a page's bookmarks the way SaveBookmarks would, plus
+// duplicate shortcuts, and reports the problems of each bookmark without saving
+func (h *Handlers) ValidateBookmarks(w http.ResponseWriter, r *http.Request) {
+	if _, err := strconv.Atoi(r.URL.Query().Get("page")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	var bookmarks []Bookmark
+	if err := json.NewDecoder(r.Body).Decode(&bookmarks); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+
+	settings := h.settingsFor(r)
+	shortcutKey := shortcutKeyFunc(settings)
+	firstUse := make(map[string]int)
+
+	results := make([]bookmarkValidation, 0, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		result := bookmarkValidation{Index: i, Name: bookmark.Name, Errors: []string{}}
+		if joined, ok := validateBookmark(bookmark, settings).(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				invalid := err.(*bookmarkError)
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", invalid.Field, invalid.Err))
+			}
+		}
+		if bookmark.Shortcut != "" {
+			key := shortcutKey(bookmark.Shortcut)
+			if first, ok := firstUse[key]; ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("shortcut: %q is already used by bookmark %d", bookmark.Shortcut, first))
+			} else {
+				firstUse[key] = i
+			}
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
 func (h *Handlers) AddBookmark(w http.ResponseWriter, r *http.Request) {
-	h.setCORSHeaders(w)
+	h.setCORSHeaders(w, r)
 	if r.Method == "OPTIONS" {
 		return
 	}
@@ -146,23 +477,24 @@ func (h *Handlers) AddBookmark(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
-	// Validate the bookmark URL
-	if err := validateBookmarkURL(request.Bookmark.URL); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid bookmark URL: %v", err), http.StatusBadRequest)
+	if err := validateBookmark(request.Bookmark, h.settingsFor(r)); err != nil {
+		writeBookmarkError(w, err)
 		return
 	}
 
 	h.store.AddBookmarkToPage(request.Page, request.Bookmark)
+	h.events.Publish("bookmarks", request.Page)
+	h.audit.Record(r, auditEntry{Action: "add", Page: request.Page, Name: request.Bookmark.Name, URL: request.Bookmark.URL})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
 func (h *Handlers) DeleteBookmark(w http.ResponseWriter, r *http.Request) {
-	h.setCORSHeaders(w)
+	h.setCORSHeaders(w, r)
 	if r.Method == "OPTIONS" {
 		return
 	}
@@ -172,14 +504,16 @@ func (h *Handlers) DeleteBookmark(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
 	if err := h.store.DeleteBookmarkFromPage(request.Page, request.Bookmark); err != nil {
-		http.Error(w, "Error deleting bookmark", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error deleting bookmark")
 		return
 	}
+	h.events.Publish("bookmarks", request.Page)
+	h.audit.Record(r, auditEntry{Action: "delete", Page: request.Page, Name: request.Bookmark.Name, URL: request.Bookmark.URL})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -197,7 +531,7 @@ func (h *Handlers) GetCategories(w http.ResponseWriter, r *http.Request) {
 
 	pageID, err := strconv.Atoi(pageIDStr)
 	if err != nil {
-		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
 		return
 	}
 
@@ -215,11 +549,12 @@ func (h *Handlers) GetFinders(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) SaveFinders(w http.ResponseWriter, r *http.Request) {
 	var finders []Finder
 	if err := json.NewDecoder(r.Body).Decode(&finders); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
 	h.store.SaveFinders(finders)
+	h.events.Publish("finders", 0)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
@@ -227,60 +562,294 @@ func (h *Handlers) SaveFinders(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) SaveCategories(w http.ResponseWriter, r *http.Request) {
 	pageIDStr := r.URL.Query().Get("page")
 	if pageIDStr == "" {
-		http.Error(w, "Page ID is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Page ID is required")
 		return
 	}
 
 	var categories []Category
 	if err := json.NewDecoder(r.Body).Decode(&categories); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
 	pageID, err := strconv.Atoi(pageIDStr)
 	if err != nil {
-		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
 		return
 	}
 
 	h.store.SaveCategoriesByPage(pageID, categories)
+	h.events.Publish("categories", pageID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// CollapseCategory stores whether one category is shown collapsed, taking
+// {"id": "...", "collapsed": true} for the page in ?page=
+func (h *Handlers) CollapseCategory(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	var request struct {
+		ID        string `json:"id"`
+		Collapsed bool   `json:"collapsed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ID == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+	if !h.store.PageExists(pageID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	if err := h.store.SetCategoryCollapsed(pageID, request.ID, request.Collapsed); err != nil {
+		if errors.Is(err, errCategoryNotFound) {
+			writeJSONError(w, http.StatusNotFound, codeCategoryNotFound, "Category not found")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving category")
+		return
+	}
+	h.events.Publish("categories", pageID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// ReorderCategories changes the order of the categories of ?page= to the order of
+// the category IDs in the body, without the renaming and remapping of SaveCategories
+func (h *Handlers) ReorderCategories(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+	if !h.store.PageExists(pageID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	if err := h.store.ReorderCategories(pageID, ids); err != nil {
+		if errors.Is(err, errCategoryOrder) {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "The IDs must be those of the page's categories, each once")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving categories")
+		return
+	}
+	h.events.Publish("categories", pageID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// DeleteCategory removes a category of ?page=. A category that still has bookmarks
+// is only removed with ?reassignTo=, the category to move them to, which is
+// created if needed; otherwise the reply is 409 with the number of bookmarks.
+func (h *Handlers) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	categoryID := mux.Vars(r)["id"]
+	reassignTo := r.URL.Query().Get("reassignTo")
+	if reassignTo == categoryID {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Cannot move bookmarks to the category being deleted")
+		return
+	}
+	if !h.store.PageExists(pageID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	moved, err := h.store.DeleteCategory(pageID, categoryID, reassignTo)
+	switch {
+	case errors.Is(err, errCategoryNotFound):
+		writeJSONError(w, http.StatusNotFound, codeCategoryNotFound, "Category not found")
+		return
+	case errors.Is(err, errCategoryInUse):
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":     apiError{Code: codeCategoryInUse, Message: fmt.Sprintf("The category has %d bookmarks, pass reassignTo to move them", len(moved))},
+			"bookmarks": len(moved),
+		})
+		return
+	case err != nil:
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error deleting category")
+		return
+	}
+
+	h.events.Publish("categories", pageID)
+	if len(moved) > 0 {
+		var entries []auditEntry
+		for _, bookmark := range moved {
+			entries = append(entries, auditEntry{Action: "update", Page: pageID, Name: bookmark.Name, URL: bookmark.URL})
+		}
+		h.events.Publish("bookmarks", pageID)
+		h.audit.Record(r, entries...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "moved": len(moved)})
+}
+
+// RepairCategories moves bookmarks that reference a category missing from their
+// page into a fallback category (?fallback=, "others" by default), creating it if needed
+func (h *Handlers) RepairCategories(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	fallback := r.URL.Query().Get("fallback")
+	if fallback == "" {
+		fallback = "others"
+	}
+
+	moved, err := h.store.RepairCategories(pageID, fallback)
+	if errors.Is(err, errPageNotFound) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+	if err != nil {
+		log.Printf("RepairCategories: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error repairing categories")
+		return
+	}
+	if len(moved) > 0 {
+		entries := make([]auditEntry, len(moved))
+		for i, bookmark := range moved {
+			entries[i] = auditEntry{Action: "update", Page: pageID, Name: bookmark.Name, URL: bookmark.URL}
+		}
+		h.events.Publish("categories", pageID)
+		h.events.Publish("bookmarks", pageID)
+		h.audit.Record(r, entries...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "fixed": len(moved)})
+}
+
 func (h *Handlers) GetPages(w http.ResponseWriter, r *http.Request) {
-	h.setCORSHeaders(w)
+	h.setCORSHeaders(w, r)
 	if r.Method == "OPTIONS" {
 		return
 	}
-	pages := h.store.GetPages()
 	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("counts") == "true" {
+		json.NewEncoder(w).Encode(h.store.GetPagesWithCounts())
+		return
+	}
+	pages := h.store.GetPages()
 	json.NewEncoder(w).Encode(pages)
 }
 
+// GetPageFull returns a page's metadata, categories and bookmarks in one response
+func (h *Handlers) GetPageFull(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	vars := mux.Vars(r)
+	pageID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	pageWithBookmarks, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+	if notModified(w, r, pageETag(pageWithBookmarks.Page)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pageWithBookmarks)
+}
+
 func (h *Handlers) SavePages(w http.ResponseWriter, r *http.Request) {
 	var pages []Page
 	if err := json.NewDecoder(r.Body).Decode(&pages); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
-	// Extract page order (array of IDs)
-	order := make([]int, len(pages))
-	for i, page := range pages {
-		order[i] = page.ID
+	// Favicons are set through /api/pages/{id}/favicon, so a page list loaded before
+	// an upload doesn't drop them
+	favicons := make(map[int]string)
+	for _, page := range h.store.GetPages() {
+		favicons[page.ID] = page.Favicon
+	}
+	for i := range pages {
+		pages[i].Favicon = favicons[pages[i].ID]
+	}
+
+	// Save the pages and their order together; bookmarks are saved separately via
+	// the SaveBookmarks endpoint and are kept as they are
+	if err := h.store.SavePages(pages); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving pages")
+		return
+	}
+	h.events.Publish("pages", 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// RenamePage changes the name of a single page
+func (h *Handlers) RenamePage(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
 	}
 
-	// Save the order
-	h.store.SavePageOrder(order)
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Page name is required")
+		return
+	}
+	if len([]rune(name)) > 100 {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Page name must be at most 100 characters")
+		return
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Page name must not contain control characters")
+		return
+	}
 
-	// Save each page individually
-	// Note: This assumes bookmarks are saved separately via SaveBookmarks endpoint
-	for _, page := range pages {
-		// Get existing bookmarks for this page to preserve them
-		bookmarks := h.store.GetBookmarksByPage(page.ID)
-		h.store.SavePage(page, bookmarks)
+	if !h.store.PageExists(pageID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
 	}
+	if err := h.store.RenamePage(pageID, name); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error renaming page")
+		return
+	}
+	h.events.Publish("pages", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -292,21 +861,25 @@ func (h *Handlers) DeletePage(w http.ResponseWriter, r *http.Request) {
 
 	pageID, err := strconv.Atoi(pageIDStr)
 	if err != nil {
-		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
 		return
 	}
 
 	// Prevent deleting page 1 (main page)
 	if pageID == 1 {
-		http.Error(w, "Cannot delete the main page", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Cannot delete the main page")
 		return
 	}
 
-	// Delete the page file
+	// Delete the page file, and its favicon if it has one
+	page, _ := h.store.GetPageWithBookmarks(pageID)
 	if err := h.store.DeletePage(pageID); err != nil {
-		http.Error(w, "Error deleting page", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error deleting page")
 		return
 	}
+	if name := pageFaviconFile(page.Page.Favicon); name != "" {
+		os.Remove(filepath.Join("data", name))
+	}
 
 	// Update the page order - remove the deleted page ID
 	order := h.store.GetPageOrder()
@@ -317,25 +890,149 @@ func (h *Handlers) DeletePage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	h.store.SavePageOrder(newOrder)
+	h.events.Publish("pages", 0)
+	h.audit.Record(r, auditEntry{Action: "delete-page", Page: pageID})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// ClearPage removes every bookmark of a page, and its categories too with
+// ?categories=true, keeping the page's ID, name and place in the page order
+func (h *Handlers) ClearPage(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	clearCategories := r.URL.Query().Get("categories") == "true"
+	previous, err := h.store.ClearPage(pageID, clearCategories)
+	if errors.Is(err, errPageNotFound) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ClearPage: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error clearing page")
+		return
+	}
+	h.events.Publish("bookmarks", pageID)
+	if clearCategories {
+		h.events.Publish("categories", pageID)
+	}
+	h.audit.Record(r, bookmarkChanges(pageID, previous.Bookmarks, nil)...)
+
+	cleared, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error reading page")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cleared)
+}
+
+// effectiveTheme is the theme to show for settings: the stored one, or with
+// RandomThemeOnLoad a different pick among light, dark and the custom themes on
+// every call
+func (h *Handlers) effectiveTheme(r *http.Request, settings Settings) string {
+	if !settings.RandomThemeOnLoad {
+		return settings.Theme
+	}
+	themes := append([]string{"light", "dark"}, customThemeIDs(h.colorsFor(r))...)
+	return themes[rand.Intn(len(themes))]
+}
+
 func (h *Handlers) GetSettings(w http.ResponseWriter, r *http.Request) {
-	settings := h.store.GetSettings()
+	settings := h.settingsFor(r)
+
+	// The current page may have been deleted since, possibly from another tab,
+	// so point it at the first page instead
+	if pages := h.store.GetPages(); len(pages) > 0 {
+		exists := false
+		for _, page := range pages {
+			exists = exists || page.ID == settings.CurrentPage
+		}
+		if !exists {
+			settings.CurrentPage = pages[0].ID
+			if !h.readOnly {
+				if err := h.saveSettingsFor(r, settings); err != nil {
+					log.Printf("Warning: could not save the corrected current page: %v", err)
+				} else {
+					h.events.Publish("settings", 0)
+				}
+			}
+		}
+	}
+
+	if r.URL.Query().Get("download") == "true" {
+		writeDataFileDownload(w, "settings.json", settings)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(settings)
+	json.NewEncoder(w).Encode(h.settingsResponse(r, settings))
+}
+
+// writeDataFileDownload sends v as a download named after its data file, encoded
+// like the file itself so it can be put back in the data directory as is
+func writeDataFileDownload(w http.ResponseWriter, filename string, v interface{}) {
+	data, err := marshalDataFile(v)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error encoding "+filename)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	w.Write(data)
+}
+
+// settingsResponse is the body of GET /api/settings. readOnly reflects the server
+// mode and effectiveTheme may be a random pick, neither is stored with the settings.
+type settingsResponse struct {
+	Settings
+	EffectiveTheme string `json:"effectiveTheme"`
+	ReadOnly       bool   `json:"readOnly"`
+}
+
+func (h *Handlers) settingsResponse(r *http.Request, settings Settings) settingsResponse {
+	return settingsResponse{settings, h.effectiveTheme(r, settings), h.readOnly}
 }
 
 func (h *Handlers) SaveSettings(w http.ResponseWriter, r *http.Request) {
 	var settings Settings
 	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+	if _, err := compileShortcutPattern(settings.ShortcutPattern); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidShortcut, fmt.Sprintf("Invalid shortcut pattern: %v", err))
+		return
+	}
+	if err := validateDisplaySettings(&settings); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidSettings, fmt.Sprintf("Invalid settings: %v", err))
+		return
+	}
+	if err := validateFontPath(settings.CustomFontPath); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidSettings, fmt.Sprintf("Invalid custom font: %v", err))
+		return
+	}
+	// A theme that was already set is kept even if its custom theme was deleted since
+	if settings.Theme != h.settingsFor(r).Theme {
+		if err := validateTheme(settings.Theme, h.colorsFor(r)); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidSettings, fmt.Sprintf("Invalid settings: %v", err))
+			return
+		}
+	}
+	if err := validateBookmarkURL(settings.StatusWebhookURL, false); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidSettings, fmt.Sprintf("Invalid status webhook URL: %v", err))
 		return
 	}
 
-	h.store.SaveSettings(settings)
+	if err := h.saveSettingsFor(r, settings); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving settings")
+		return
+	}
+	h.events.Publish("settings", 0)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
@@ -343,11 +1040,11 @@ func (h *Handlers) SaveSettings(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) Colors(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.ParseFS(h.files, "templates/colors.html")
 	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Template parsing error")
 		return
 	}
 
-	settings := h.store.GetSettings()
+	settings := h.settingsFor(r)
 
 	data := struct {
 		Theme                     string
@@ -386,6 +1083,7 @@ func (h *Handlers) Colors(w http.ResponseWriter, r *http.Request) {
 		ShowIcons                 bool
 		IncludeFindersInSearch    bool
 		AlwaysCollapseCategories  bool
+		BasePath                  string
 	}{
 		Theme:                     settings.Theme,
 		FontSize:                  settings.FontSize,
@@ -423,11 +1121,12 @@ func (h *Handlers) Colors(w http.ResponseWriter, r *http.Request) {
 		ShowIcons:                 settings.ShowIcons,
 		IncludeFindersInSearch:    settings.IncludeFindersInSearch,
 		AlwaysCollapseCategories:  settings.AlwaysCollapseCategories,
+		BasePath:                  h.basePathFor(r),
 	}
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
-		http.Error(w, "Template execution error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Template execution error")
 		return
 	}
 
@@ -437,7 +1136,11 @@ func (h *Handlers) Colors(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) GetColors(w http.ResponseWriter, r *http.Request) {
-	colors := h.store.GetColors()
+	colors := h.colorsFor(r)
+	if r.URL.Query().Get("download") == "true" {
+		writeDataFileDownload(w, "colors.json", colors)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(colors)
 }
@@ -445,18 +1148,22 @@ func (h *Handlers) GetColors(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) SaveColors(w http.ResponseWriter, r *http.Request) {
 	var colors ColorTheme
 	if err := json.NewDecoder(r.Body).Decode(&colors); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
 		return
 	}
 
-	h.store.SaveColors(colors)
+	if err := h.saveColorsFor(r, colors); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving colors")
+		return
+	}
+	h.events.Publish("colors", 0)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
 func (h *Handlers) ResetColors(w http.ResponseWriter, r *http.Request) {
 	// Get current colors to preserve custom themes
-	currentColors := h.store.GetColors()
+	currentColors := h.colorsFor(r)
 
 	// Reset only light and dark themes to defaults, keep custom themes
 	defaultColors := ColorTheme{
@@ -489,27 +1196,32 @@ func (h *Handlers) ResetColors(w http.ResponseWriter, r *http.Request) {
 			AccentError:         "#EF4444",
 		},
 		Custom: currentColors.Custom, // Preserve existing custom themes
+		Order:  currentColors.Order,
 	}
 
-	h.store.SaveColors(defaultColors)
+	if err := h.saveColorsFor(r, defaultColors); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving colors")
+		return
+	}
+	h.events.Publish("colors", 0)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(defaultColors)
 }
 
 func (h *Handlers) GetCustomThemesList(w http.ResponseWriter, r *http.Request) {
-	colors := h.store.GetColors()
+	colors := h.colorsFor(r)
 
-	themesMap := make(map[string]string)
-	for themeID, themeColors := range colors.Custom {
-		themesMap[themeID] = themeColors.Name
+	themes := make([]customThemeSummary, 0, len(colors.Custom))
+	for _, themeID := range customThemeIDs(colors) {
+		themes = append(themes, customThemeSummary{ID: themeID, Name: colors.Custom[themeID].Name})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(themesMap)
+	json.NewEncoder(w).Encode(themes)
 }
 
 func (h *Handlers) CustomThemeCSS(w http.ResponseWriter, r *http.Request) {
-	colors := h.store.GetColors()
+	colors := h.colorsFor(r)
 
 	w.Header().Set("Content-Type", "text/css")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxCustomHeadBytes caps how much of data/head.html is injected
+const maxCustomHeadBytes = 64 << 10
+
+// customHeadEnabled reports whether CUSTOM_HEAD_HTML=true. The file is raw HTML
+// put in every dashboard page as is, so it is only read from the data directory,
+// which only the operator can write, and never from the API.
+func customHeadEnabled() bool {
+	return strings.ToLower(os.Getenv("CUSTOM_HEAD_HTML")) == "true"
+}
+
+// customHead returns the content of data/head.html for the dashboard's <head>, or
+// nothing unless CUSTOM_HEAD_HTML is enabled. It's read on every request so edits
+// show up on reload.
+func (h *Handlers) customHead() template.HTML {
+	if !h.headHTML {
+		return ""
+	}
+	file, err := os.Open(filepath.Join("data", "head.html"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: could not read data/head.html: %v", err)
+		}
+		return ""
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxCustomHeadBytes+1))
+	if err != nil {
+		log.Printf("Warning: could not read data/head.html: %v", err)
+		return ""
+	}
+	if len(content) > maxCustomHeadBytes {
+		log.Printf("Warning: data/head.html is larger than %d bytes and was ignored", maxCustomHeadBytes)
+		return ""
+	}
+	// Trusted operator content, see customHeadEnabled
+	return template.HTML(content)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	pingHistorySize    = 60  // Samples kept per URL
+	pingHistoryMaxURLs = 500 // URLs tracked before the stalest is dropped
+)
+
+// pingSample is one recorded status check
+type pingSample struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"` // "online" or "offline"
+	Ping   *int64    `json:"ping"`   // Response time in ms, null when offline
+}
+
+// pingHistory keeps the latest samples of every status-checked bookmark in memory.
+// It's loaded from and saved to data/ping-history.json so trends survive restarts.
+type pingHistory struct {
+	mutex   sync.Mutex
+	path    string
+	samples map[string][]pingSample // By bookmarkURLKey, oldest first
+}
+
+func newPingHistory(path string) *pingHistory {
+	history := &pingHistory{path: path, samples: make(map[string][]pingSample)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &history.samples); err != nil {
+			log.Printf("Warning: could not read %s: %v", path, err)
+			history.samples = make(map[string][]pingSample)
+		}
+	}
+	return history
+}
+
+// Record adds the result of a check of rawURL
+func (ph *pingHistory) Record(rawURL string, result pingResult) {
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	key := bookmarkURLKey(rawURL)
+	if _, ok := ph.samples[key]; !ok && len(ph.samples) >= pingHistoryMaxURLs {
+		ph.dropStalest()
+	}
+
+	samples := append(ph.samples[key], pingSample{Time: time.Now().UTC(), Status: result.Status, Ping: result.Ping})
+	if len(samples) > pingHistorySize {
+		samples = samples[len(samples)-pingHistorySize:]
+	}
+	ph.samples[key] = samples
+}
+
+// dropStalest removes the URL that was checked least recently. It must be called
+// with the mutex held.
+func (ph *pingHistory) dropStalest() {
+	stalestKey := ""
+	var stalest time.Time
+	for key, samples := range ph.samples {
+		last := samples[len(samples)-1].Time
+		if stalestKey == "" || last.Before(stalest) {
+			stalestKey, stalest = key, last
+		}
+	}
+	delete(ph.samples, stalestKey)
+}
+
+// Samples returns the recorded checks of rawURL, oldest first
+func (ph *pingHistory) Samples(rawURL string) []pingSample {
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	samples := ph.samples[bookmarkURLKey(rawURL)]
+	return append(make([]pingSample, 0, len(samples)), samples...)
+}
+
+// Latest returns the most recent check of rawURL
+func (ph *pingHistory) Latest(rawURL string) (pingSample, bool) {
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	samples := ph.samples[bookmarkURLKey(rawURL)]
+	if len(samples) == 0 {
+		return pingSample{}, false
+	}
+	return samples[len(samples)-1], true
+}
+
+// Save writes the history to disk
+func (ph *pingHistory) Save() error {
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	data, err := json.Marshal(ph.samples)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ph.path, data, 0644)
+}
+
+// PingHistory returns the recent checks of a status-checked bookmark's ?url=,
+// oldest first, for drawing a latency trend
+func (h *Handlers) PingHistory(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	urlParam := r.URL.Query().Get("url")
+	if urlParam == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "URL parameter is required")
+		return
+	}
+	if _, ok := h.findRegisteredBookmark(urlParam); !ok {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidURL, "URL is not a registered bookmark")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.pingHistory.Samples(urlParam))
+}
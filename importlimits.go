@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// defaultMaxBookmarksPerPage is the most bookmarks an imported page file may hold
+// unless MAX_BOOKMARKS_PER_PAGE says otherwise
+const defaultMaxBookmarksPerPage = 10000
+
+// errTooManyBookmarks is returned for imported pages over the bookmark limit
+var errTooManyBookmarks = errors.New("too many bookmarks")
+
+// loadMaxBookmarksPerPage reads the bookmark limit from MAX_BOOKMARKS_PER_PAGE
+func loadMaxBookmarksPerPage() int {
+	limit := defaultMaxBookmarksPerPage
+	if value := os.Getenv("MAX_BOOKMARKS_PER_PAGE"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			limit = parsed
+		} else {
+			log.Printf("Warning: invalid MAX_BOOKMARKS_PER_PAGE %q, using %d", value, limit)
+		}
+	}
+	return limit
+}
+
+// decodeImportedPage parses an imported bookmarks-N.json, rejecting files that
+// aren't shaped like a page or that hold more than limit bookmarks. The bookmarks
+// are counted before they are decoded, so an oversized file is turned away early.
+func decodeImportedPage(content []byte, limit int) (PageWithBookmarks, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return PageWithBookmarks{}, errors.New("not a JSON object")
+	}
+	for _, name := range []string{"page", "bookmarks"} {
+		if value, ok := fields[name]; !ok || string(value) == "null" {
+			return PageWithBookmarks{}, fmt.Errorf("missing %q", name)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(fields["bookmarks"]))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return PageWithBookmarks{}, errors.New(`"bookmarks" is not a list`)
+	}
+	for count := 0; decoder.More(); count++ {
+		if count == limit {
+			return PageWithBookmarks{}, errTooManyBookmarks
+		}
+		var skipped json.RawMessage
+		if err := decoder.Decode(&skipped); err != nil {
+			return PageWithBookmarks{}, err
+		}
+	}
+
+	var page PageWithBookmarks
+	if err := json.Unmarshal(content, &page); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return PageWithBookmarks{}, fmt.Errorf("%q has the wrong type", typeErr.Field)
+		}
+		return PageWithBookmarks{}, err
+	}
+	if page.Page.Favicon != "" && !pageFaviconPattern.MatchString(page.Page.Favicon) {
+		return PageWithBookmarks{}, errors.New(`"page.favicon" is not a page favicon`)
+	}
+	return page, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStatusBody caps how much of a status endpoint's response is read
+const maxStatusBody = 1 << 20
+
+// defaultStatusFields are the top-level fields read when a bookmark has no
+// StatusPath, in order. They cover the usual health endpoints: Spring Boot and
+// Gatus ({"status": "UP"}), the IETF health check draft ({"status": "pass"}),
+// Uptime Kuma heartbeats ({"status": 1}) and plain {"healthy": true} replies.
+var defaultStatusFields = []string{"status", "state", "health", "healthy", "up", "ok"}
+
+// upStatusValues are the strings that mean a service is up, compared ignoring case
+var upStatusValues = map[string]bool{
+	"up": true, "ok": true, "online": true, "healthy": true, "pass": true,
+	"passing": true, "operational": true, "green": true, "running": true,
+}
+
+// statusPath is a parsed StatusPath: a path into a JSON document, optionally
+// compared with a JSON value, such as `.status == "up"` or `.data[0].healthy`
+type statusPath struct {
+	Steps    []interface{} // Object keys (string) and array indexes (int)
+	Operator string        // "==", "!=" or "" to test the value like the defaults do
+	Value    interface{}   // Decoded JSON value to compare with
+}
+
+// parseStatusPath parses a StatusPath expression
+func parseStatusPath(expr string) (statusPath, error) {
+	var path statusPath
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, ".") && !strings.HasPrefix(expr, "[") {
+		return path, fmt.Errorf("path must start with . or [")
+	}
+
+	i := 0
+	for i < len(expr) && expr[i] != ' ' && expr[i] != '=' && expr[i] != '!' {
+		switch expr[i] {
+		case '.':
+			end := i + 1
+			for end < len(expr) && !strings.ContainsRune(".[ =!", rune(expr[end])) {
+				end++
+			}
+			if end == i+1 {
+				if end == len(expr) && len(path.Steps) == 0 {
+					i = end // "." is the whole document
+					continue
+				}
+				return path, fmt.Errorf("empty key at position %d", i+1)
+			}
+			path.Steps = append(path.Steps, expr[i+1:end])
+			i = end
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return path, fmt.Errorf("missing ] after position %d", i+1)
+			}
+			index, err := strconv.Atoi(expr[i+1 : i+end])
+			if err != nil || index < 0 {
+				return path, fmt.Errorf("invalid array index %q", expr[i+1:i+end])
+			}
+			path.Steps = append(path.Steps, index)
+			i += end + 1
+		default:
+			return path, fmt.Errorf("unexpected %q at position %d", expr[i], i+1)
+		}
+	}
+
+	rest := strings.TrimSpace(expr[i:])
+	if rest == "" {
+		return path, nil
+	}
+	switch {
+	case strings.HasPrefix(rest, "=="):
+		path.Operator = "=="
+	case strings.HasPrefix(rest, "!="):
+		path.Operator = "!="
+	default:
+		return path, fmt.Errorf("expected == or != after the path")
+	}
+	literal := strings.TrimSpace(rest[2:])
+	if err := json.Unmarshal([]byte(literal), &path.Value); err != nil || literal == "" {
+		return path, fmt.Errorf("the compared value must be JSON, such as \"up\", 1 or true")
+	}
+	return path, nil
+}
+
+// lookup follows the path into doc
+func (p statusPath) lookup(doc interface{}) (interface{}, bool) {
+	value := doc
+	for _, step := range p.Steps {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[step]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || step >= len(array) {
+				return nil, false
+			}
+			value = array[step]
+		}
+	}
+	return value, true
+}
+
+// Up reports whether doc describes a service that is up. A missing value is down
+// for == and plain paths, and up for !=.
+func (p statusPath) Up(doc interface{}) bool {
+	value, found := p.lookup(doc)
+	switch p.Operator {
+	case "==":
+		return found && reflect.DeepEqual(value, p.Value)
+	case "!=":
+		return !found || !reflect.DeepEqual(value, p.Value)
+	}
+	return found && statusValueUp(value)
+}
+
+// statusValueUp interprets a status value: true, 1 and the upStatusValues are up
+func statusValueUp(value interface{}) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case float64:
+		return value == 1
+	case string:
+		return upStatusValues[strings.ToLower(strings.TrimSpace(value))]
+	}
+	return false
+}
+
+// jsonStatusUp reports whether a status endpoint's response body says the service
+// is up, reading pathExpr or else the first of the defaultStatusFields present
+func jsonStatusUp(body []byte, pathExpr string) bool {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return false
+	}
+
+	if pathExpr != "" {
+		path, err := parseStatusPath(pathExpr)
+		return err == nil && path.Up(doc)
+	}
+
+	if object, ok := doc.(map[string]interface{}); ok {
+		for _, field := range defaultStatusFields {
+			if value, ok := object[field]; ok {
+				return statusValueUp(value)
+			}
+		}
+	}
+	return false
+}
+
+// probeJSONStatus fetches a status endpoint and reads the service status from its
+// JSON response. Only 2xx responses are read, anything else is offline.
+func probeJSONStatus(targetURL string, opts pingOptions, start time.Time) pingResult {
+	resp, err := pingRequest("GET", targetURL, opts)
+	if err != nil {
+		return offlineResult()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return withResponse(offlineResult(), resp)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxStatusBody))
+	if err != nil || !jsonStatusUp(body, opts.StatusPath) {
+		return withResponse(offlineResult(), resp)
+	}
+	return withResponse(onlineResult(start), resp)
+}
+
+// validateStatusPath checks the syntax of a bookmark's StatusPath
+func validateStatusPath(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	_, err := parseStatusPath(expr)
+	return err
+}
+
+// usesJSONStatus reports whether a bookmark's status comes from its StatusURL
+func usesJSONStatus(bookmark Bookmark) bool {
+	return bookmark.CheckStatus && bookmark.StatusURL != ""
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	maxColumnsPerRow        = 12   // Largest column count the dashboard grid accepts
+	defaultMobileBreakpoint = 767  // px, matches the single column layout in responsive.css
+	defaultWideBreakpoint   = 1920 // px
+)
+
+// fontSizes are the FontSize values the dashboard has styles for
+var fontSizes = []string{"xs", "s", "sm", "m", "lg", "l", "xl"}
+
+// legacyFontSizes maps the FontSize values of older versions to current ones
+var legacyFontSizes = map[string]string{"small": "sm", "medium": "m", "large": "l"}
+
+// normalizeFontSize returns the current name of a font size, accepting the legacy
+// names. An empty value is the default size.
+func normalizeFontSize(fontSize string) (string, error) {
+	fontSize = strings.ToLower(strings.TrimSpace(fontSize))
+	if fontSize == "" {
+		return "m", nil
+	}
+	if current, ok := legacyFontSizes[fontSize]; ok {
+		return current, nil
+	}
+	for _, known := range fontSizes {
+		if fontSize == known {
+			return fontSize, nil
+		}
+	}
+	return "", fmt.Errorf("fontSize must be one of %s", strings.Join(fontSizes, ", "))
+}
+
+// validateDisplaySettings checks the settings that shape the dashboard grid and
+// text, normalizing FontSize in place
+func validateDisplaySettings(settings *Settings) error {
+	if settings.ColumnsPerRow < 1 || settings.ColumnsPerRow > maxColumnsPerRow {
+		return fmt.Errorf("columnsPerRow must be between 1 and %d", maxColumnsPerRow)
+	}
+	fontSize, err := normalizeFontSize(settings.FontSize)
+	if err != nil {
+		return err
+	}
+	settings.FontSize = fontSize
+	return validateColumnLayout(*settings)
+}
+
+// validateColumnLayout checks the responsive column settings. The mobile and wide
+// column counts may be 0 to leave that breakpoint alone, as may the breakpoints
+// themselves to use the defaults.
+func validateColumnLayout(settings Settings) error {
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"columnsPerRowMobile", settings.ColumnsPerRowMobile},
+		{"columnsPerRowWide", settings.ColumnsPerRowWide},
+	} {
+		if field.value < 0 || field.value > maxColumnsPerRow {
+			return fmt.Errorf("%s must be between 1 and %d, or 0 to leave it unset", field.name, maxColumnsPerRow)
+		}
+	}
+
+	if settings.MobileBreakpoint < 0 || settings.WideBreakpoint < 0 {
+		return fmt.Errorf("breakpoints can't be negative")
+	}
+	mobile, wide := settings.MobileBreakpoint, settings.WideBreakpoint
+	if mobile == 0 {
+		mobile = defaultMobileBreakpoint
+	}
+	if wide == 0 {
+		wide = defaultWideBreakpoint
+	}
+	if mobile >= wide {
+		return fmt.Errorf("mobileBreakpoint (%dpx) must be below wideBreakpoint (%dpx)", mobile, wide)
+	}
+	return nil
+}
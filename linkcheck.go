@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	linkCheckMaxRedirects = 10
+	linkCheckConcurrency  = 8
+	linkCheckTimeout      = 10 * time.Second
+)
+
+// Link check statuses, from fine to broken
+const (
+	linkOK          = "ok"          // Answers 2xx at the stored URL
+	linkRedirected  = "redirected"  // Answers 2xx after redirects to another URL
+	linkDead        = "dead"        // 404 Not Found or 410 Gone
+	linkHTTPError   = "http-error"  // Any other 4xx or 5xx
+	linkTLSError    = "tls-error"   // The certificate doesn't verify
+	linkUnreachable = "unreachable" // DNS, connection or timeout failure
+	linkSkipped     = "skipped"     // Not an http(s) URL
+)
+
+// errTooManyRedirects stops a link check that keeps being redirected
+var errTooManyRedirects = errors.New("too many redirects")
+
+// linkCheckResult is the report for one bookmark in GET /api/linkcheck
+type linkCheckResult struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Status    string `json:"status"`              // One of the link check statuses
+	HTTPCode  int    `json:"httpCode,omitempty"`  // Status code of the last response
+	FinalURL  string `json:"finalUrl,omitempty"`  // Where the redirects ended, when they did
+	Redirects int    `json:"redirects,omitempty"` // Number of redirects followed
+	Error     string `json:"error,omitempty"`     // Why the request failed
+}
+
+// linkCheckReport is the body of GET /api/linkcheck
+type linkCheckReport struct {
+	Page    int               `json:"page"`
+	Summary map[string]int    `json:"summary"` // Number of bookmarks per status
+	Results []linkCheckResult `json:"results"`
+}
+
+// isTLSVerificationError reports whether err means the server's certificate was
+// rejected, as opposed to the server not being reachable at all
+func isTLSVerificationError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verificationErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// checkLink requests a bookmark's URL with certificate verification on, following
+// up to linkCheckMaxRedirects redirects, and classifies the outcome
+func (h *Handlers) checkLink(bookmark Bookmark, userAgent string) linkCheckResult {
+	result := linkCheckResult{Name: bookmark.Name, URL: bookmark.URL}
+	parsedURL, err := url.Parse(bookmark.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		result.Status = linkSkipped
+		return result
+	}
+
+	h.pingLimit.Acquire()
+	defer h.pingLimit.Release()
+
+	if userAgent == "" {
+		userAgent = "ThinkDashboard-LinkCheck/1.0"
+	}
+
+	client := &http.Client{
+		Timeout: linkCheckTimeout,
+		Transport: &http.Transport{
+			DialContext:         h.pingPolicy.dialer(5 * time.Second).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > linkCheckMaxRedirects {
+				return errTooManyRedirects
+			}
+			return nil
+		},
+	}
+	request := func(method string) (*http.Response, error) {
+		req, err := http.NewRequest(method, bookmark.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		for name, value := range bookmark.PingHeaders {
+			req.Header.Set(name, value)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		return resp, nil
+	}
+
+	resp, err := request("HEAD")
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusForbidden) {
+		resp, err = request("GET")
+	}
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err // The URL is already in the report
+		}
+		result.Error = err.Error()
+		switch {
+		case isTLSVerificationError(err):
+			result.Status = linkTLSError
+		case errors.Is(err, errTooManyRedirects):
+			result.Status = linkHTTPError
+		default:
+			result.Status = linkUnreachable
+		}
+		return result
+	}
+
+	result.HTTPCode = resp.StatusCode
+	for r := resp.Request; r.Response != nil; r = r.Response.Request {
+		result.Redirects++
+	}
+	if result.Redirects > 0 {
+		result.FinalURL = resp.Request.URL.String()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		result.Status = linkDead
+	case resp.StatusCode >= 400:
+		result.Status = linkHTTPError
+	case result.FinalURL != "" && !sameBookmarkURL(result.FinalURL, bookmark.URL):
+		result.Status = linkRedirected
+	default:
+		result.Status = linkOK
+	}
+	return result
+}
+
+// LinkCheck checks every bookmark of ?page= for redirects, dead links and
+// certificate problems without changing anything. Unlike status checks it verifies
+// certificates and follows redirects to report where a link ends up.
+func (h *Handlers) LinkCheck(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+	if !h.store.PageExists(pageID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	userAgent := h.store.GetSettings().PingUserAgent
+	bookmarks := h.store.GetBookmarksByPage(pageID)
+	results := make([]linkCheckResult, len(bookmarks))
+	semaphore := make(chan struct{}, linkCheckConcurrency)
+	var wg sync.WaitGroup
+	for i, bookmark := range bookmarks {
+		wg.Add(1)
+		go func(i int, bookmark Bookmark) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[i] = h.checkLink(bookmark, userAgent)
+		}(i, bookmark)
+	}
+	wg.Wait()
+
+	report := linkCheckReport{Page: pageID, Summary: make(map[string]int), Results: results}
+	for _, result := range results {
+		report.Summary[result.Status]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// localesDir holds the translation files, one <lang>.json per language
+const localesDir = "locales"
+
+// baseLocale is the language other translations fall back to
+const baseLocale = "en"
+
+// localePattern matches language codes such as "en", "jp" or "pt-BR"
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+
+// availableLocales lists the language codes with a translation file
+func availableLocales() []string {
+	matches, _ := filepath.Glob(filepath.Join(localesDir, "*.json"))
+	locales := []string{}
+	for _, match := range matches {
+		if lang := strings.TrimSuffix(filepath.Base(match), ".json"); localePattern.MatchString(lang) {
+			locales = append(locales, lang)
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localeAvailable reports whether lang is one of availableLocales
+func localeAvailable(lang string) bool {
+	for _, available := range availableLocales() {
+		if available == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLocale reads the translations of an available language
+func loadLocale(lang string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filepath.Join(localesDir, lang+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var translations map[string]interface{}
+	if err := json.Unmarshal(data, &translations); err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+// mergeLocale returns base with the keys of translations replacing its own,
+// section by section, so keys missing from translations keep their base text
+func mergeLocale(base, translations map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range translations {
+		baseSection, baseIsSection := merged[key].(map[string]interface{})
+		section, isSection := value.(map[string]interface{})
+		if baseIsSection && isSection {
+			merged[key] = mergeLocale(baseSection, section)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// Locales lists the available language codes
+func (h *Handlers) Locales(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availableLocales())
+}
+
+// Locale returns the translations of a language merged over the English ones, so
+// keys it doesn't translate yet fall back to English
+func (h *Handlers) Locale(w http.ResponseWriter, r *http.Request) {
+	lang := mux.Vars(r)["lang"]
+	if !localePattern.MatchString(lang) || !localeAvailable(lang) {
+		writeJSONError(w, http.StatusNotFound, codeNotFound, "Language not found")
+		return
+	}
+
+	translations, err := loadLocale(lang)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error reading translations")
+		return
+	}
+	if lang != baseLocale {
+		if base, err := loadLocale(baseLocale); err == nil {
+			translations = mergeLocale(base, translations)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(translations)
+}
+
+// validateLocale checks that translations has the structure of the base language:
+// sections of text keyed like the base ones. Keys may be left out, but unknown
+// sections and keys are rejected since they're most likely typos.
+func validateLocale(base, translations map[string]interface{}) error {
+	if len(translations) == 0 {
+		return fmt.Errorf("no translations")
+	}
+	for sectionName, value := range translations {
+		baseSection, ok := base[sectionName].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unknown section %q", sectionName)
+		}
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("section %q must be an object", sectionName)
+		}
+		for key, text := range section {
+			if _, ok := baseSection[key]; !ok {
+				return fmt.Errorf("unknown key %q", sectionName+"."+key)
+			}
+			if _, ok := text.(string); !ok {
+				return fmt.Errorf("%q must be a string", sectionName+"."+key)
+			}
+		}
+	}
+	return nil
+}
+
+// UploadLocale installs the translations in the JSON body as language {lang}, so
+// new translations can be added without rebuilding. The English base can't be
+// replaced since every other language is checked against it.
+func (h *Handlers) UploadLocale(w http.ResponseWriter, r *http.Request) {
+	lang := mux.Vars(r)["lang"]
+	if !localePattern.MatchString(lang) {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid language code")
+		return
+	}
+	if lang == baseLocale {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "The base language can't be replaced")
+		return
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20)) // 1MB max
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to read translations")
+		return
+	}
+	var translations map[string]interface{}
+	if err := json.Unmarshal(content, &translations); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+
+	base, err := loadLocale(baseLocale)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error reading base translations")
+		return
+	}
+	if err := validateLocale(base, translations); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid translations: %v", err))
+		return
+	}
+
+	data, _ := json.MarshalIndent(translations, "", "    ")
+	if err := os.MkdirAll(localesDir, 0755); err == nil {
+		err = os.WriteFile(filepath.Join(localesDir, lang+".json"), data, 0644)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to save translations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"log"
 	"mime"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -16,6 +20,14 @@ import (
 var embeddedFiles embed.FS
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize MIME types
 	mime.AddExtensionType(".css", "text/css")
 	mime.AddExtensionType(".js", "application/javascript")
@@ -28,39 +40,106 @@ func main() {
 
 	// Create router
 	r := mux.NewRouter()
+	if handlers.readOnly {
+		log.Printf("Read-only mode: write endpoints are disabled")
+		r.Use(readOnlyMiddleware)
+	}
+	if handlers.devices != nil {
+		log.Printf("Per-device settings: settings and colors are saved for each device")
+		r.Use(deviceMiddleware)
+	}
+	if handlers.headHTML {
+		log.Printf("Custom head HTML: data/head.html is added to the dashboard as is")
+	}
+	go handlers.monitorStatuses(envDuration("STATUS_MONITOR_INTERVAL", defaultStatusMonitorInterval))
 
 	// Routes
 	r.HandleFunc("/", handlers.Dashboard).Methods("GET")
+	r.HandleFunc("/go", handlers.Go).Methods("GET")
 	r.HandleFunc("/config", handlers.Config).Methods("GET")
 	r.HandleFunc("/colors", handlers.Colors).Methods("GET")
+	r.HandleFunc("/manifest.webmanifest", handlers.Manifest).Methods("GET")
+	r.HandleFunc("/sw.js", handlers.ServiceWorker).Methods("GET")
 	r.HandleFunc("/api/bookmarks", handlers.GetBookmarks).Methods("GET")
 	r.HandleFunc("/api/bookmarks", handlers.SaveBookmarks).Methods("POST")
 	r.HandleFunc("/api/bookmarks", handlers.DeleteBookmark).Methods("DELETE")
 	r.HandleFunc("/api/bookmarks/add", handlers.AddBookmark).Methods("POST")
+	r.HandleFunc("/api/bookmarks/pinned", handlers.GetPinnedBookmarks).Methods("GET")
+	r.HandleFunc("/api/bookmarks/assign-category", handlers.AssignCategory).Methods("POST")
+	r.HandleFunc("/api/bookmarks/validate", handlers.ValidateBookmarks).Methods("POST")
+	r.HandleFunc("/api/shortcuts/resolve", handlers.ResolveShortcut).Methods("GET")
+	r.HandleFunc("/api/palette", handlers.Palette).Methods("GET")
+	r.HandleFunc("/api/search", handlers.Search).Methods("GET")
+	r.HandleFunc("/api/locales", handlers.Locales).Methods("GET")
+	r.HandleFunc("/api/locales/{lang}", handlers.Locale).Methods("GET")
+	r.HandleFunc("/api/locales/{lang}", handlers.UploadLocale).Methods("POST")
 	r.HandleFunc("/api/finders", handlers.GetFinders).Methods("GET")
 	r.HandleFunc("/api/finders", handlers.SaveFinders).Methods("POST")
 	r.HandleFunc("/api/categories", handlers.GetCategories).Methods("GET")
 	r.HandleFunc("/api/categories", handlers.SaveCategories).Methods("POST")
+	r.HandleFunc("/api/categories/collapse", handlers.CollapseCategory).Methods("POST")
+	r.HandleFunc("/api/categories/repair", handlers.RepairCategories).Methods("POST")
+	r.HandleFunc("/api/categories/reorder", handlers.ReorderCategories).Methods("POST")
+	r.HandleFunc("/api/categories/{id}", handlers.DeleteCategory).Methods("DELETE")
 	r.HandleFunc("/api/pages", handlers.GetPages).Methods("GET")
 	r.HandleFunc("/api/pages", handlers.SavePages).Methods("POST")
+	r.HandleFunc("/api/pages/merge", handlers.MergePages).Methods("POST")
 	r.HandleFunc("/api/pages/{id:[0-9]+}", handlers.DeletePage).Methods("DELETE")
+	r.HandleFunc("/api/pages/{id:[0-9]+}", handlers.RenamePage).Methods("PATCH")
+	r.HandleFunc("/api/pages/{id:[0-9]+}/full", handlers.GetPageFull).Methods("GET")
+	r.HandleFunc("/api/pages/{id:[0-9]+}/clear", handlers.ClearPage).Methods("POST")
+	r.HandleFunc("/api/pages/{id:[0-9]+}/favicon", handlers.PageFavicon).Methods("GET")
+	r.HandleFunc("/api/pages/{id:[0-9]+}/favicon", handlers.UploadPageFavicon).Methods("POST")
+	r.HandleFunc("/api/pages/{id:[0-9]+}/favicon", handlers.DeletePageFavicon).Methods("DELETE")
+	r.HandleFunc("/api/snapshot", handlers.Snapshot).Methods("GET")
+	r.HandleFunc("/api/all", handlers.All).Methods("GET")
+	r.HandleFunc("/api/sitemap.json", handlers.Sitemap).Methods("GET")
 	r.HandleFunc("/api/settings", handlers.GetSettings).Methods("GET")
 	r.HandleFunc("/api/settings", handlers.SaveSettings).Methods("POST")
+	r.HandleFunc("/api/favicon", handlers.Favicon).Methods("GET")
 	r.HandleFunc("/api/favicon", handlers.UploadFavicon).Methods("POST")
 	r.HandleFunc("/api/font", handlers.UploadFont).Methods("POST")
+	r.HandleFunc("/api/fonts", handlers.GetFonts).Methods("GET")
+	r.HandleFunc("/api/fonts/{id:[0-9a-f]+}", handlers.DeleteFont).Methods("DELETE")
 	r.HandleFunc("/api/icon", handlers.UploadIcon).Methods("POST")
+	r.HandleFunc("/api/icon/letter", handlers.LetterIcon).Methods("GET")
+	r.HandleFunc("/api/favicon/proxy", handlers.FaviconProxy).Methods("GET")
 	r.HandleFunc("/api/colors", handlers.GetColors).Methods("GET")
 	r.HandleFunc("/api/colors", handlers.SaveColors).Methods("POST")
 	r.HandleFunc("/api/colors/reset", handlers.ResetColors).Methods("POST")
 	r.HandleFunc("/api/colors/custom-themes", handlers.GetCustomThemesList).Methods("GET")
+	r.HandleFunc("/api/colors/custom-themes/reorder", handlers.ReorderCustomThemes).Methods("POST")
+	r.HandleFunc("/api/colors/custom-themes/{id}", handlers.RenameCustomTheme).Methods("PATCH")
 	r.HandleFunc("/api/theme.css", handlers.CustomThemeCSS).Methods("GET")
-	r.HandleFunc("/api/backup", handlers.Backup).Methods("GET")
-	r.HandleFunc("/api/import", handlers.Import).Methods("POST")
-	r.HandleFunc("/api/ping", handlers.PingURL).Methods("GET")
+	r.HandleFunc("/api/font.css", handlers.FontCSS).Methods("GET")
+	r.HandleFunc("/api/themepack/export", handlers.ExportThemePack).Methods("GET")
+	r.HandleFunc("/api/themepack/import", handlers.ImportThemePack).Methods("POST")
+	r.HandleFunc("/api/backup", withoutDeadlines(handlers.Backup)).Methods("GET")
+	r.HandleFunc("/api/import", withoutDeadlines(handlers.Import)).Methods("POST")
+	r.HandleFunc("/api/reset", withoutDeadlines(handlers.Reset)).Methods("POST")
+	r.HandleFunc("/api/export/csv", handlers.ExportCSV).Methods("GET")
+	r.HandleFunc("/api/import/csv", handlers.ImportCSV).Methods("POST")
+	r.HandleFunc("/api/export/opml", handlers.ExportOPML).Methods("GET")
+	r.HandleFunc("/api/import/opml", handlers.ImportOPML).Methods("POST")
+	r.HandleFunc("/api/import/pocket", handlers.ImportPocket).Methods("POST")
+	r.HandleFunc("/api/ping", withoutDeadlines(handlers.PingURL)).Methods("GET")
+	r.HandleFunc("/api/ping/history", handlers.PingHistory).Methods("GET")
+	r.HandleFunc("/api/ping/rollup", withoutDeadlines(handlers.PingRollup)).Methods("GET")
+	r.HandleFunc("/api/linkcheck", withoutDeadlines(handlers.LinkCheck)).Methods("GET")
+	r.HandleFunc("/api/qr", handlers.QRCode).Methods("GET")
+	r.HandleFunc("/api/og-image", handlers.OGImage).Methods("GET")
+	r.HandleFunc("/api/events", withoutDeadlines(handlers.Events)).Methods("GET")
+	r.HandleFunc("/api/diagnostics", handlers.Diagnostics).Methods("GET")
+	r.HandleFunc("/api/storage", handlers.Storage).Methods("GET")
+	r.HandleFunc("/api/audit", handlers.Audit).Methods("GET")
+	r.HandleFunc("/api/version", handlers.Version).Methods("GET")
+	r.HandleFunc("/api/capabilities", handlers.Capabilities).Methods("GET")
+	r.HandleFunc("/api/update", handlers.Update).Methods("GET")
 	r.HandleFunc("/health", handlers.Health).Methods("GET")
+	r.HandleFunc("/api/routes", handlers.Routes).Methods("GET")
 
-	// Data files (for uploaded favicons, etc.)
-	r.PathPrefix("/data/").Handler(http.StripPrefix("/data/", http.FileServer(http.Dir("data/"))))
+	// Uploaded favicons, fonts and icons (but not the data files themselves)
+	r.PathPrefix("/data/").Handler(http.StripPrefix("/data/", dataFileHandler("data")))
 
 	// Locales files
 	r.PathPrefix("/locales/").Handler(http.StripPrefix("/locales/", http.FileServer(http.Dir("locales/"))))
@@ -77,6 +156,8 @@ func main() {
 		staticHandler.ServeHTTP(w, r)
 	})))
 
+	handlers.routes = describeRoutes(r)
+
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -84,8 +165,27 @@ func main() {
 	}
 
 	log.Printf("Server starting on port %s", port)
-	log.Printf("Dashboard: http://localhost:%s", port)
-	log.Printf("Configuration: http://localhost:%s/config", port)
+	log.Printf("Dashboard: http://localhost:%s%s/", port, handlers.basePath)
+	log.Printf("Configuration: http://localhost:%s%s/config", port, handlers.basePath)
+
+	server := newServer(":"+port, withBasePath(handlers.basePath, r), loadServerConfig())
+
+	// Save in-memory state and let requests finish on Ctrl+C or docker stop
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
 
-	log.Fatal(http.ListenAndServe(":"+port, r))
+		log.Printf("Shutting down")
+		if err := handlers.pingHistory.Save(); err != nil {
+			log.Printf("Warning: could not save ping history: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
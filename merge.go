@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// mergeCategories adds the source categories to the target ones. A source category
+// with the ID and name of a target category is merged into it; one whose ID is
+// taken by a differently named category gets a new ID. It returns the combined
+// categories and the new ID of each remapped source category.
+func mergeCategories(target, source []Category) ([]Category, map[string]string) {
+	merged := append([]Category{}, target...)
+	byID := make(map[string]Category)
+	taken := make(map[string]bool)
+	for _, category := range target {
+		byID[category.ID] = category
+		taken[category.ID] = true
+	}
+
+	remapped := make(map[string]string)
+	for _, category := range source {
+		existing, ok := byID[category.ID]
+		if ok && strings.EqualFold(existing.Name, category.Name) {
+			continue
+		}
+		if ok {
+			newID := newCategoryID(taken)
+			remapped[category.ID] = newID
+			category.ID = newID
+		}
+		category.OriginalID = ""
+		byID[category.ID] = category
+		taken[category.ID] = true
+		merged = append(merged, category)
+	}
+	return merged, remapped
+}
+
+// mergePageInto returns target with the categories and bookmarks of source
+// appended, the bookmarks following their categories' new IDs
+func mergePageInto(target, source PageWithBookmarks) PageWithBookmarks {
+	categories, remapped := mergeCategories(target.Categories, source.Categories)
+	bookmarks := append([]Bookmark{}, target.Bookmarks...)
+	for _, bookmark := range source.Bookmarks {
+		if newID, ok := remapped[bookmark.Category]; ok {
+			bookmark.Category = newID
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	target.Categories = categories
+	target.Bookmarks = bookmarks
+	return target
+}
+
+// withoutPage returns order without pageID
+func withoutPage(order []int, pageID int) []int {
+	kept := make([]int, 0, len(order))
+	for _, id := range order {
+		if id != pageID {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// mergeWarnings reports each bookmark at or after index moved whose shortcut is
+// already used by an earlier bookmark
+func mergeWarnings(bookmarks []Bookmark, moved int, shortcutKey func(string) string) []string {
+	warnings := []string{}
+	shortcutOwners := make(map[string]string)
+	for i, bookmark := range bookmarks {
+		if bookmark.Shortcut == "" {
+			continue
+		}
+		key := shortcutKey(bookmark.Shortcut)
+		if owner, ok := shortcutOwners[key]; ok && i >= moved {
+			warnings = append(warnings, fmt.Sprintf("shortcut %q of %q is also used by %q", bookmark.Shortcut, bookmark.Name, owner))
+		} else if !ok {
+			shortcutOwners[key] = bookmark.Name
+		}
+	}
+	return warnings
+}
+
+// MergePages moves the categories and bookmarks of {"sourceId"} into
+// {"targetId"} and deletes the source page. Shortcuts the two pages have in common
+// are kept and reported as warnings. It returns the merged page.
+func (h *Handlers) MergePages(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SourceID int `json:"sourceId"`
+		TargetID int `json:"targetId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+	if request.SourceID == request.TargetID {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Source and target must be different pages")
+		return
+	}
+	if request.SourceID == 1 {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Cannot delete the main page")
+		return
+	}
+	if !h.store.PageExists(request.SourceID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Source page not found")
+		return
+	}
+	if !h.store.PageExists(request.TargetID) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Target page not found")
+		return
+	}
+
+	if err := validateShortcuts(h.store.GetBookmarksByPage(request.SourceID), h.settingsFor(r)); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidShortcut, fmt.Sprintf("Invalid shortcut on the source page: %v", err))
+		return
+	}
+
+	target, err := h.store.MergePages(request.SourceID, request.TargetID)
+	if errors.Is(err, errPageNotFound) {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+	if err != nil {
+		log.Printf("MergePages: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error merging pages")
+		return
+	}
+
+	// Dashboards that were showing the source page move to the target
+	if settings := h.store.GetSettings(); settings.CurrentPage == request.SourceID {
+		settings.CurrentPage = request.TargetID
+		h.store.SaveSettings(settings)
+		h.events.Publish("settings", 0)
+	}
+
+	h.events.Publish("categories", request.TargetID)
+	h.events.Publish("bookmarks", request.TargetID)
+	h.events.Publish("pages", 0)
+
+	merged, err := h.store.GetPageWithBookmarks(request.TargetID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error reading merged page")
+		return
+	}
+	h.audit.Record(r, append(bookmarkChanges(request.TargetID, target.Bookmarks, merged.Bookmarks),
+		auditEntry{Action: "delete-page", Page: request.SourceID})...)
+
+	warnings := mergeWarnings(merged.Bookmarks, len(target.Bookmarks), shortcutKeyFunc(h.settingsFor(r)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"page": merged, "warnings": warnings})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// metaFilter returns the meta.<key>=<value> query parameters as key/value pairs
+func metaFilter(query url.Values) map[string]string {
+	filter := make(map[string]string)
+	for param, values := range query {
+		if key, ok := strings.CutPrefix(param, "meta."); ok && key != "" && len(values) > 0 {
+			filter[key] = values[0]
+		}
+	}
+	return filter
+}
+
+// metaBookmarks returns the bookmarks whose Meta has every key of the filter with
+// the same value
+func metaBookmarks(bookmarks []Bookmark, filter map[string]string) []Bookmark {
+	matching := make([]Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		matches := true
+		for key, value := range filter {
+			if actual, ok := bookmark.Meta[key]; !ok || actual != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			matching = append(matching, bookmark)
+		}
+	}
+	return matching
+}
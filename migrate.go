@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// runMigrate implements the "migrate" subcommand, which copies all data between
+// the JSON data directory and the SQLite database:
+//
+//	thinkdashboard migrate --to sqlite [--data data] [--db data/thinkdashboard.db] [--force]
+//	thinkdashboard migrate --to json   [--data data] [--db data/thinkdashboard.db] [--force]
+func runMigrate(args []string) error {
+	defaultDBPath := os.Getenv("DB_PATH")
+	if defaultDBPath == "" {
+		defaultDBPath = "data/thinkdashboard.db"
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := flags.String("to", "", "target storage backend: sqlite or json")
+	dataDir := flags.String("data", "data", "JSON data directory")
+	dbPath := flags.String("db", defaultDBPath, "SQLite database path")
+	force := flags.Bool("force", false, "overwrite a target that already contains data")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fileStore := newFileStore(*dataDir)
+	sqliteStore, err := openSQLiteStore(*dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	var src, dst Store
+	var targetEmpty bool
+	switch *to {
+	case "sqlite":
+		if !fileStoreHasData(*dataDir) {
+			return fmt.Errorf("no JSON data found in %s", *dataDir)
+		}
+		src, dst = fileStore, sqliteStore
+		targetEmpty = sqliteStore.isEmpty()
+	case "json":
+		if sqliteStore.isEmpty() {
+			return fmt.Errorf("no data found in %s", *dbPath)
+		}
+		src, dst = sqliteStore, fileStore
+		targetEmpty = !fileStoreHasData(*dataDir)
+	default:
+		return fmt.Errorf("--to must be either sqlite or json")
+	}
+
+	if !targetEmpty && !*force {
+		return fmt.Errorf("target already contains data, use --force to overwrite it")
+	}
+
+	copyStoreData(src, dst)
+
+	// Round-trip check: everything read back from the target must match the source
+	if problems := compareStoreData(src, dst); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "mismatch: %s\n", problem)
+		}
+		return fmt.Errorf("migration finished with %d mismatches", len(problems))
+	}
+
+	fmt.Printf("Migrated %d pages to %s\n", len(src.GetPages()), *to)
+	return nil
+}
+
+// fileStoreHasData reports whether dataDir contains any page or settings files
+func fileStoreHasData(dataDir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dataDir, "bookmarks-*.json"))
+	if len(matches) > 0 {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(dataDir, "settings.json"))
+	return err == nil
+}
+
+// copyStoreData copies every page, category, bookmark, finder, setting and color
+// from src into dst, removing pages from dst that don't exist in src
+func copyStoreData(src, dst Store) {
+	srcPages := src.GetPages()
+	srcPageIDs := make(map[int]bool)
+	for _, page := range srcPages {
+		srcPageIDs[page.ID] = true
+	}
+
+	for _, page := range dst.GetPages() {
+		if !srcPageIDs[page.ID] {
+			dst.DeletePage(page.ID)
+		}
+	}
+
+	for _, page := range srcPages {
+		// Categories go first so SavePage keeps them instead of the defaults
+		dst.SaveCategoriesByPage(page.ID, src.GetCategoriesByPage(page.ID))
+		dst.SavePage(page, src.GetBookmarksByPage(page.ID))
+	}
+	dst.SavePageOrder(src.GetPageOrder())
+	dst.SaveFinders(src.GetFinders())
+	dst.SaveSettings(src.GetSettings())
+	dst.SaveColors(src.GetColors())
+}
+
+// compareStoreData returns a description of every record that differs between a and b.
+// UpdatedAt timestamps are ignored since every write stamps them anew.
+func compareStoreData(a, b Store) []string {
+	var problems []string
+
+	if !sameElements(withoutPageTimestamps(a.GetPages()), withoutPageTimestamps(b.GetPages())) {
+		problems = append(problems, "pages")
+	}
+	if !sameElements(a.GetPageOrder(), b.GetPageOrder()) {
+		problems = append(problems, "page order")
+	}
+	for _, page := range a.GetPages() {
+		if !sameElements(a.GetCategoriesByPage(page.ID), b.GetCategoriesByPage(page.ID)) {
+			problems = append(problems, fmt.Sprintf("categories of page %d", page.ID))
+		}
+		if !sameElements(a.GetBookmarksByPage(page.ID), b.GetBookmarksByPage(page.ID)) {
+			problems = append(problems, fmt.Sprintf("bookmarks of page %d", page.ID))
+		}
+	}
+	if !sameElements(a.GetFinders(), b.GetFinders()) {
+		problems = append(problems, "finders")
+	}
+	settingsA, settingsB := a.GetSettings(), b.GetSettings()
+	settingsA.UpdatedAt, settingsB.UpdatedAt = 0, 0
+	if !reflect.DeepEqual(settingsA, settingsB) {
+		problems = append(problems, "settings")
+	}
+	colorsA, colorsB := a.GetColors(), b.GetColors()
+	colorsA.UpdatedAt, colorsB.UpdatedAt = 0, 0
+	if !reflect.DeepEqual(colorsA, colorsB) {
+		problems = append(problems, "colors")
+	}
+
+	return problems
+}
+
+func withoutPageTimestamps(pages []Page) []Page {
+	result := make([]Page, len(pages))
+	for i, page := range pages {
+		page.UpdatedAt = 0
+		result[i] = page
+	}
+	return result
+}
+
+// sameElements compares two slices element by element, treating nil and empty as equal
+func sameElements[T any](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !reflect.DeepEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
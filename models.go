@@ -1,20 +1,41 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Bookmark struct {
-	Name        string `json:"name"`
-	URL         string `json:"url"`
-	Shortcut    string `json:"shortcut"`
-	Category    string `json:"category"`
-	CheckStatus bool   `json:"checkStatus"`
-	Icon        string `json:"icon"`
+	Name             string            `json:"name"`
+	URL              string            `json:"url"`
+	Shortcut         string            `json:"shortcut"`
+	Category         string            `json:"category"`
+	Subcategory      string            `json:"subcategory,omitempty"` // Free-text group within the category
+	CheckStatus      bool              `json:"checkStatus"`
+	Icon             string            `json:"icon"`
+	HealthURL        string            `json:"healthUrl,omitempty"`        // Probed instead of URL by status checks when set
+	StatusURL        string            `json:"statusUrl,omitempty"`        // JSON status endpoint of another monitor, read instead of probing when set
+	StatusPath       string            `json:"statusPath,omitempty"`       // Where the status is in StatusURL's response, e.g. `.status == "up"`
+	StatusWebhookURL string            `json:"statusWebhookUrl,omitempty"` // Overrides Settings.StatusWebhookURL for this bookmark
+	PingHeaders      map[string]string `json:"pingHeaders,omitempty"`      // Extra headers sent with HTTP status checks
+	OpenInNewTab     *bool             `json:"openInNewTab,omitempty"`     // Overrides Settings.OpenInNewTab when set
+	Hidden           bool              `json:"hidden,omitempty"`           // Kept but left out of the dashboard, search and status checks
+	Pinned           bool              `json:"pinned,omitempty"`           // Shown in the favorites bar on every page
+	VisibleFrom      string            `json:"visibleFrom,omitempty"`      // "HH:MM" server time the bookmark starts being shown, when schedules apply
+	VisibleTo        string            `json:"visibleTo,omitempty"`        // "HH:MM" server time the bookmark stops being shown
+	VisitCount       int               `json:"visitCount,omitempty"`       // Times opened through /go
+	Meta             map[string]string `json:"meta,omitempty"`             // Free-form key/value data for external tools, filterable with ?meta.<key>=
 }
 
 type Finder struct {
@@ -27,11 +48,14 @@ type Category struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
 	OriginalID string `json:"originalId,omitempty"` // Track original ID for renames
+	Collapsed  bool   `json:"collapsed,omitempty"`  // Shown collapsed on the dashboard
 }
 
 type Page struct {
-	ID   int    `json:"id"`   // Numeric ID matching the file number (bookmarks-1.json = id: 1)
-	Name string `json:"name"` // Editable page name
+	ID        int    `json:"id"`                  // Numeric ID matching the file number (bookmarks-1.json = id: 1)
+	Name      string `json:"name"`                // Editable page name
+	UpdatedAt int64  `json:"updatedAt,omitempty"` // Unix millis of the last write to the page file
+	Favicon   string `json:"favicon,omitempty"`   // Own favicon under /data/, set through /api/pages/{id}/favicon
 }
 
 type PageWithBookmarks struct {
@@ -40,6 +64,13 @@ type PageWithBookmarks struct {
 	Bookmarks  []Bookmark `json:"bookmarks"`
 }
 
+// PageSummary is a page with the number of bookmarks and categories it holds
+type PageSummary struct {
+	Page
+	BookmarkCount int `json:"bookmarkCount"`
+	CategoryCount int `json:"categoryCount"`
+}
+
 type PageOrder struct {
 	Order []int `json:"order"` // Array of page IDs in display order
 }
@@ -49,7 +80,11 @@ type Settings struct {
 	Theme                     string `json:"theme"`       // "light" or "dark"
 	OpenInNewTab              bool   `json:"openInNewTab"`
 	ColumnsPerRow             int    `json:"columnsPerRow"`
-	FontSize                  string `json:"fontSize"` // "small", "medium", or "large"
+	ColumnsPerRowMobile       int    `json:"columnsPerRowMobile"` // Columns at or below MobileBreakpoint, 0 for the built-in responsive layout
+	ColumnsPerRowWide         int    `json:"columnsPerRowWide"`   // Columns at or above WideBreakpoint, 0 to keep ColumnsPerRow
+	MobileBreakpoint          int    `json:"mobileBreakpoint"`    // Viewport width in px, 0 for the default of 767
+	WideBreakpoint            int    `json:"wideBreakpoint"`      // Viewport width in px, 0 for the default of 1920
+	FontSize                  string `json:"fontSize"`            // "xs", "s", "sm", "m", "lg", "l" or "xl"
 	ShowBackgroundDots        bool   `json:"showBackgroundDots"`
 	ShowTitle                 bool   `json:"showTitle"`
 	ShowDate                  bool   `json:"showDate"`
@@ -64,8 +99,14 @@ type Settings struct {
 	ShowPing                  bool   `json:"showPing"`
 	ShowStatusLoading         bool   `json:"showStatusLoading"`
 	SkipFastPing              bool   `json:"skipFastPing"`
+	PingMode                  string `json:"pingMode"`                  // "tcp" (default), "head" or "get"
+	PingUserAgent             string `json:"pingUserAgent"`             // User-Agent for HTTP status checks, empty for the default
+	StatusWebhookURL          string `json:"statusWebhookUrl"`          // Receives a POST when a status-checked bookmark goes offline or back online
 	GlobalShortcuts           bool   `json:"globalShortcuts"`           // Use shortcuts from all pages
+	CaseSensitiveShortcuts    bool   `json:"caseSensitiveShortcuts"`    // Match shortcuts by exact case when resolving them on the server
 	HyprMode                  bool   `json:"hyprMode"`                  // Launcher mode for PWA usage
+	AllowCustomSchemes        bool   `json:"allowCustomSchemes"`        // Accept non-http(s) bookmark URLs such as ssh:// or file://
+	ShortcutPattern           string `json:"shortcutPattern"`           // Regular expression shortcuts must match, empty for letters and digits
 	AnimationsEnabled         bool   `json:"animationsEnabled"`         // Enable or disable animations globally
 	EnableCustomTitle         bool   `json:"enableCustomTitle"`         // Enable custom page title
 	CustomTitle               string `json:"customTitle"`               // Custom page title
@@ -75,6 +116,9 @@ type Settings struct {
 	CustomFaviconPath         string `json:"customFaviconPath"`         // Path to custom favicon file
 	EnableCustomFont          bool   `json:"enableCustomFont"`          // Enable custom font
 	CustomFontPath            string `json:"customFontPath"`            // Path to custom font file
+	HeadingFont               string `json:"headingFont"`               // ID of the uploaded font used for titles, empty for the main font
+	BodyFont                  string `json:"bodyFont"`                  // ID of the uploaded font used for everything else, overriding CustomFontPath
+	MonoFont                  string `json:"monoFont"`                  // ID of the uploaded font used for shortcuts and the search box
 	Language                  string `json:"language"`                  // Language code, e.g., "en" or "es"
 	InterleaveMode            bool   `json:"interleaveMode"`            // Interleave mode for search (/ for shortcuts, direct input for fuzzy)
 	ShowPageTabs              bool   `json:"showPageTabs"`              // Show page navigation tabs
@@ -84,12 +128,17 @@ type Settings struct {
 	KeepSearchOpenWhenEmpty   bool   `json:"keepSearchOpenWhenEmpty"`   // Keep search interface open when query is empty
 	ShowIcons                 bool   `json:"showIcons"`                 // Show bookmark icons
 	IncludeFindersInSearch    bool   `json:"includeFindersInSearch"`    // Include finders in normal search
+	RespectSchedules          bool   `json:"respectSchedules"`          // Hide bookmarks outside their VisibleFrom/VisibleTo window on the dashboard
+	RandomThemeOnLoad         bool   `json:"randomThemeOnLoad"`         // Show a random theme, built-in or custom, each time the dashboard loads
+	UpdatedAt                 int64  `json:"updatedAt,omitempty"`       // Unix millis of the last save
 }
 
 type ColorTheme struct {
-	Light  ThemeColors            `json:"light"`
-	Dark   ThemeColors            `json:"dark"`
-	Custom map[string]ThemeColors `json:"custom"` // Custom themes with dynamic keys
+	Light     ThemeColors            `json:"light"`
+	Dark      ThemeColors            `json:"dark"`
+	Custom    map[string]ThemeColors `json:"custom"`              // Custom themes with dynamic keys
+	Order     []string               `json:"order,omitempty"`     // Display order of the custom theme IDs, see customThemeIDs
+	UpdatedAt int64                  `json:"updatedAt,omitempty"` // Unix millis of the last save
 }
 
 type ThemeColors struct {
@@ -108,25 +157,71 @@ type ThemeColors struct {
 	AccentError         string `json:"accentError"`
 }
 
+// errBookmarkNotFound is returned when no bookmark on the page has the name and URL
+var errBookmarkNotFound = errors.New("bookmark not found")
+
+// errVersionMismatch is returned by a conditional save when the page changed, or
+// doesn't exist
+var errVersionMismatch = errors.New("page version mismatch")
+
+// errPageNotFound is returned when a page a write depends on doesn't exist
+var errPageNotFound = errors.New("page not found")
+
+// errCategoryNotFound is returned when a category ID isn't on the page
+var errCategoryNotFound = errors.New("category not found")
+
+// errCategoryOrder is returned when a new category order doesn't list exactly the
+// page's categories
+var errCategoryOrder = errors.New("category IDs don't match the page's categories")
+
+// errCategoryInUse is returned when deleting a category that still has bookmarks
+// without saying where they should go
+var errCategoryInUse = errors.New("category has bookmarks")
+
 type Store interface {
 	// Bookmarks - per page only
 	GetBookmarksByPage(pageID int) []Bookmark
 	GetAllBookmarks() []Bookmark
+	GetBookmarksRange(offset, limit int) ([]Bookmark, int) // Bookmarks of all pages in the [offset, offset+limit) window, and the total count
 	SaveBookmarksByPage(pageID int, bookmarks []Bookmark)
+	SaveBookmarksIfVersion(pageID int, bookmarks []Bookmark, matches func(Page) bool) ([]Bookmark, error) // Saves only while matches accepts the page as stored, else errVersionMismatch; returns the replaced bookmarks
 	AddBookmarkToPage(pageID int, bookmark Bookmark)
 	DeleteBookmarkFromPage(pageID int, bookmark Bookmark) error
+	IncrementVisit(pageID int, name, url string) error // Adds a visit to the bookmark without changing the page's UpdatedAt, errBookmarkNotFound if there's none
 	// Categories - per page only
 	GetCategoriesByPage(pageID int) []Category
 	SaveCategoriesByPage(pageID int, categories []Category)
+	SetCategoryCollapsed(pageID int, categoryID string, collapsed bool) error     // Returns errCategoryNotFound when the page has no such category
+	ReorderCategories(pageID int, ids []string) error                             // Returns errCategoryOrder unless ids are the page's category IDs, each once
+	DeleteCategory(pageID int, categoryID, reassignTo string) ([]Bookmark, error) // Returns the category's bookmarks, with errCategoryInUse if there are any and reassignTo is empty
+	// AssignCategory returns the bookmarks moved into the category, with
+	// errPageNotFound or errCategoryNotFound when either is missing
+	AssignCategory(pageID int, categoryID string, urls []string) ([]Bookmark, error)
+	// RepairCategories moves the bookmarks whose category is missing into
+	// fallback, creating it if needed, and returns them; errPageNotFound if there's no page
+	RepairCategories(pageID int, fallback string) ([]Bookmark, error)
+	// ClearPage removes the page's bookmarks, and its categories too when
+	// categories is set, returning the page as it was; errPageNotFound if there's none
+	ClearPage(pageID int, categories bool) (PageWithBookmarks, error)
 	// Finders
 	GetFinders() []Finder
 	SaveFinders(finders []Finder)
 	// Pages
 	GetPages() []Page
+	GetPagesWithCounts() []PageSummary
+	GetPageWithBookmarks(pageID int) (PageWithBookmarks, error)
 	SavePage(page Page, bookmarks []Bookmark)
 	DeletePage(pageID int) error
+	RenamePage(pageID int, name string) error
+	SetPageFavicon(pageID int, favicon string) error
+	PageExists(pageID int) bool
 	GetPageOrder() []int
 	SavePageOrder(order []int)
+	SavePages(pages []Page) error // Saves the pages' metadata, keeping their bookmarks, and makes their order the page order, all or nothing
+	// MergePages moves the source page's categories and bookmarks into the target
+	// and deletes the source, returning the target as it was; errPageNotFound if
+	// either is missing
+	MergePages(sourceID, targetID int) (PageWithBookmarks, error)
 	// Settings
 	GetSettings() Settings
 	SaveSettings(settings Settings)
@@ -141,113 +236,231 @@ type FileStore struct {
 	pageOrderFile string
 	dataDir       string
 	mutex         sync.RWMutex
+
+	// Corrupt files already preserved, by path, with the size and mtime seen
+	corruptMutex sync.Mutex
+	corruptSeen  map[string]string
 }
 
+// NewStore returns the storage backend selected by the STORAGE environment
+// variable. The JSON file store is the default; STORAGE=sqlite selects the
+// SQLite store at DB_PATH (data/thinkdashboard.db by default).
 func NewStore() Store {
-	store := &FileStore{
-		settingsFile:  "data/settings.json",
-		colorsFile:    "data/colors.json",
-		pageOrderFile: "data/pages.json",
-		dataDir:       "data",
+	if strings.ToLower(os.Getenv("STORAGE")) == "sqlite" {
+		dbPath := os.Getenv("DB_PATH")
+		if dbPath == "" {
+			dbPath = "data/thinkdashboard.db"
+		}
+
+		store, err := NewSQLiteStore(dbPath, "data")
+		if err != nil {
+			log.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		migrateCategoryIDs(store)
+		return store
 	}
 
+	store := newFileStore("data")
+
 	// Initialize default files if they don't exist
 	store.initializeDefaultFiles()
+	migrateCategoryIDs(store)
 
-	return store
+	return newCachingStore(store)
+}
+
+// newFileStore returns a FileStore rooted at dataDir without creating any default files
+func newFileStore(dataDir string) *FileStore {
+	return &FileStore{
+		settingsFile:  filepath.Join(dataDir, "settings.json"),
+		colorsFile:    filepath.Join(dataDir, "colors.json"),
+		pageOrderFile: filepath.Join(dataDir, "pages.json"),
+		dataDir:       dataDir,
+	}
 }
 
 func (fs *FileStore) initializeDefaultFiles() {
+	if err := fs.writeDefaultFiles(); err != nil {
+		log.Fatalf("Failed to load seed file: %v", err)
+	}
+}
+
+// writeDefaultFiles creates the data files that don't exist yet, from SEED_FILE
+// when it is set and there are no pages, failing only if the seed can't be loaded
+func (fs *FileStore) writeDefaultFiles() error {
 	fs.ensureDataDir()
 
+	// A new data directory starts from SEED_FILE when set
+	if pageFiles, _ := filepath.Glob(filepath.Join(fs.dataDir, "bookmarks-*.json")); len(pageFiles) == 0 {
+		if seeded, err := seedStore(fs); err != nil || seeded {
+			return err
+		}
+	}
+
 	// Initialize bookmarks for main page if file doesn't exist
-	mainPageBookmarksFile := "data/bookmarks-1.json"
+	mainPageBookmarksFile := fmt.Sprintf("%s/bookmarks-1.json", fs.dataDir)
 	if _, err := os.Stat(mainPageBookmarksFile); os.IsNotExist(err) {
-		defaultPageWithBookmarks := PageWithBookmarks{
-			Page: Page{
-				ID:   1,
-				Name: "main",
-			},
-			Categories: []Category{
-				{ID: "development", Name: "Development"},
-				{ID: "media", Name: "Media"},
-				{ID: "social", Name: "Social"},
-				{ID: "search", Name: "Search"},
-				{ID: "utilities", Name: "Utilities"},
-			},
-			Bookmarks: []Bookmark{
-				{Name: "GitHub", URL: "https://github.com", Shortcut: "G", Category: "development", CheckStatus: false},
-				{Name: "GitHub Issues", URL: "https://github.com/issues", Shortcut: "GI", Category: "development", CheckStatus: false},
-				{Name: "GitHub Pull Requests", URL: "https://github.com/pulls", Shortcut: "GP", Category: "development", CheckStatus: false},
-				{Name: "YouTube", URL: "https://youtube.com", Shortcut: "Y", Category: "media", CheckStatus: false},
-				{Name: "YouTube Studio", URL: "https://studio.youtube.com", Shortcut: "YS", Category: "media", CheckStatus: false},
-				{Name: "Twitter", URL: "https://twitter.com", Shortcut: "T", Category: "social", CheckStatus: false},
-				{Name: "TikTok", URL: "https://tiktok.com", Shortcut: "TT", Category: "social", CheckStatus: false},
-				{Name: "Google", URL: "https://google.com", Shortcut: "", Category: "search", CheckStatus: false},
-			},
-		}
-		data, _ := json.MarshalIndent(defaultPageWithBookmarks, "", "  ")
+		defaultPageWithBookmarks := getDefaultMainPage()
+		data, _ := marshalDataFile(defaultPageWithBookmarks)
 		os.WriteFile(mainPageBookmarksFile, data, 0644)
 	}
 
 	// Initialize settings if file doesn't exist
 	if _, err := os.Stat(fs.settingsFile); os.IsNotExist(err) {
-		defaultSettings := Settings{
-			CurrentPage:               1,
-			Theme:                     "dark",
-			OpenInNewTab:              true,
-			ColumnsPerRow:             3,
-			FontSize:                  "medium",
-			ShowBackgroundDots:        true,
-			ShowTitle:                 true,
-			ShowDate:                  true,
-			ShowConfigButton:          true,
-			ShowSearchButton:          true,
-			ShowFindersButton:         false,
-			ShowCommandsButton:        false,
-			ShowSearchButtonText:      true,
-			ShowFindersButtonText:     true,
-			ShowCommandsButtonText:    true,
-			ShowStatus:                false,
-			ShowPing:                  false,
-			ShowStatusLoading:         false,
-			SkipFastPing:              false,
-			GlobalShortcuts:           true,
-			HyprMode:                  false,
-			AnimationsEnabled:         true,
-			EnableCustomTitle:         false,
-			CustomTitle:               "",
-			ShowPageInTitle:           false,
-			ShowPageNamesInTabs:       false,
-			EnableCustomFavicon:       false,
-			CustomFaviconPath:         "",
-			EnableCustomFont:          false,
-			CustomFontPath:            "",
-			Language:                  "en",
-			InterleaveMode:            false,
-			ShowPageTabs:              true,
-			AlwaysCollapseCategories:  false,
-			EnableFuzzySuggestions:    false,
-			FuzzySuggestionsStartWith: false,
-			KeepSearchOpenWhenEmpty:   false,
-			ShowIcons:                 false,
-			IncludeFindersInSearch:    false,
-		}
-		data, _ := json.MarshalIndent(defaultSettings, "", "  ")
+		defaultSettings := getDefaultSettings()
+		data, _ := marshalDataFile(defaultSettings)
 		os.WriteFile(fs.settingsFile, data, 0644)
 	}
 
 	// Initialize colors if file doesn't exist
 	if _, err := os.Stat(fs.colorsFile); os.IsNotExist(err) {
 		defaultColors := getDefaultColors()
-		data, _ := json.MarshalIndent(defaultColors, "", "  ")
+		data, _ := marshalDataFile(defaultColors)
 		os.WriteFile(fs.colorsFile, data, 0644)
 	}
 
+	return nil
+}
+
+// decodeFile unmarshals data read from filePath. When the file is corrupt, the error
+// is logged and a copy is kept as <file>.corrupt-<timestamp> before anything can
+// overwrite it, so the data can be recovered by hand.
+func (fs *FileStore) decodeFile(filePath string, data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+
+	stamp, _ := fileStamp(filePath)
+
+	fs.corruptMutex.Lock()
+	defer fs.corruptMutex.Unlock()
+	if fs.corruptSeen == nil {
+		fs.corruptSeen = make(map[string]string)
+	}
+	if seen, ok := fs.corruptSeen[filePath]; ok && seen == stamp {
+		return err
+	}
+	fs.corruptSeen[filePath] = stamp
+
+	backupPath := fmt.Sprintf("%s.corrupt-%s", filePath, time.Now().Format("20060102-150405"))
+	if writeErr := os.WriteFile(backupPath, data, 0644); writeErr != nil {
+		log.Printf("Error: %s is corrupted (%v) and could not be preserved: %v", filePath, err, writeErr)
+	} else {
+		log.Printf("Error: %s is corrupted (%v), preserved a copy as %s", filePath, err, backupPath)
+	}
+	return err
 }
 
 func (fs *FileStore) ensureDataDir() {
-	os.MkdirAll("data", 0755)
+	os.MkdirAll(fs.dataDir, 0755)
+}
+
+// nowMillis returns the current time as Unix milliseconds, used for UpdatedAt
+func nowMillis() int64 {
+	return time.Now().UnixMilli()
+}
+
+// marshalDataFile encodes v for a file in the data directory, indented for hand
+// editing unless JSON_COMPACT=true
+func marshalDataFile(v interface{}) ([]byte, error) {
+	if strings.ToLower(os.Getenv("JSON_COMPACT")) == "true" {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// writePageFile stamps the page's UpdatedAt and writes it to filePath
+func (fs *FileStore) writePageFile(filePath string, pageWithBookmarks PageWithBookmarks) error {
+	pageWithBookmarks.Page.UpdatedAt = nowMillis()
+	data, err := marshalDataFile(pageWithBookmarks)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// getDefaultMainPage returns the sample main page written on first run
+func getDefaultMainPage() PageWithBookmarks {
+	return PageWithBookmarks{
+		Page: Page{
+			ID:   1,
+			Name: "main",
+		},
+		Categories: []Category{
+			{ID: "development", Name: "Development"},
+			{ID: "media", Name: "Media"},
+			{ID: "social", Name: "Social"},
+			{ID: "search", Name: "Search"},
+			{ID: "utilities", Name: "Utilities"},
+		},
+		Bookmarks: []Bookmark{
+			{Name: "GitHub", URL: "https://github.com", Shortcut: "G", Category: "development", CheckStatus: false},
+			{Name: "GitHub Issues", URL: "https://github.com/issues", Shortcut: "GI", Category: "development", CheckStatus: false},
+			{Name: "GitHub Pull Requests", URL: "https://github.com/pulls", Shortcut: "GP", Category: "development", CheckStatus: false},
+			{Name: "YouTube", URL: "https://youtube.com", Shortcut: "Y", Category: "media", CheckStatus: false},
+			{Name: "YouTube Studio", URL: "https://studio.youtube.com", Shortcut: "YS", Category: "media", CheckStatus: false},
+			{Name: "Twitter", URL: "https://twitter.com", Shortcut: "T", Category: "social", CheckStatus: false},
+			{Name: "TikTok", URL: "https://tiktok.com", Shortcut: "TT", Category: "social", CheckStatus: false},
+			{Name: "Google", URL: "https://google.com", Shortcut: "", Category: "search", CheckStatus: false},
+		},
+	}
+}
+
+// getDefaultSettings returns the settings written on first run
+func getDefaultSettings() Settings {
+	return Settings{
+		CurrentPage:               1,
+		Theme:                     "dark",
+		OpenInNewTab:              true,
+		ColumnsPerRow:             3,
+		MobileBreakpoint:          defaultMobileBreakpoint,
+		WideBreakpoint:            defaultWideBreakpoint,
+		FontSize:                  "m",
+		ShowBackgroundDots:        true,
+		ShowTitle:                 true,
+		ShowDate:                  true,
+		ShowConfigButton:          true,
+		ShowSearchButton:          true,
+		ShowFindersButton:         false,
+		ShowCommandsButton:        false,
+		ShowSearchButtonText:      true,
+		ShowFindersButtonText:     true,
+		ShowCommandsButtonText:    true,
+		ShowStatus:                false,
+		ShowPing:                  false,
+		ShowStatusLoading:         false,
+		SkipFastPing:              false,
+		PingMode:                  "tcp",
+		GlobalShortcuts:           true,
+		CaseSensitiveShortcuts:    false,
+		HyprMode:                  false,
+		AllowCustomSchemes:        false,
+		ShortcutPattern:           "",
+		AnimationsEnabled:         true,
+		EnableCustomTitle:         false,
+		CustomTitle:               "",
+		ShowPageInTitle:           false,
+		ShowPageNamesInTabs:       false,
+		EnableCustomFavicon:       false,
+		CustomFaviconPath:         "",
+		EnableCustomFont:          false,
+		CustomFontPath:            "",
+		HeadingFont:               "",
+		BodyFont:                  "",
+		MonoFont:                  "",
+		Language:                  "en",
+		InterleaveMode:            false,
+		ShowPageTabs:              true,
+		AlwaysCollapseCategories:  false,
+		EnableFuzzySuggestions:    false,
+		FuzzySuggestionsStartWith: false,
+		KeepSearchOpenWhenEmpty:   false,
+		ShowIcons:                 false,
+		IncludeFindersInSearch:    false,
+		RespectSchedules:          false,
+		RandomThemeOnLoad:         false,
+	}
 }
 
 // getDefaultNewPageCategories returns the default categories for a newly created page
@@ -261,6 +474,11 @@ func (fs *FileStore) GetBookmarksByPage(pageID int) []Bookmark {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
+	return fs.getBookmarksByPage(pageID)
+}
+
+// getBookmarksByPage must be called with the mutex held
+func (fs *FileStore) getBookmarksByPage(pageID int) []Bookmark {
 	fs.ensureDataDir()
 
 	// Read directly from bookmarks-{pageID}.json
@@ -271,7 +489,7 @@ func (fs *FileStore) GetBookmarksByPage(pageID int) []Bookmark {
 	}
 
 	var pageWithBookmarks PageWithBookmarks
-	if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 		return []Bookmark{}
 	}
 
@@ -297,20 +515,46 @@ func (fs *FileStore) SaveBookmarksByPage(pageID int, bookmarks []Bookmark) {
 			Categories: getDefaultNewPageCategories(),
 			Bookmarks:  bookmarks,
 		}
-		newData, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-		os.WriteFile(filePath, newData, 0644)
+		fs.writePageFile(filePath, pageWithBookmarks)
 		return
 	}
 
 	var pageWithBookmarks PageWithBookmarks
-	if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 		return
 	}
 
 	// Update only bookmarks, preserve page metadata and categories
 	pageWithBookmarks.Bookmarks = bookmarks
-	newData, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-	os.WriteFile(filePath, newData, 0644)
+	fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+// SaveBookmarksIfVersion replaces the page's bookmarks if matches accepts the page
+// as it is on disk, checking and writing under one lock
+func (fs *FileStore) SaveBookmarksIfVersion(pageID int, bookmarks []Bookmark, matches func(Page) bool) ([]Bookmark, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errVersionMismatch
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return nil, err
+	}
+	if !matches(pageWithBookmarks.Page) {
+		return nil, errVersionMismatch
+	}
+
+	previous := pageWithBookmarks.Bookmarks
+	pageWithBookmarks.Bookmarks = bookmarks
+	return previous, fs.writePageFile(filePath, pageWithBookmarks)
 }
 
 func (fs *FileStore) AddBookmarkToPage(pageID int, bookmark Bookmark) {
@@ -332,20 +576,18 @@ func (fs *FileStore) AddBookmarkToPage(pageID int, bookmark Bookmark) {
 			Categories: getDefaultNewPageCategories(),
 			Bookmarks:  []Bookmark{bookmark},
 		}
-		newData, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-		os.WriteFile(filePath, newData, 0644)
+		fs.writePageFile(filePath, pageWithBookmarks)
 		return
 	}
 
 	var pageWithBookmarks PageWithBookmarks
-	if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 		return
 	}
 
 	// Add the new bookmark to existing bookmarks
 	pageWithBookmarks.Bookmarks = append(pageWithBookmarks.Bookmarks, bookmark)
-	newData, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-	os.WriteFile(filePath, newData, 0644)
+	fs.writePageFile(filePath, pageWithBookmarks)
 }
 
 func (fs *FileStore) DeleteBookmarkFromPage(pageID int, bookmarkToDelete Bookmark) error {
@@ -362,7 +604,7 @@ func (fs *FileStore) DeleteBookmarkFromPage(pageID int, bookmarkToDelete Bookmar
 	}
 
 	var pageWithBookmarks PageWithBookmarks
-	if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 		return err
 	}
 
@@ -376,11 +618,7 @@ func (fs *FileStore) DeleteBookmarkFromPage(pageID int, bookmarkToDelete Bookmar
 	}
 
 	// Save the updated data
-	newData, err := json.MarshalIndent(pageWithBookmarks, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(filePath, newData, 0644)
+	return fs.writePageFile(filePath, pageWithBookmarks)
 }
 
 func (fs *FileStore) removeBookmarkFromSlice(bookmarks []Bookmark, toDelete Bookmark) []Bookmark {
@@ -404,19 +642,42 @@ func (fs *FileStore) GetAllBookmarks() []Bookmark {
 	fs.ensureDataDir()
 
 	// Get all pages
-	pages := fs.GetPages()
+	pages := fs.getPages()
 
 	var allBookmarks []Bookmark
 
 	// Collect bookmarks from all pages
 	for _, page := range pages {
-		pageBookmarks := fs.GetBookmarksByPage(page.ID)
+		pageBookmarks := fs.getBookmarksByPage(page.ID)
 		allBookmarks = append(allBookmarks, pageBookmarks...)
 	}
 
 	return allBookmarks
 }
 
+func (fs *FileStore) GetBookmarksRange(offset, limit int) ([]Bookmark, int) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	fs.ensureDataDir()
+
+	items := []Bookmark{}
+	total := 0
+
+	// Only bookmarks inside the window are kept, the rest are just counted
+	for _, page := range fs.getPages() {
+		pageBookmarks := fs.getBookmarksByPage(page.ID)
+		for _, bookmark := range pageBookmarks {
+			if total >= offset && len(items) < limit {
+				items = append(items, bookmark)
+			}
+			total++
+		}
+	}
+
+	return items, total
+}
+
 func (fs *FileStore) GetFinders() []Finder {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
@@ -430,7 +691,7 @@ func (fs *FileStore) GetFinders() []Finder {
 	}
 
 	var finders []Finder
-	if err := json.Unmarshal(data, &finders); err != nil {
+	if err := fs.decodeFile(filePath, data, &finders); err != nil {
 		return []Finder{}
 	}
 
@@ -444,7 +705,7 @@ func (fs *FileStore) SaveFinders(finders []Finder) {
 	fs.ensureDataDir()
 
 	filePath := fmt.Sprintf("%s/finders.json", fs.dataDir)
-	data, err := json.MarshalIndent(finders, "", "  ")
+	data, err := marshalDataFile(finders)
 	if err != nil {
 		return
 	}
@@ -466,7 +727,7 @@ func (fs *FileStore) GetCategoriesByPage(pageID int) []Category {
 	}
 
 	var pageWithBookmarks PageWithBookmarks
-	if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 		return []Category{}
 	}
 
@@ -479,6 +740,8 @@ func (fs *FileStore) SaveCategoriesByPage(pageID int, categories []Category) {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
+	assignCategoryIDs(categories)
+
 	fs.ensureDataDir()
 
 	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
@@ -494,16 +757,310 @@ func (fs *FileStore) SaveCategoriesByPage(pageID int, categories []Category) {
 			Categories: categories,
 			Bookmarks:  []Bookmark{},
 		}
-		newData, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-		os.WriteFile(filePath, newData, 0644)
+		fs.writePageFile(filePath, pageWithBookmarks)
 		return
 	}
 
 	var pageWithBookmarks PageWithBookmarks
-	if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 		return
 	}
 
+	remapBookmarkCategories(pageWithBookmarks.Categories, categories, pageWithBookmarks.Bookmarks)
+
+	pageWithBookmarks.Categories = categories
+	fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+// SetCategoryCollapsed changes only the collapsed flag of one category, leaving the
+// bookmarks and the other categories as they are
+func (fs *FileStore) SetCategoryCollapsed(pageID int, categoryID string, collapsed bool) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return err
+	}
+
+	for i := range pageWithBookmarks.Categories {
+		if pageWithBookmarks.Categories[i].ID == categoryID {
+			pageWithBookmarks.Categories[i].Collapsed = collapsed
+			return fs.writePageFile(filePath, pageWithBookmarks)
+		}
+	}
+	return errCategoryNotFound
+}
+
+// ReorderCategories puts the page's categories in the order of ids, leaving their
+// names, IDs and bookmarks as they are
+func (fs *FileStore) ReorderCategories(pageID int, ids []string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return err
+	}
+
+	categories, err := orderCategories(pageWithBookmarks.Categories, ids)
+	if err != nil {
+		return err
+	}
+	pageWithBookmarks.Categories = categories
+	return fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+// AssignCategory moves the page's bookmarks whose URL is in urls into categoryID
+func (fs *FileStore) AssignCategory(pageID int, categoryID string, urls []string) ([]Bookmark, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errPageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return nil, err
+	}
+
+	changed, err := assignCategory(pageWithBookmarks.Categories, pageWithBookmarks.Bookmarks, categoryID, urls)
+	if err != nil || len(changed) == 0 {
+		return changed, err
+	}
+	return changed, fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+// RepairCategories moves the page's bookmarks whose category is missing into
+// fallback. Categories and bookmarks share the page file, which is replaced
+// through a fileBatch so they're never saved apart.
+func (fs *FileStore) RepairCategories(pageID int, fallback string) ([]Bookmark, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errPageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return nil, err
+	}
+
+	categories, moved := repairCategories(pageWithBookmarks.Categories, pageWithBookmarks.Bookmarks, fallback)
+	if len(moved) == 0 {
+		return nil, nil
+	}
+	pageWithBookmarks.Categories = categories
+	pageWithBookmarks.Page.UpdatedAt = nowMillis()
+	data, err = marshalDataFile(pageWithBookmarks)
+	if err != nil {
+		return nil, err
+	}
+	batch := newFileBatch()
+	if err := batch.Write(filePath, data); err != nil {
+		return nil, err
+	}
+	return moved, batch.Commit()
+}
+
+// ClearPage empties the page file in a single replacement, so the bookmarks and
+// categories are never cleared apart
+func (fs *FileStore) ClearPage(pageID int, categories bool) (PageWithBookmarks, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return PageWithBookmarks{}, errPageNotFound
+	}
+	if err != nil {
+		return PageWithBookmarks{}, err
+	}
+
+	var previous PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &previous); err != nil {
+		return PageWithBookmarks{}, err
+	}
+
+	cleared := previous
+	cleared.Bookmarks = []Bookmark{}
+	if categories {
+		cleared.Categories = []Category{}
+	}
+	cleared.Page.UpdatedAt = nowMillis()
+	data, err = marshalDataFile(cleared)
+	if err != nil {
+		return PageWithBookmarks{}, err
+	}
+	batch := newFileBatch()
+	if err := batch.Write(filePath, data); err != nil {
+		return PageWithBookmarks{}, err
+	}
+	return previous, batch.Commit()
+}
+
+// IncrementVisit adds one to the visit count of the first bookmark on the page
+// with name and url. A visit isn't an edit, so the page's UpdatedAt is kept.
+func (fs *FileStore) IncrementVisit(pageID int, name, url string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return err
+	}
+
+	for i := range pageWithBookmarks.Bookmarks {
+		bookmark := &pageWithBookmarks.Bookmarks[i]
+		if bookmark.Name == name && bookmark.URL == url {
+			bookmark.VisitCount++
+			data, err := marshalDataFile(pageWithBookmarks)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(filePath, data, 0644)
+		}
+	}
+	return errBookmarkNotFound
+}
+
+// DeleteCategory removes a category from the page, moving its bookmarks to the
+// category reassignTo
+func (fs *FileStore) DeleteCategory(pageID int, categoryID, reassignTo string) ([]Bookmark, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return nil, err
+	}
+
+	categories, moved, err := removeCategory(pageWithBookmarks.Categories, pageWithBookmarks.Bookmarks, categoryID, reassignTo)
+	if err != nil {
+		return moved, err
+	}
+	pageWithBookmarks.Categories = categories
+	return moved, fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+// removeCategory returns categories without categoryID and moves its bookmarks to
+// reassignTo in place, adding that category if the page doesn't have it. It
+// returns the moved bookmarks, or errCategoryInUse with the bookmarks left alone
+// when there are some and reassignTo is empty.
+func removeCategory(categories []Category, bookmarks []Bookmark, categoryID, reassignTo string) ([]Category, []Bookmark, error) {
+	remaining := make([]Category, 0, len(categories))
+	found, hasTarget := false, false
+	for _, category := range categories {
+		switch category.ID {
+		case categoryID:
+			found = true
+			continue
+		case reassignTo:
+			hasTarget = true
+		}
+		remaining = append(remaining, category)
+	}
+	if !found {
+		return nil, nil, errCategoryNotFound
+	}
+
+	var moved []Bookmark
+	for _, bookmark := range bookmarks {
+		if bookmark.Category == categoryID {
+			moved = append(moved, bookmark)
+		}
+	}
+	if len(moved) == 0 {
+		return remaining, nil, nil
+	}
+	if reassignTo == "" {
+		return nil, moved, errCategoryInUse
+	}
+
+	if !hasTarget {
+		remaining = append(remaining, fallbackCategory(reassignTo))
+	}
+	for i := range bookmarks {
+		if bookmarks[i].Category == categoryID {
+			bookmarks[i].Category = reassignTo
+		}
+	}
+	return remaining, moved, nil
+}
+
+// fallbackCategory returns a new category for bookmarks that lost theirs. "others"
+// gets the translated name the dashboard uses for it.
+func fallbackCategory(id string) Category {
+	name := id
+	if id == "others" {
+		name = "dashboard.others"
+	}
+	return Category{ID: id, Name: name}
+}
+
+// orderCategories returns categories in the order of ids, which must hold every
+// category ID exactly once
+func orderCategories(categories []Category, ids []string) ([]Category, error) {
+	if len(ids) != len(categories) {
+		return nil, errCategoryOrder
+	}
+	byID := make(map[string]Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	ordered := make([]Category, 0, len(ids))
+	for _, id := range ids {
+		category, ok := byID[id]
+		if !ok {
+			return nil, errCategoryOrder
+		}
+		delete(byID, id)
+		ordered = append(ordered, category)
+	}
+	return ordered, nil
+}
+
+// remapBookmarkCategories updates bookmarks in place to use the new category IDs
+// when category names (and thus IDs) change between oldCategories and categories
+func remapBookmarkCategories(oldCategories, categories []Category, bookmarks []Bookmark) {
 	// Create a mapping from old category IDs to new category IDs
 	// This allows us to update bookmarks when category names (and thus IDs) change
 	oldToNewCategoryMap := make(map[string]string)
@@ -517,46 +1074,168 @@ func (fs *FileStore) SaveCategoriesByPage(pageID int, categories []Category) {
 			if newCat.OriginalID != newCat.ID {
 				oldToNewCategoryMap[newCat.OriginalID] = newCat.ID
 			}
-		} else if i < len(pageWithBookmarks.Categories) {
+		} else if i < len(oldCategories) {
 			// Fallback: map by position if originalId is not available
-			oldCat := pageWithBookmarks.Categories[i]
+			oldCat := oldCategories[i]
 			oldToNewCategoryMap[oldCat.ID] = newCat.ID
 		}
 	}
 
 	// Update bookmarks to use new category IDs
-	for i := range pageWithBookmarks.Bookmarks {
-		oldCategoryID := pageWithBookmarks.Bookmarks[i].Category
+	for i := range bookmarks {
+		oldCategoryID := bookmarks[i].Category
 		if newCategoryID, exists := oldToNewCategoryMap[oldCategoryID]; exists {
-			pageWithBookmarks.Bookmarks[i].Category = newCategoryID
+			bookmarks[i].Category = newCategoryID
 		}
 	}
+}
 
-	pageWithBookmarks.Categories = categories
-	newData, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-	os.WriteFile(filePath, newData, 0644)
+// newCategoryID returns a random category ID that isn't in taken. Category IDs are
+// assigned once and never derived from the name, so renaming a category doesn't
+// change which bookmarks belong to it.
+func newCategoryID(taken map[string]bool) string {
+	for {
+		b := make([]byte, 4)
+		rand.Read(b)
+		id := "cat-" + hex.EncodeToString(b)
+		if !taken[id] {
+			return id
+		}
+	}
+}
+
+// assignCategoryIDs gives categories with an empty or duplicate ID a new stable ID,
+// keeping bookmarks with the first category that had the ID. It reports whether
+// any ID was changed.
+func assignCategoryIDs(categories []Category) bool {
+	taken := make(map[string]bool)
+	for _, category := range categories {
+		taken[category.ID] = true
+	}
+
+	seen := make(map[string]bool)
+	changed := false
+	for i := range categories {
+		if categories[i].ID != "" && !seen[categories[i].ID] {
+			seen[categories[i].ID] = true
+			continue
+		}
+		id := newCategoryID(taken)
+		taken[id], seen[id] = true, true
+		categories[i].ID = id
+		// Keep remapBookmarkCategories from moving bookmarks to the new category
+		categories[i].OriginalID = id
+		changed = true
+	}
+	return changed
+}
+
+// migrateCategoryIDs freezes the category IDs of every page, fixing empty or
+// duplicate IDs left by older versions that derived IDs from category names
+func migrateCategoryIDs(store Store) {
+	for _, page := range store.GetPages() {
+		categories := store.GetCategoriesByPage(page.ID)
+		if assignCategoryIDs(categories) {
+			log.Printf("Assigned stable category IDs on page %d", page.ID)
+			store.SaveCategoriesByPage(page.ID, categories)
+		}
+	}
+}
+
+// findOrphanedBookmarks returns the indexes of bookmarks whose category isn't one of
+// categories. Such bookmarks aren't shown on the dashboard; bookmarks without a
+// category are shown as uncategorized and aren't orphaned.
+func findOrphanedBookmarks(categories []Category, bookmarks []Bookmark) []int {
+	known := make(map[string]bool)
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+
+	var orphaned []int
+	for i, bookmark := range bookmarks {
+		if bookmark.Category != "" && !known[bookmark.Category] {
+			orphaned = append(orphaned, i)
+		}
+	}
+	return orphaned
+}
+
+// repairCategories moves the bookmarks whose category is missing from categories
+// into fallback, adding it when needed. The bookmarks are changed in place; the
+// categories are returned with the moved bookmarks.
+func repairCategories(categories []Category, bookmarks []Bookmark, fallback string) ([]Category, []Bookmark) {
+	orphaned := findOrphanedBookmarks(categories, bookmarks)
+	if len(orphaned) == 0 {
+		return categories, nil
+	}
+	if !slices.ContainsFunc(categories, func(category Category) bool { return category.ID == fallback }) {
+		categories = append(categories, fallbackCategory(fallback))
+	}
+	moved := make([]Bookmark, 0, len(orphaned))
+	for _, i := range orphaned {
+		bookmarks[i].Category = fallback
+		moved = append(moved, bookmarks[i])
+	}
+	return categories, moved
 }
 
 func (fs *FileStore) GetPages() []Page {
+	return pagesOf(fs.GetPagesWithCounts())
+}
+
+// GetPagesWithCounts reads the pages under the read lock. Pages missing from
+// pages.json are only added to it under the write lock, so concurrent readers
+// never write the file.
+func (fs *FileStore) GetPagesWithCounts() []PageSummary {
 	fs.mutex.RLock()
-	defer fs.mutex.RUnlock()
+	summaries, order := fs.getPageSummaries()
+	fs.mutex.RUnlock()
+	if order == nil {
+		return summaries
+	}
 
-	return fs.getPages()
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	// Read again, another writer may have changed the pages in between
+	summaries, order = fs.getPageSummaries()
+	if order != nil {
+		fs.savePageOrder(order)
+	}
+	return summaries
 }
 
+// getPages returns the pages in order without saving pages.json, for readers
+// that already hold the mutex
 func (fs *FileStore) getPages() []Page {
-	fs.ensureDataDir()
+	summaries, _ := fs.getPageSummaries()
+	return pagesOf(summaries)
+}
 
-	var pages []Page
+func pagesOf(summaries []PageSummary) []Page {
+	pages := make([]Page, len(summaries))
+	for i, summary := range summaries {
+		pages[i] = summary.Page
+	}
+	return pages
+}
+
+// getPageSummaries reads every page file once, collecting the pages in order
+// together with their bookmark and category counts. When pages.json is missing
+// some pages it also returns the order with them added, for the caller to save
+// under the write lock.
+func (fs *FileStore) getPageSummaries() ([]PageSummary, []int) {
+	fs.ensureDataDir()
 
 	// Read all bookmarks files in data directory
 	files, err := os.ReadDir(fs.dataDir)
 	if err != nil {
-		return []Page{{ID: 1, Name: "main"}}
+		return []PageSummary{{Page: Page{ID: 1, Name: "main"}}}, nil
 	}
 
 	// First, collect all pages from bookmark files
 	pageMap := make(map[int]Page)
+	counts := make(map[int]PageSummary)
 	for _, file := range files {
 		if file.IsDir() || !strings.HasPrefix(file.Name(), "bookmarks-") || !strings.HasSuffix(file.Name(), ".json") {
 			continue
@@ -569,46 +1248,65 @@ func (fs *FileStore) getPages() []Page {
 		}
 
 		var pageWithBookmarks PageWithBookmarks
-		if err := json.Unmarshal(data, &pageWithBookmarks); err != nil {
+		if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
 			continue
 		}
 
 		pageMap[pageWithBookmarks.Page.ID] = pageWithBookmarks.Page
+		counts[pageWithBookmarks.Page.ID] = PageSummary{
+			BookmarkCount: len(pageWithBookmarks.Bookmarks),
+			CategoryCount: len(pageWithBookmarks.Categories),
+		}
 	}
 
 	if len(pageMap) == 0 {
-		return []Page{{ID: 1, Name: "main"}}
+		return []PageSummary{{Page: Page{ID: 1, Name: "main"}}}, nil
 	}
 
-	// Get the order from pages.json
-	order := fs.getPageOrder()
+	// Get the order from pages.json, adding any pages missing from it
+	order, changed := reconcilePageOrder(pageMap, fs.getPageOrder())
 
-	// If no order file exists, create default order
-	if len(order) == 0 {
-		for id := range pageMap {
-			order = append(order, id)
-		}
-		// Save the default order
-		fs.savePageOrder(order)
+	pages := orderPages(pageMap, order)
+	summaries := make([]PageSummary, len(pages))
+	for i, page := range pages {
+		summaries[i] = counts[page.ID]
+		summaries[i].Page = page
+	}
+	if !changed {
+		return summaries, nil
 	}
+	return summaries, order
+}
 
-	// Build pages array in the specified order
+// reconcilePageOrder appends the pages that exist but are missing from order,
+// sorted by ID so they always land in the same position, and reports whether
+// the order changed and should be saved
+func reconcilePageOrder(pageMap map[int]Page, order []int) ([]int, bool) {
+	inOrder := make(map[int]bool)
 	for _, id := range order {
-		if page, exists := pageMap[id]; exists {
-			pages = append(pages, page)
-		}
+		inOrder[id] = true
 	}
 
-	// Add any pages that exist in files but not in order
-	for id, page := range pageMap {
-		found := false
-		for _, orderId := range order {
-			if orderId == id {
-				found = true
-				break
-			}
+	var missing []int
+	for id := range pageMap {
+		if !inOrder[id] {
+			missing = append(missing, id)
 		}
-		if !found {
+	}
+	if len(missing) == 0 {
+		return order, false
+	}
+
+	sort.Ints(missing)
+	return append(append([]int{}, order...), missing...), true
+}
+
+// orderPages builds the pages array in the given order
+func orderPages(pageMap map[int]Page, order []int) []Page {
+	var pages []Page
+
+	for _, id := range order {
+		if page, exists := pageMap[id]; exists {
 			pages = append(pages, page)
 		}
 	}
@@ -616,6 +1314,81 @@ func (fs *FileStore) getPages() []Page {
 	return pages
 }
 
+// GetPageWithBookmarks returns the page metadata, categories and bookmarks
+// stored together in bookmarks-{pageID}.json
+func (fs *FileStore) GetPageWithBookmarks(pageID int) (PageWithBookmarks, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	fs.ensureDataDir()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return PageWithBookmarks{}, err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return PageWithBookmarks{}, err
+	}
+
+	if pageWithBookmarks.Categories == nil {
+		pageWithBookmarks.Categories = []Category{}
+	}
+	if pageWithBookmarks.Bookmarks == nil {
+		pageWithBookmarks.Bookmarks = []Bookmark{}
+	}
+
+	return pageWithBookmarks, nil
+}
+
+// RenamePage changes the name stored in bookmarks-{pageID}.json, leaving its
+// categories and bookmarks untouched
+func (fs *FileStore) RenamePage(pageID int, name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return err
+	}
+
+	pageWithBookmarks.Page.Name = name
+	return fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+// SetPageFavicon changes only the favicon of a page
+func (fs *FileStore) SetPageFavicon(pageID int, favicon string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var pageWithBookmarks PageWithBookmarks
+	if err := fs.decodeFile(filePath, data, &pageWithBookmarks); err != nil {
+		return err
+	}
+
+	pageWithBookmarks.Page.Favicon = favicon
+	return fs.writePageFile(filePath, pageWithBookmarks)
+}
+
+func (fs *FileStore) PageExists(pageID int) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID))
+	return err == nil
+}
+
 func (fs *FileStore) GetPageOrder() []int {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
@@ -632,7 +1405,7 @@ func (fs *FileStore) getPageOrder() []int {
 	}
 
 	var pageOrder PageOrder
-	if err := json.Unmarshal(data, &pageOrder); err != nil {
+	if err := fs.decodeFile(fs.pageOrderFile, data, &pageOrder); err != nil {
 		return []int{}
 	}
 
@@ -653,8 +1426,10 @@ func (fs *FileStore) savePageOrder(order []int) {
 		Order: order,
 	}
 
-	data, _ := json.MarshalIndent(pageOrder, "", "  ")
-	os.WriteFile(fs.pageOrderFile, data, 0644)
+	data, _ := marshalDataFile(pageOrder)
+	if err := writeFileAtomic(fs.pageOrderFile, data); err != nil {
+		log.Printf("Error saving page order: %v", err)
+	}
 }
 
 func (fs *FileStore) SavePage(page Page, bookmarks []Bookmark) {
@@ -669,7 +1444,7 @@ func (fs *FileStore) SavePage(page Page, bookmarks []Bookmark) {
 
 	var existing PageWithBookmarks
 	if data, err := os.ReadFile(fileName); err == nil {
-		_ = json.Unmarshal(data, &existing)
+		_ = fs.decodeFile(fileName, data, &existing)
 	}
 
 	pageWithBookmarks := PageWithBookmarks{
@@ -682,8 +1457,57 @@ func (fs *FileStore) SavePage(page Page, bookmarks []Bookmark) {
 		pageWithBookmarks.Categories = getDefaultNewPageCategories()
 	}
 
-	data, _ := json.MarshalIndent(pageWithBookmarks, "", "  ")
-	os.WriteFile(fileName, data, 0644)
+	fs.writePageFile(fileName, pageWithBookmarks)
+}
+
+// SavePages rewrites every page file and the page order through a fileBatch, so a
+// failure partway leaves all of them as they were
+func (fs *FileStore) SavePages(pages []Page) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.ensureDataDir()
+	batch := newFileBatch()
+	order := make([]int, len(pages))
+	for i, page := range pages {
+		order[i] = page.ID
+		fileName := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, page.ID)
+
+		var existing PageWithBookmarks
+		if data, err := os.ReadFile(fileName); err == nil {
+			if err := fs.decodeFile(fileName, data, &existing); err != nil {
+				batch.Rollback()
+				return err
+			}
+		}
+		if existing.Categories == nil {
+			existing.Categories = getDefaultNewPageCategories()
+		}
+		if existing.Bookmarks == nil {
+			existing.Bookmarks = []Bookmark{}
+		}
+
+		page.UpdatedAt = nowMillis()
+		data, err := marshalDataFile(PageWithBookmarks{Page: page, Categories: existing.Categories, Bookmarks: existing.Bookmarks})
+		if err == nil {
+			err = batch.Write(fileName, data)
+		}
+		if err != nil {
+			batch.Rollback()
+			return err
+		}
+	}
+
+	// The order goes last so it never lists a page whose file wasn't written
+	data, err := marshalDataFile(PageOrder{Order: order})
+	if err == nil {
+		err = batch.Write(fs.pageOrderFile, data)
+	}
+	if err != nil {
+		batch.Rollback()
+		return err
+	}
+	return batch.Commit()
 }
 
 func (fs *FileStore) DeletePage(pageID int) error {
@@ -697,6 +1521,38 @@ func (fs *FileStore) DeletePage(pageID int) error {
 	return os.Remove(filePath)
 }
 
+// MergePages merges the source page into the target with mergePageInto, deletes
+// the source and removes it from the page order, all under one lock
+func (fs *FileStore) MergePages(sourceID, targetID int) (PageWithBookmarks, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var pages [2]PageWithBookmarks
+	for i, pageID := range []int{sourceID, targetID} {
+		filePath := fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, pageID)
+		data, err := os.ReadFile(filePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return PageWithBookmarks{}, errPageNotFound
+		}
+		if err != nil {
+			return PageWithBookmarks{}, err
+		}
+		if err := fs.decodeFile(filePath, data, &pages[i]); err != nil {
+			return PageWithBookmarks{}, err
+		}
+	}
+	source, target := pages[0], pages[1]
+
+	if err := fs.writePageFile(fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, targetID), mergePageInto(target, source)); err != nil {
+		return PageWithBookmarks{}, err
+	}
+	if err := os.Remove(fmt.Sprintf("%s/bookmarks-%d.json", fs.dataDir, sourceID)); err != nil {
+		return PageWithBookmarks{}, err
+	}
+	fs.savePageOrder(withoutPage(fs.getPageOrder(), sourceID))
+	return target, nil
+}
+
 func (fs *FileStore) GetSettings() Settings {
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
@@ -711,6 +1567,8 @@ func (fs *FileStore) GetSettings() Settings {
 			Theme:                     "dark",
 			OpenInNewTab:              true,
 			ColumnsPerRow:             3,
+			MobileBreakpoint:          defaultMobileBreakpoint,
+			WideBreakpoint:            defaultWideBreakpoint,
 			FontSize:                  "m",
 			ShowBackgroundDots:        true,
 			ShowTitle:                 true,
@@ -726,8 +1584,12 @@ func (fs *FileStore) GetSettings() Settings {
 			ShowPing:                  false,
 			ShowStatusLoading:         false,
 			SkipFastPing:              false,
+			PingMode:                  "tcp",
 			GlobalShortcuts:           true,
+			CaseSensitiveShortcuts:    false,
 			HyprMode:                  false,
+			AllowCustomSchemes:        false,
+			ShortcutPattern:           "",
 			AnimationsEnabled:         true,
 			EnableCustomTitle:         false,
 			CustomTitle:               "",
@@ -737,6 +1599,9 @@ func (fs *FileStore) GetSettings() Settings {
 			CustomFaviconPath:         "",
 			EnableCustomFont:          false,
 			CustomFontPath:            "",
+			HeadingFont:               "",
+			BodyFont:                  "",
+			MonoFont:                  "",
 			Language:                  "en",
 			InterleaveMode:            false,
 			ShowPageTabs:              true,
@@ -746,11 +1611,15 @@ func (fs *FileStore) GetSettings() Settings {
 			KeepSearchOpenWhenEmpty:   false,
 			ShowIcons:                 false,
 			IncludeFindersInSearch:    false,
+			RespectSchedules:          false,
+			RandomThemeOnLoad:         false,
 		}
 	}
 
 	var settings Settings
-	json.Unmarshal(data, &settings)
+	if err := fs.decodeFile(fs.settingsFile, data, &settings); err != nil {
+		return getDefaultSettings()
+	}
 
 	// Set default language if empty
 	if settings.Language == "" {
@@ -766,7 +1635,8 @@ func (fs *FileStore) SaveSettings(settings Settings) {
 
 	fs.ensureDataDir()
 
-	data, _ := json.MarshalIndent(settings, "", "  ")
+	settings.UpdatedAt = nowMillis()
+	data, _ := marshalDataFile(settings)
 	os.WriteFile(fs.settingsFile, data, 0644)
 }
 
@@ -817,7 +1687,7 @@ func (fs *FileStore) GetColors() ColorTheme {
 	}
 
 	var colors ColorTheme
-	if err := json.Unmarshal(data, &colors); err != nil {
+	if err := fs.decodeFile(fs.colorsFile, data, &colors); err != nil {
 		return getDefaultColors()
 	}
 
@@ -835,6 +1705,7 @@ func (fs *FileStore) SaveColors(colors ColorTheme) {
 
 	fs.ensureDataDir()
 
-	data, _ := json.MarshalIndent(colors, "", "  ")
+	colors.UpdatedAt = nowMillis()
+	data, _ := marshalDataFile(colors)
 	os.WriteFile(fs.colorsFile, data, 0644)
 }
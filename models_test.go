@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFileStoreConcurrentGetPages(t *testing.T) {
+	dir := t.TempDir()
+	store := newFileStore(dir)
+	for id := 1; id <= 5; id++ {
+		store.SavePage(Page{ID: id, Name: "page"}, []Bookmark{})
+	}
+	// Without pages.json every reader finds pages missing from the order
+	os.Remove(filepath.Join(dir, "pages.json"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				pages := store.GetPages()
+				if len(pages) != 5 {
+					t.Errorf("GetPages returned %d pages, want 5", len(pages))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(filepath.Join(dir, "pages.json"))
+	if err != nil {
+		t.Fatalf("pages.json wasn't written: %v", err)
+	}
+	var order PageOrder
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatalf("pages.json is invalid: %v", err)
+	}
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(order.Order, want) {
+		t.Errorf("order = %v, want %v", order.Order, want)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file left behind: %s", entry.Name())
+		}
+	}
+}
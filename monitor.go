@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// defaultStatusMonitorInterval is how often bookmarks with a status webhook are
+// checked in the background unless STATUS_MONITOR_INTERVAL says otherwise
+const defaultStatusMonitorInterval = time.Minute
+
+// monitorStatuses checks the status-checked bookmarks that have a webhook every
+// interval, so changes are reported while no dashboard is open. An interval of 0
+// turns it off.
+func (h *Handlers) monitorStatuses(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.checkWebhookStatuses()
+	}
+}
+
+// checkWebhookStatuses probes every status-checked bookmark with a webhook and
+// waits for the results, which pingBookmark hands to the notifier
+func (h *Handlers) checkWebhookStatuses() {
+	settings := h.store.GetSettings()
+	var wg sync.WaitGroup
+	for _, bookmark := range h.store.GetAllBookmarks() {
+		if !bookmark.CheckStatus || statusWebhookFor(bookmark, settings) == "" {
+			continue
+		}
+		parsedURL, err := url.Parse(bookmark.URL)
+		if err != nil {
+			continue
+		}
+		targetURL, err := h.pingTarget(bookmark, parsedURL)
+		if err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(bookmark Bookmark) {
+			defer wg.Done()
+			h.pingBookmark(bookmark, targetURL, settings.SkipFastPing)
+		}(bookmark)
+	}
+	wg.Wait()
+}
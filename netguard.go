@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// targetPolicy restricts which hosts the server connects to on behalf of clients.
+// The default allows everything, which suits single-user homelab setups; shared
+// deployments can block private addresses and specific hosts via the environment:
+//
+//	PING_ALLOW_PRIVATE=false    block loopback, RFC1918 and link-local targets
+//	PING_DENY_HOSTS=a.com,10.0.0.0/8    block hosts (and their subdomains), IPs or CIDRs
+type targetPolicy struct {
+	allowPrivate bool
+	denyHosts    []string
+	denyNets     []*net.IPNet
+}
+
+// loadTargetPolicy reads the target policy from the environment
+func loadTargetPolicy() targetPolicy {
+	policy := targetPolicy{
+		allowPrivate: strings.ToLower(os.Getenv("PING_ALLOW_PRIVATE")) != "false",
+	}
+
+	for _, entry := range strings.Split(os.Getenv("PING_DENY_HOSTS"), ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			policy.denyNets = append(policy.denyNets, network)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			policy.denyNets = append(policy.denyNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			policy.denyHosts = append(policy.denyHosts, strings.TrimPrefix(entry, "."))
+		}
+	}
+
+	return policy
+}
+
+// checkHost returns an error if host is denied by name, or is an IP literal that
+// isn't allowed. Host names that resolve to a disallowed address are caught at
+// dial time by the dialer's Control function.
+func (p targetPolicy) checkHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, denied := range p.denyHosts {
+		if host == denied || strings.HasSuffix(host, "."+denied) {
+			return fmt.Errorf("host %s is denied", host)
+		}
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(ip)
+	}
+	return nil
+}
+
+// checkIP returns an error if connecting to ip isn't allowed
+func (p targetPolicy) checkIP(ip net.IP) error {
+	if !p.allowPrivate && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()) {
+		return fmt.Errorf("private address %s is not allowed", ip)
+	}
+	for _, network := range p.denyNets {
+		if network.Contains(ip) {
+			return fmt.Errorf("address %s is denied", ip)
+		}
+	}
+	return nil
+}
+
+// dialer returns a net.Dialer that refuses to connect to disallowed addresses,
+// including ones reached through DNS or HTTP redirects
+func (p targetPolicy) dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("unexpected address %s", address)
+			}
+			return p.checkIP(ip)
+		},
+	}
+}
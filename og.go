@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// The 1.91:1 size link previews show without cropping
+const (
+	ogImageWidth  = 1200
+	ogImageHeight = 630
+	ogImageMargin = 80
+)
+
+// dashboardTitle returns the title of the dashboard as shown in the browser tab
+func dashboardTitle(settings Settings) string {
+	if settings.EnableCustomTitle && settings.CustomTitle != "" {
+		return settings.CustomTitle
+	}
+	return "Dashboard"
+}
+
+// parseHexColor turns "#rgb" or "#rrggbb" into a color, or returns fallback
+func parseHexColor(value string, fallback color.Color) color.Color {
+	if !hexColorPattern.MatchString(value) {
+		return fallback
+	}
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	rgb, _ := strconv.ParseUint(hex, 16, 32)
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}
+}
+
+// drawPixelText draws text in the 7x13 bitmap font enlarged scale times, with its
+// top left corner at (x, y). Characters the font lacks are left out.
+func drawPixelText(dst draw.Image, text string, x, y, scale int, c color.Color) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil()
+	if width == 0 {
+		return
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	drawer := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: fixed.P(0, face.Ascent)}
+	drawer.DrawString(text)
+
+	target := image.Rect(x, y, x+width*scale, y+face.Height*scale)
+	scaled := image.NewAlpha(target)
+	xdraw.NearestNeighbor.Scale(scaled, target, mask, mask.Bounds(), draw.Src, nil)
+	draw.DrawMask(dst, target, image.NewUniform(c), image.Point{}, scaled, target.Min, draw.Over)
+}
+
+// fitPixelText returns the largest scale up to maxScale at which text fits in
+// width, shortening the text with "..." if it doesn't fit even at minScale
+func fitPixelText(text string, width, minScale, maxScale int) (string, int) {
+	advance := basicfont.Face7x13.Advance
+	runes := []rune(text)
+	if scale := width / (advance * max(len(runes), 1)); scale >= minScale {
+		return text, min(scale, maxScale)
+	}
+	fits := width/(advance*minScale) - 3
+	return string(runes[:max(fits, 0)]) + "...", minScale
+}
+
+// OGImage renders the PNG card link previews show for the dashboard: its title and
+// number of pages in the colors of the current theme
+func (h *Handlers) OGImage(w http.ResponseWriter, r *http.Request) {
+	settings := h.settingsFor(r)
+	colors := currentThemeColors(h.colorsFor(r), h.effectiveTheme(r, settings))
+
+	img := image.NewRGBA(image.Rect(0, 0, ogImageWidth, ogImageHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(parseHexColor(colors.BackgroundPrimary, color.Black)), image.Point{}, draw.Src)
+	footer := image.Rect(0, ogImageHeight-120, ogImageWidth, ogImageHeight)
+	draw.Draw(img, footer, image.NewUniform(parseHexColor(colors.BackgroundSecondary, color.Black)), image.Point{}, draw.Src)
+	accent := image.Rect(ogImageMargin, 150, ogImageMargin+96, 162)
+	draw.Draw(img, accent, image.NewUniform(parseHexColor(colors.AccentSuccess, color.White)), image.Point{}, draw.Src)
+
+	title, scale := fitPixelText(dashboardTitle(settings), ogImageWidth-2*ogImageMargin, 4, 10)
+	drawPixelText(img, title, ogImageMargin, 200, scale, parseHexColor(colors.TextPrimary, color.White))
+
+	pages := len(h.store.GetPages())
+	subtitle := fmt.Sprintf("%d pages", pages)
+	if pages == 1 {
+		subtitle = "1 page"
+	}
+	drawPixelText(img, subtitle, ogImageMargin, footer.Min.Y+(footer.Dy()-basicfont.Face7x13.Height*4)/2, 4, parseHexColor(colors.TextSecondary, color.White))
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to render image")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(buf.Bytes())
+}
+
+// absoluteURL returns the full URL of a dashboard path for clients that need one,
+// such as link preview crawlers, using the scheme and host the request came in on
+func (h *Handlers) absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host + h.basePathFor(r) + path
+}
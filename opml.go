@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`     // type="link" outlines
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"` // Feed outlines: the site
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`  // Feed outlines: the feed itself
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// link returns the URL an outline points to, preferring the site over the feed
+func (o opmlOutline) link() string {
+	switch {
+	case o.HTMLURL != "":
+		return o.HTMLURL
+	case o.URL != "":
+		return o.URL
+	default:
+		return o.XMLURL
+	}
+}
+
+// ExportOPML downloads a page's bookmarks as OPML, with one outline per category
+func (h *Handlers) ExportOPML(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := h.importPageID(w, r)
+	if !ok {
+		return
+	}
+
+	pageWithBookmarks, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	linkOutline := func(bookmark Bookmark) opmlOutline {
+		return opmlOutline{Text: bookmark.Name, Type: "link", URL: bookmark.URL}
+	}
+
+	// Bookmarks grouped under their category, uncategorized ones at the top level
+	categoryOutlines := make(map[string]*opmlOutline)
+	var outlines []opmlOutline
+	for _, category := range pageWithBookmarks.Categories {
+		categoryOutlines[category.ID] = &opmlOutline{Text: category.Name}
+	}
+	var uncategorized []opmlOutline
+	for _, bookmark := range pageWithBookmarks.Bookmarks {
+		if outline, ok := categoryOutlines[bookmark.Category]; ok {
+			outline.Outlines = append(outline.Outlines, linkOutline(bookmark))
+		} else {
+			uncategorized = append(uncategorized, linkOutline(bookmark))
+		}
+	}
+	for _, category := range pageWithBookmarks.Categories {
+		if outline := categoryOutlines[category.ID]; len(outline.Outlines) > 0 {
+			outlines = append(outlines, *outline)
+		}
+	}
+	outlines = append(outlines, uncategorized...)
+
+	document := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       pageWithBookmarks.Page.Name,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: opmlBody{Outlines: outlines},
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=bookmarks-%d.opml", pageID))
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	encoder.Encode(document)
+}
+
+// ImportOPML appends the links of an OPML file to a page. Outlines with a URL become
+// bookmarks in the category named after their parent outline, which is created
+// when missing.
+func (h *Handlers) ImportOPML(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := h.importPageID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB max
+	body, err := importBody(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Failed to read file")
+		return
+	}
+
+	var document opmlDocument
+	if err := xml.NewDecoder(body).Decode(&document); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid OPML: %v", err))
+		return
+	}
+
+	allowCustomSchemes := h.settingsFor(r).AllowCustomSchemes
+	resolver := newCategoryResolver(h.store.GetCategoriesByPage(pageID))
+	var bookmarks []Bookmark
+
+	var walk func(outlines []opmlOutline, category string) error
+	walk = func(outlines []opmlOutline, category string) error {
+		for _, outline := range outlines {
+			name := outline.Text
+			if name == "" {
+				name = outline.Title
+			}
+
+			link := outline.link()
+			if link == "" {
+				// Outlines without a link group their children
+				if err := walk(outline.Outlines, name); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := validateBookmarkURL(link, allowCustomSchemes); err != nil {
+				return fmt.Errorf("%s: %v", link, err)
+			}
+			if name == "" {
+				name = link
+			}
+			bookmarks = append(bookmarks, Bookmark{
+				Name:     name,
+				URL:      link,
+				Category: resolver.resolve(category),
+			})
+
+			if err := walk(outline.Outlines, category); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(document.Body.Outlines, ""); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidURL, fmt.Sprintf("Invalid bookmark URL %v", err))
+		return
+	}
+
+	h.appendImportedBookmarks(r, pageID, resolver, bookmarks)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "imported": len(bookmarks)})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// faviconExtension returns the extension a favicon upload of contentType is saved
+// with, and whether the type is accepted
+func faviconExtension(contentType string) (string, bool) {
+	for ext, faviconType := range faviconExtensions {
+		if faviconType == contentType {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// pageFaviconPattern is the only form Page.Favicon may take. Any other value, e.g.
+// from a hand-edited page file, is never read or deleted.
+var pageFaviconPattern = regexp.MustCompile(`^/data/favicon-page-\d+\.(ico|png|jpe?g|gif)$`)
+
+// pageFaviconFile returns the name in the data directory of a page's favicon, or
+// "" when there is none or the value isn't a page favicon
+func pageFaviconFile(favicon string) string {
+	if !pageFaviconPattern.MatchString(favicon) {
+		return ""
+	}
+	return strings.TrimPrefix(favicon, "/data/")
+}
+
+// serveFaviconFile serves a favicon from the data directory, reporting false if
+// it doesn't exist
+func serveFaviconFile(w http.ResponseWriter, r *http.Request, dataPath string) bool {
+	file, err := os.Open(filepath.Join("data", filepath.FromSlash(path.Clean("/"+dataPath))))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+	return true
+}
+
+// PageFavicon serves the favicon of a page, falling back to the custom favicon in
+// settings and then the default one. ?size= picks among the resized copies of the
+// custom favicon as in Favicon.
+func (h *Handlers) PageFavicon(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+	size, ok := faviconSize(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid size")
+		return
+	}
+	page, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if name := pageFaviconFile(page.Page.Favicon); name != "" && serveFaviconFile(w, r, name) {
+		return
+	}
+	h.serveSiteFavicon(w, r, h.settingsFor(r), size)
+}
+
+// UploadPageFavicon saves the favicon shown while a page is open, as
+// data/favicon-page-<id> with the extension of its type
+func (h *Handlers) UploadPageFavicon(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to parse form")
+		return
+	}
+	file, header, err := r.FormFile("favicon")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Error retrieving file")
+		return
+	}
+	defer file.Close()
+
+	ext, ok := faviconExtension(header.Header.Get("Content-Type"))
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid file type. Only ico, png, jpg, gif allowed")
+		return
+	}
+	page, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to read file")
+		return
+	}
+	name := fmt.Sprintf("favicon-page-%d%s", pageID, ext)
+	os.MkdirAll("data", 0755)
+	if err := os.WriteFile(filepath.Join("data", name), content, 0644); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to save file")
+		return
+	}
+
+	favicon := "/data/" + name
+	if previous := pageFaviconFile(page.Page.Favicon); previous != "" && previous != name {
+		os.Remove(filepath.Join("data", previous))
+	}
+	if err := h.store.SetPageFavicon(pageID, favicon); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving page")
+		return
+	}
+	h.events.Publish("pages", 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "path": favicon})
+}
+
+// DeletePageFavicon removes a page's favicon, so it shows the global one again
+func (h *Handlers) DeletePageFavicon(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+	page, err := h.store.GetPageWithBookmarks(pageID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+		return
+	}
+
+	if page.Page.Favicon != "" {
+		if err := h.store.SetPageFavicon(pageID, ""); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving page")
+			return
+		}
+		if name := pageFaviconFile(page.Page.Favicon); name != "" {
+			if err := os.Remove(filepath.Join("data", name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Warning: could not remove %s: %v", page.Page.Favicon, err)
+			}
+		}
+		h.events.Publish("pages", 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
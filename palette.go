@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paletteAction is one entry of the command palette. Action says how the client
+// invokes it and which of the other fields it uses:
+//   - "open": open URL (bookmarks)
+//   - "switch-page": show Page
+//   - "apply-theme": apply Theme
+//   - "search": search with URL, where %s stands for the query (finders)
+type paletteAction struct {
+	Type     string `json:"type"` // "bookmark", "page", "theme" or "finder"
+	Label    string `json:"label"`
+	Action   string `json:"action"`
+	URL      string `json:"url,omitempty"`
+	Page     int    `json:"page,omitempty"` // Page to switch to, or the page a bookmark is on
+	Theme    string `json:"theme,omitempty"`
+	Shortcut string `json:"shortcut,omitempty"`
+	score    int
+}
+
+// paletteActions gathers everything the palette can do from pageID: switching
+// pages, opening bookmarks of the page (of every page with GlobalShortcuts),
+// applying themes and searching with finders
+func (h *Handlers) paletteActions(r *http.Request, pageID int) []paletteAction {
+	settings := h.settingsFor(r)
+	var actions []paletteAction
+
+	pages := h.store.GetPages()
+	for _, page := range pages {
+		actions = append(actions, paletteAction{Type: "page", Label: page.Name, Action: "switch-page", Page: page.ID})
+	}
+
+	for _, page := range pages {
+		if page.ID != pageID && !settings.GlobalShortcuts {
+			continue
+		}
+		bookmarks := visibleBookmarks(h.store.GetBookmarksByPage(page.ID))
+		if settings.RespectSchedules {
+			bookmarks = scheduledBookmarks(bookmarks, time.Now())
+		}
+		for _, bookmark := range bookmarks {
+			actions = append(actions, paletteAction{
+				Type:     "bookmark",
+				Label:    bookmark.Name,
+				Action:   "open",
+				URL:      bookmark.URL,
+				Page:     page.ID,
+				Shortcut: bookmark.Shortcut,
+			})
+		}
+	}
+
+	actions = append(actions,
+		paletteAction{Type: "theme", Label: "Light", Action: "apply-theme", Theme: "light"},
+		paletteAction{Type: "theme", Label: "Dark", Action: "apply-theme", Theme: "dark"},
+	)
+	colors := h.colorsFor(r)
+	for _, themeID := range customThemeIDs(colors) {
+		label := colors.Custom[themeID].Name
+		if label == "" {
+			label = themeID
+		}
+		actions = append(actions, paletteAction{Type: "theme", Label: label, Action: "apply-theme", Theme: themeID})
+	}
+
+	for _, finder := range h.store.GetFinders() {
+		actions = append(actions, paletteAction{
+			Type:     "finder",
+			Label:    finder.Name,
+			Action:   "search",
+			URL:      finder.SearchUrl,
+			Shortcut: finder.Shortcut,
+		})
+	}
+
+	return actions
+}
+
+// Palette returns the actions of the command palette for ?page= (default the
+// current page). With ?q= only matching actions are returned, ranked like Search.
+// ?limit= caps the count (1-500, default 50).
+func (h *Handlers) Palette(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	pageID := h.settingsFor(r).CurrentPage
+	if pageIDStr := query.Get("page"); pageIDStr != "" {
+		value, err := strconv.Atoi(pageIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		pageID = value
+	}
+
+	limit := 50
+	if limitStr := query.Get("limit"); limitStr != "" {
+		value, err := strconv.Atoi(limitStr)
+		if err != nil || value < 1 || value > 500 {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid limit, must be between 1 and 500")
+			return
+		}
+		limit = value
+	}
+
+	actions := h.paletteActions(r, pageID)
+	if q := strings.ToLower(strings.TrimSpace(query.Get("q"))); q != "" {
+		matches := actions[:0]
+		for _, action := range actions {
+			if action.score = matchScore(q, action.Label, action.Shortcut, false); action.score > 0 {
+				matches = append(matches, action)
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+		actions = matches
+	}
+	if len(actions) > limit {
+		actions = actions[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actions)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readLaterItem is one saved link of a read-later export
+type readLaterItem struct {
+	URL     string
+	Title   string
+	AddedAt time.Time // Zero when the export has no time
+	Tags    []string
+}
+
+var (
+	readLaterLinkPattern      = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
+	readLaterAttributePattern = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*"([^"]*)"`)
+	readLaterTagPattern       = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// errUnknownReadLaterFormat is returned for files that aren't a Pocket or Instapaper export
+var errUnknownReadLaterFormat = errors.New("not a Pocket or Instapaper export")
+
+// parseReadLaterExport reads Pocket's HTML export (ril_export.html) or the CSV
+// exports of Pocket (title, url, time_added, tags) and Instapaper (URL, Title,
+// Folder, Timestamp, Tags). CSV columns are found by their header.
+func parseReadLaterExport(content []byte) ([]readLaterItem, error) {
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("<")) {
+		return parsePocketHTML(content), nil
+	}
+	return parseReadLaterCSV(content)
+}
+
+// parsePocketHTML reads the links of Pocket's HTML export, which are plain <a>
+// elements with time_added and tags attributes
+func parsePocketHTML(content []byte) []readLaterItem {
+	var items []readLaterItem
+	for _, match := range readLaterLinkPattern.FindAllSubmatch(content, -1) {
+		attributes := make(map[string]string)
+		for _, attribute := range readLaterAttributePattern.FindAllSubmatch(match[1], -1) {
+			attributes[strings.ToLower(string(attribute[1]))] = html.UnescapeString(string(attribute[2]))
+		}
+		items = append(items, readLaterItem{
+			URL:     strings.TrimSpace(attributes["href"]),
+			Title:   strings.TrimSpace(html.UnescapeString(readLaterTagPattern.ReplaceAllString(string(match[2]), ""))),
+			AddedAt: parseUnixTime(attributes["time_added"]),
+			Tags:    splitReadLaterTags(attributes["tags"]),
+		})
+	}
+	return items
+}
+
+// parseReadLaterCSV reads a Pocket or Instapaper CSV export
+func parseReadLaterCSV(content []byte) ([]readLaterItem, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errUnknownReadLaterFormat
+	}
+
+	columns := make(map[string]int)
+	for i, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errUnknownReadLaterFormat
+	}
+	field := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+
+	var items []readLaterItem
+	for _, record := range records[1:] {
+		items = append(items, readLaterItem{
+			URL:     field(record, "url"),
+			Title:   field(record, "title"),
+			AddedAt: parseUnixTime(field(record, "time_added", "timestamp")),
+			Tags:    splitReadLaterTags(field(record, "tags")),
+		})
+	}
+	return items, nil
+}
+
+// parseUnixTime parses seconds since the epoch, returning the zero time otherwise
+func parseUnixTime(value string) time.Time {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// splitReadLaterTags splits the tags of an export: a JSON array for Instapaper,
+// "|" separated in Pocket's CSV and "," separated in its HTML
+func splitReadLaterTags(value string) []string {
+	value = strings.TrimSpace(value)
+	var tags []string
+	switch {
+	case strings.HasPrefix(value, "["):
+		json.Unmarshal([]byte(value), &tags)
+	case strings.Contains(value, "|"):
+		tags = strings.Split(value, "|")
+	default:
+		tags = strings.Split(value, ",")
+	}
+
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// ImportPocket appends the links of a Pocket or Instapaper export to a page,
+// leaving out URLs the page already has. Tags and the time a link was saved are
+// kept in the bookmark's meta as "tags" (comma separated) and "addedAt".
+func (h *Handlers) ImportPocket(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := h.importPageID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB max
+	body, err := importBody(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Failed to read file")
+		return
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Failed to read file")
+		return
+	}
+
+	items, err := parseReadLaterExport(content)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid export: %v", err))
+		return
+	}
+
+	allowCustomSchemes := h.settingsFor(r).AllowCustomSchemes
+	seen := make(map[string]bool)
+	for _, bookmark := range h.store.GetBookmarksByPage(pageID) {
+		seen[bookmarkURLKey(bookmark.URL)] = true
+	}
+
+	var bookmarks []Bookmark
+	skipped := 0
+	for _, item := range items {
+		if item.URL == "" {
+			continue
+		}
+		if err := validateBookmarkURL(item.URL, allowCustomSchemes); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidURL, fmt.Sprintf("Invalid bookmark URL %s: %v", item.URL, err))
+			return
+		}
+		key := bookmarkURLKey(item.URL)
+		if seen[key] {
+			skipped++
+			continue
+		}
+		seen[key] = true
+
+		bookmark := Bookmark{Name: item.Title, URL: item.URL}
+		if bookmark.Name == "" {
+			bookmark.Name = item.URL
+		}
+		if len(item.Tags) > 0 || !item.AddedAt.IsZero() {
+			bookmark.Meta = make(map[string]string)
+			if len(item.Tags) > 0 {
+				bookmark.Meta["tags"] = strings.Join(item.Tags, ",")
+			}
+			if !item.AddedAt.IsZero() {
+				bookmark.Meta["addedAt"] = item.AddedAt.Format(time.RFC3339)
+			}
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	h.appendImportedBookmarks(r, pageID, newCategoryResolver(nil), bookmarks)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "imported": len(bookmarks), "skipped": skipped})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	faviconMaxSize  = 512 << 10 // 512KB
+	faviconCacheTTL = 7 * 24 * time.Hour
+)
+
+// faviconTypes maps the content types accepted from remote servers to the
+// extension the cached copy is saved with
+var faviconTypes = map[string]string{
+	"image/png":                ".png",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/svg+xml":            ".svg",
+	"image/webp":               ".webp",
+}
+
+// bookmarkHostRegistered reports whether a visible bookmark points at host
+func (h *Handlers) bookmarkHostRegistered(host string) bool {
+	for _, bookmark := range h.store.GetAllBookmarks() {
+		if bookmark.Hidden {
+			continue
+		}
+		parsedURL, err := url.Parse(bookmark.URL)
+		if err == nil && strings.EqualFold(parsedURL.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchFavicon downloads an icon within the size and time limits, refusing
+// targets the ping policy doesn't allow
+func (h *Handlers) fetchFavicon(iconURL string) ([]byte, string, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext:           h.pingPolicy.dialer(2 * time.Second).DialContext,
+			TLSHandshakeTimeout:   2 * time.Second,
+			ResponseHeaderTimeout: 3 * time.Second,
+		},
+	}
+
+	req, err := http.NewRequest("GET", iconURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "ThinkDashboard-Favicon/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("remote server returned %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, faviconMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(content) > faviconMaxSize {
+		return nil, "", fmt.Errorf("icon is larger than %d bytes", faviconMaxSize)
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0]))
+	if _, ok := faviconTypes[contentType]; !ok {
+		contentType = http.DetectContentType(content)
+	}
+	ext, ok := faviconTypes[contentType]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported content type %s", contentType)
+	}
+	return content, ext, nil
+}
+
+// svgContentSecurityPolicy keeps SVGs served from the dashboard's own origin from
+// running scripts or loading anything, even when opened directly
+const svgContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+
+// writeFavicon sends a proxied icon. SVGs get a restrictive CSP since they're
+// served from the dashboard's own origin.
+func writeFavicon(w http.ResponseWriter, ext string, content []byte) {
+	if ext == ".svg" {
+		w.Header().Set("Content-Security-Policy", svgContentSecurityPolicy)
+	}
+	for contentType, typeExt := range faviconTypes {
+		if typeExt == ext && contentType != "image/vnd.microsoft.icon" {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(content)
+}
+
+// FaviconProxy fetches a remote icon for ?url= on the client's behalf, so icons of
+// http services load on an https dashboard. The URL's host must belong to a
+// bookmark, and fetched icons are cached under data/icons for a week.
+func (h *Handlers) FaviconProxy(w http.ResponseWriter, r *http.Request) {
+	iconURL := r.URL.Query().Get("url")
+	parsedURL, err := url.Parse(iconURL)
+	if iconURL == "" || err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Hostname() == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidURL, "url must be an http(s) URL")
+		return
+	}
+
+	if !h.bookmarkHostRegistered(parsedURL.Hostname()) {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidURL, "URL host doesn't belong to a bookmark")
+		return
+	}
+	if err := h.pingPolicy.checkHost(parsedURL.Hostname()); err != nil {
+		writeJSONError(w, http.StatusForbidden, codeURLNotAllowed, "URL target is not allowed")
+		return
+	}
+
+	sum := sha256.Sum256([]byte(iconURL))
+	cacheName := "favicon-" + hex.EncodeToString(sum[:8])
+	iconsDir := filepath.Join("data", "icons")
+
+	// Serve a fresh cached copy if there is one
+	if matches, _ := filepath.Glob(filepath.Join(iconsDir, cacheName+".*")); len(matches) > 0 {
+		if info, err := os.Stat(matches[0]); err == nil && time.Since(info.ModTime()) < faviconCacheTTL {
+			if content, err := os.ReadFile(matches[0]); err == nil {
+				writeFavicon(w, filepath.Ext(matches[0]), content)
+				return
+			}
+		}
+	}
+
+	content, ext, err := h.fetchFavicon(iconURL)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, codeUpstreamError, "Unable to fetch icon")
+		return
+	}
+
+	os.MkdirAll(iconsDir, 0755)
+	if matches, _ := filepath.Glob(filepath.Join(iconsDir, cacheName+".*")); len(matches) > 0 {
+		for _, match := range matches {
+			os.Remove(match)
+		}
+	}
+	os.WriteFile(filepath.Join(iconsDir, cacheName+ext), content, 0644)
+
+	writeFavicon(w, ext, content)
+}
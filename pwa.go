@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type webManifest struct {
+	Name            string            `json:"name"`
+	ShortName       string            `json:"short_name"`
+	StartURL        string            `json:"start_url"`
+	Scope           string            `json:"scope"`
+	Display         string            `json:"display"`
+	BackgroundColor string            `json:"background_color,omitempty"`
+	ThemeColor      string            `json:"theme_color,omitempty"`
+	Icons           []webManifestIcon `json:"icons"`
+}
+
+type webManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type,omitempty"`
+}
+
+// currentThemeColors returns the colors of the theme selected in settings, which
+// is "light", "dark" or the ID of a custom theme
+func currentThemeColors(colors ColorTheme, theme string) ThemeColors {
+	switch theme {
+	case "light":
+		return colors.Light
+	case "dark":
+		return colors.Dark
+	}
+	if custom, ok := colors.Custom[theme]; ok {
+		return custom
+	}
+	return colors.Dark
+}
+
+// Manifest serves the web app manifest, built from the title, favicon and theme settings
+func (h *Handlers) Manifest(w http.ResponseWriter, r *http.Request) {
+	settings := h.settingsFor(r)
+
+	name := dashboardTitle(settings)
+
+	basePath := h.basePathFor(r)
+	icon := basePath + "/static/favicon.ico"
+	if settings.EnableCustomFavicon && settings.CustomFaviconPath != "" {
+		icon = basePath + settings.CustomFaviconPath
+	}
+
+	background := currentThemeColors(h.colorsFor(r), settings.Theme).BackgroundPrimary
+
+	manifest := webManifest{
+		Name:            name,
+		ShortName:       name,
+		StartURL:        basePath + "/",
+		Scope:           basePath + "/",
+		Display:         "standalone",
+		BackgroundColor: background,
+		ThemeColor:      background,
+		Icons: []webManifestIcon{
+			{Src: icon, Sizes: "any", Type: mime.TypeByExtension(path.Ext(icon))},
+		},
+	}
+	for _, size := range faviconVariantSizes(settings) {
+		if size >= 192 {
+			manifest.Icons = append(manifest.Icons, webManifestIcon{
+				Src:   fmt.Sprintf("%s/api/favicon?size=%d", basePath, size),
+				Sizes: fmt.Sprintf("%dx%d", size, size),
+				Type:  "image/png",
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// serviceWorkerTemplate caches the dashboard shell on install and keeps a copy of
+// every GET response, so the last seen dashboard can be shown while offline.
+// Requests go to the network first so online use always sees fresh data.
+const serviceWorkerTemplate = `const CACHE = %s;
+const SHELL = %s;
+const BASE = %s;
+
+self.addEventListener('install', (event) => {
+    event.waitUntil(
+        caches.open(CACHE)
+            .then((cache) => cache.addAll(SHELL))
+            .then(() => self.skipWaiting())
+    );
+});
+
+self.addEventListener('activate', (event) => {
+    event.waitUntil(
+        caches.keys()
+            .then((keys) => Promise.all(keys.filter((key) => key !== CACHE).map((key) => caches.delete(key))))
+            .then(() => self.clients.claim())
+    );
+});
+
+self.addEventListener('fetch', (event) => {
+    const request = event.request;
+    const url = new URL(request.url);
+    if (request.method !== 'GET' || url.origin !== self.location.origin) {
+        return;
+    }
+    // Live endpoints make no sense offline
+    if (url.pathname === BASE + '/api/events' || url.pathname === BASE + '/api/ping') {
+        return;
+    }
+
+    event.respondWith(
+        fetch(request)
+            .then((response) => {
+                if (response.ok) {
+                    const copy = response.clone();
+                    caches.open(CACHE).then((cache) => cache.put(request, copy));
+                }
+                return response;
+            })
+            .catch(() => caches.match(request).then((cached) => cached || Response.error()))
+    );
+});
+`
+
+// ServiceWorker serves the dashboard's service worker. The cache name follows the
+// build so a new release drops the old shell.
+func (h *Handlers) ServiceWorker(w http.ResponseWriter, r *http.Request) {
+	basePath := h.basePathFor(r)
+	shell := []string{basePath + "/", basePath + "/manifest.webmanifest", basePath + "/api/theme.css"}
+	fs.WalkDir(h.files, "static", func(filePath string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			shell = append(shell, basePath+"/"+filePath)
+		}
+		return nil
+	})
+
+	revision, builtAt := buildInfo()
+	cacheName, _ := json.Marshal("thinkdashboard-" + version + "-" + revision + "-" + builtAt)
+	shellJSON, _ := json.Marshal(shell)
+	baseJSON, _ := json.Marshal(basePath)
+
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, serviceWorkerTemplate, cacheName, shellJSON, baseJSON)
+}
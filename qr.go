@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// validateQRData accepts http(s) URLs and paths on the dashboard itself
+func validateQRData(data string) bool {
+	if strings.HasPrefix(data, "/") {
+		// "//host" would be a protocol-relative URL to another site
+		return !strings.HasPrefix(data, "//") && !strings.HasPrefix(data, "/\\")
+	}
+	parsed, err := url.Parse(data)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Hostname() != ""
+}
+
+// QRCode returns a PNG QR code for ?data=, an http(s) URL or a dashboard path,
+// ?size= pixels wide (64-1024, default 256)
+func (h *Handlers) QRCode(w http.ResponseWriter, r *http.Request) {
+	data := r.URL.Query().Get("data")
+	if data == "" || !validateQRData(data) {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidURL, "data must be an http(s) URL or a dashboard path")
+		return
+	}
+
+	// A phone scanning the code can't resolve a path, so it gets the full URL
+	if strings.HasPrefix(data, "/") {
+		data = h.absoluteURL(r, data)
+	}
+
+	size := 256
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		value, err := strconv.Atoi(sizeStr)
+		if err != nil || value < 64 || value > 1024 {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid size, must be between 64 and 1024")
+			return
+		}
+		size = value
+	}
+
+	png, err := qrcode.Encode(data, qrcode.Medium, size)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Unable to generate QR code")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(png)
+}
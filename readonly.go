@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// readOnlyEnabled reports whether READ_ONLY=true, which turns off every write endpoint
+func readOnlyEnabled() bool {
+	return strings.ToLower(os.Getenv("READ_ONLY")) == "true"
+}
+
+// readOnlyMiddleware rejects requests that could change data with a 403
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			writeJSONError(w, http.StatusForbidden, codeReadOnly, "read-only mode")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Go redirects to a bookmark, counting the visit and recording it in the audit log.
+// The bookmark is given by ?url=, which must belong to a bookmark (of ?page= when
+// set) so this can't be used as an open redirect, or by ?shortcut= looked up like
+// ResolveShortcut.
+func (h *Handlers) Go(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	pageID := 0
+	if pageIDStr := params.Get("page"); pageIDStr != "" {
+		value, err := strconv.Atoi(pageIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		pageID = value
+	}
+
+	var match indexedShortcut
+	found := false
+	switch {
+	case params.Get("shortcut") != "":
+		settings := h.settingsFor(r)
+		global := settings.GlobalShortcuts || pageID == 0
+		match, found = h.shortcuts.Lookup(params.Get("shortcut"), pageID, global, settings.CaseSensitiveShortcuts)
+	case params.Get("url") != "":
+		for _, page := range h.store.GetPages() {
+			if found || (pageID != 0 && page.ID != pageID) {
+				continue
+			}
+			for _, bookmark := range visibleBookmarks(h.store.GetBookmarksByPage(page.ID)) {
+				if sameBookmarkURL(bookmark.URL, params.Get("url")) {
+					match, found = indexedShortcut{Page: page.ID, Bookmark: bookmark}, true
+					break
+				}
+			}
+		}
+	default:
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "url or shortcut is required")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, codeBookmarkNotFound, "Bookmark not found")
+		return
+	}
+
+	// Read-only instances still redirect but don't write anything
+	if !h.readOnly {
+		if err := h.store.IncrementVisit(match.Page, match.Bookmark.Name, match.Bookmark.URL); err != nil {
+			log.Printf("Go: could not count the visit: %v", err)
+		}
+		h.audit.Record(r, auditEntry{Action: "visit", Page: match.Page, Name: match.Bookmark.Name, URL: match.Bookmark.URL})
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, match.Bookmark.URL, http.StatusFound)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// resetBackupDir is where Reset keeps the backup it takes before resetting
+var resetBackupDir = filepath.Join("data", "backups")
+
+// writeSafetyBackup saves everything Reset is about to replace as a zip in
+// resetBackupDir, in the format of GET /api/backup
+func (h *Handlers) writeSafetyBackup() (string, error) {
+	if err := os.MkdirAll(resetBackupDir, 0755); err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(resetBackupDir, "reset-"+time.Now().Format("20060102-150405")+".zip")
+	file, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	err = h.writeBackup(file, func(string) bool { return true })
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(backupPath)
+		return "", err
+	}
+	return filepath.ToSlash(backupPath), nil
+}
+
+// Reset puts pages, bookmarks, settings, colors and finders back to the state of a
+// new data directory, after saving them with writeSafetyBackup, and drops the
+// per-device settings. It only runs with ?confirm=true. Uploaded icons, fonts and
+// favicons are kept.
+func (h *Handlers) Reset(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("confirm") != "true" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Reset replaces all pages, bookmarks and settings; repeat the request with ?confirm=true")
+		return
+	}
+
+	backupPath, err := h.writeSafetyBackup()
+	if err != nil {
+		log.Printf("Reset: backup failed: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to back up the current data, nothing was reset")
+		return
+	}
+
+	defaultsDir, err := os.MkdirTemp("", "thinkdashboard-defaults-")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to prepare the default data")
+		return
+	}
+	defer os.RemoveAll(defaultsDir)
+	defaults := newFileStore(defaultsDir)
+	if err := defaults.writeDefaultFiles(); err != nil {
+		log.Printf("Reset: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to load the seed file, nothing was reset")
+		return
+	}
+	copyStoreData(defaults, h.store)
+
+	// Device copies would keep overriding the defaults. They're moved next to the
+	// backup, also when PER_DEVICE_SETTINGS is off but copies are left from before.
+	devices := h.devices
+	if devices == nil {
+		devices = &deviceStore{dir: deviceDir}
+	}
+	if err := devices.archive(strings.TrimSuffix(backupPath, ".zip") + "-devices"); err != nil {
+		log.Printf("Reset: could not move the device settings: %v", err)
+	}
+
+	log.Printf("Reset to defaults, the previous data is in %s", backupPath)
+	h.events.Publish("import", 0)
+	h.audit.Record(r, auditEntry{Action: "reset"})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "backup": backupPath})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rollupMaxAge      = time.Minute // Checks newer than this are reused instead of pinging again
+	rollupConcurrency = 8
+)
+
+// categoryStatus summarizes the status-checked bookmarks of one category
+type categoryStatus struct {
+	Category  string `json:"category"`  // Category ID
+	Up        int    `json:"up"`        // Bookmarks online
+	Total     int    `json:"total"`     // Bookmarks checked
+	WorstPing *int64 `json:"worstPing"` // Slowest response time in ms among the online ones
+}
+
+// PingRollup returns an up/total summary per category of ?page= for the bookmarks
+// with CheckStatus, in category order. Results from the ping history that are
+// under a minute old are reused; the other bookmarks are pinged.
+func (h *Handlers) PingRollup(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	pageID, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+		return
+	}
+
+	// Check every bookmark that can be checked, a few at a time
+	bookmarks := visibleBookmarks(h.store.GetBookmarksByPage(pageID))
+	results := make([]*pingResult, len(bookmarks))
+	semaphore := make(chan struct{}, rollupConcurrency)
+	var wg sync.WaitGroup
+	for i, bookmark := range bookmarks {
+		if !bookmark.CheckStatus {
+			continue
+		}
+		if sample, ok := h.pingHistory.Latest(bookmark.URL); ok && time.Since(sample.Time) < rollupMaxAge {
+			results[i] = &pingResult{Status: sample.Status, Ping: sample.Ping}
+			continue
+		}
+		parsedURL, err := url.Parse(bookmark.URL)
+		if err != nil {
+			continue
+		}
+		targetURL, err := h.pingTarget(bookmark, parsedURL)
+		if err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, bookmark Bookmark) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			result := h.pingBookmark(bookmark, targetURL, false)
+			results[i] = &result
+		}(i, bookmark)
+	}
+	wg.Wait()
+
+	// Group the results by category, keeping the page's category order
+	rollup := []categoryStatus{}
+	positions := make(map[string]int)
+	for _, category := range h.store.GetCategoriesByPage(pageID) {
+		positions[category.ID] = len(rollup)
+		rollup = append(rollup, categoryStatus{Category: category.ID})
+	}
+	for i, bookmark := range bookmarks {
+		result := results[i]
+		if result == nil {
+			continue
+		}
+		position, ok := positions[bookmark.Category]
+		if !ok {
+			position = len(rollup)
+			positions[bookmark.Category] = position
+			rollup = append(rollup, categoryStatus{Category: bookmark.Category})
+		}
+		status := &rollup[position]
+		status.Total++
+		if result.Status == "online" {
+			status.Up++
+			if result.Ping != nil && (status.WorstPing == nil || *result.Ping > *status.WorstPing) {
+				status.WorstPing = result.Ping
+			}
+		}
+	}
+
+	// Categories without status-checked bookmarks have nothing to report
+	summary := make([]categoryStatus, 0, len(rollup))
+	for _, status := range rollup {
+		if status.Total > 0 {
+			summary = append(summary, status)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
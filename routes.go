@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// routeDescriptions documents every route, by method and path template. Routes
+// without a method matcher (the file servers) use "*".
+var routeDescriptions = map[string]string{
+	"GET /":                     "Dashboard page",
+	"GET /go":                   "Redirect to the bookmark of ?url= or ?shortcut=, counting the visit",
+	"GET /config":               "Configuration page",
+	"GET /colors":               "Color customization page",
+	"GET /manifest.webmanifest": "Web app manifest",
+	"GET /sw.js":                "Service worker for offline use",
+	"GET /health":               "Liveness check",
+
+	"GET /api/routes":       "This list of routes",
+	"GET /api/all":          "Settings, pages, colors, finders, categories and bookmarks of one page for the first render",
+	"GET /api/snapshot":     "All pages with their bookmarks, settings, colors and finders",
+	"GET /api/sitemap.json": "Flat list of every bookmark with its page and category",
+
+	"GET /api/bookmarks":                     "Bookmarks of ?page=, or of all pages with ?all=true",
+	"POST /api/bookmarks":                    "Replace the bookmarks of ?page=",
+	"DELETE /api/bookmarks":                  "Delete a bookmark",
+	"POST /api/bookmarks/add":                "Add a bookmark to a page",
+	"GET /api/bookmarks/pinned":              "Pinned bookmarks of every page",
+	"POST /api/bookmarks/assign-category":    "Move bookmarks of ?page= to a category by URL",
+	"POST /api/bookmarks/validate":           "Check bookmarks without saving them",
+	"GET /api/shortcuts/resolve":             "Find the bookmark of a shortcut",
+	"GET /api/palette":                       "Entries for the command palette",
+	"GET /api/search":                        "Search bookmarks across pages",
+	"GET /api/pages":                         "Pages in display order",
+	"POST /api/pages":                        "Save the pages and their order",
+	"POST /api/pages/merge":                  "Merge one page into another",
+	"DELETE /api/pages/{id:[0-9]+}":          "Delete a page",
+	"PATCH /api/pages/{id:[0-9]+}":           "Rename a page",
+	"GET /api/pages/{id:[0-9]+}/full":        "A page with its categories and bookmarks",
+	"POST /api/pages/{id:[0-9]+}/clear":      "Remove every bookmark of a page",
+	"GET /api/pages/{id:[0-9]+}/favicon":     "Favicon of a page, or the global one",
+	"POST /api/pages/{id:[0-9]+}/favicon":    "Upload the favicon of a page",
+	"DELETE /api/pages/{id:[0-9]+}/favicon":  "Remove the favicon of a page",
+	"GET /api/categories":                    "Categories of ?page=",
+	"POST /api/categories":                   "Replace the categories of ?page=",
+	"POST /api/categories/collapse":          "Collapse or expand a category",
+	"POST /api/categories/repair":            "Move bookmarks whose category is missing into a fallback category",
+	"POST /api/categories/reorder":           "Reorder the categories of ?page= by ID",
+	"DELETE /api/categories/{id}":            "Delete a category of ?page=, moving its bookmarks to ?reassignTo=",
+	"GET /api/finders":                       "Search finders",
+	"POST /api/finders":                      "Replace the finders",
+	"GET /api/settings":                      "Settings, as a settings.json download with ?download=true",
+	"POST /api/settings":                     "Save the settings",
+	"GET /api/colors":                        "Colors and custom themes, as a colors.json download with ?download=true",
+	"POST /api/colors":                       "Save the colors",
+	"POST /api/colors/reset":                 "Restore the default colors, keeping custom themes",
+	"GET /api/colors/custom-themes":          "IDs and names of the custom themes, in display order",
+	"POST /api/colors/custom-themes/reorder": "Set the display order of the custom themes",
+	"PATCH /api/colors/custom-themes/{id}":   "Rename a custom theme's ID or name",
+	"GET /api/theme.css":                     "CSS variables of the custom themes",
+	"GET /api/font.css":                      "@font-face rules and font variables",
+	"GET /api/themepack/export":              "Download the theme as a theme pack zip",
+	"POST /api/themepack/import":             "Apply a theme pack zip",
+	"GET /api/locales":                       "Available languages",
+	"GET /api/locales/{lang}":                "Translations of a language",
+	"POST /api/locales/{lang}":               "Upload translations for a language",
+	"GET /api/favicon":                       "The favicon, resized to the closest of 16, 32, 180, 192 or 512 with ?size=",
+	"POST /api/favicon":                      "Upload the favicon",
+	"POST /api/font":                         "Upload a font",
+	"GET /api/fonts":                         "Uploaded fonts",
+	"DELETE /api/fonts/{id:[0-9a-f]+}":       "Delete an uploaded font",
+	"POST /api/icon":                         "Upload a bookmark icon",
+	"GET /api/icon/letter":                   "Letter icon for a bookmark without one",
+	"GET /api/favicon/proxy":                 "Fetch the favicon of a site",
+	"GET /api/backup":                        "Download a backup zip of the data directory",
+	"POST /api/import":                       "Restore files from a backup",
+	"POST /api/reset":                        "Back up all data to data/backups, then reset to the defaults (?confirm=true)",
+	"GET /api/export/csv":                    "Export bookmarks as CSV",
+	"POST /api/import/csv":                   "Import bookmarks from CSV",
+	"GET /api/export/opml":                   "Export bookmarks as OPML",
+	"POST /api/import/opml":                  "Import bookmarks from OPML",
+	"POST /api/import/pocket":                "Import links from a Pocket or Instapaper export",
+	"GET /api/ping":                          "Status check of a bookmark URL",
+	"GET /api/ping/history":                  "Recent status checks of a bookmark",
+	"GET /api/ping/rollup":                   "Status summary per category of ?page=",
+	"GET /api/linkcheck":                     "Check the bookmarks of ?page= for redirects, dead links and certificate errors",
+	"GET /api/qr":                            "QR code PNG for a URL",
+	"GET /api/og-image":                      "Link preview image with the dashboard title and page count",
+	"GET /api/events":                        "Server-sent events when data changes",
+	"GET /api/diagnostics":                   "Health of the data files",
+	"GET /api/storage":                       "Disk usage of the data directory",
+	"GET /api/audit":                         "Recent bookmark changes",
+	"GET /api/version":                       "Version and build information",
+	"GET /api/capabilities":                  "Optional server features that are active",
+	"GET /api/update":                        "Whether a newer release is available",
+
+	"* /data/":    "Uploaded favicon, fonts and icons",
+	"* /locales/": "Translation files",
+	"* /static/":  "Scripts, stylesheets and images",
+}
+
+// routeInfo describes one method of a route in GET /api/routes
+type routeInfo struct {
+	Method      string `json:"method"` // "*" for any method
+	Path        string `json:"path"`   // Path template, with {name:pattern} variables
+	Description string `json:"description"`
+}
+
+// describeRoutes walks the router and pairs every route with its description,
+// logging routes without one and descriptions without a route so the two lists
+// can't drift apart
+func describeRoutes(router *mux.Router) []routeInfo {
+	routes := []routeInfo{}
+	described := make(map[string]bool)
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"*"}
+		}
+		for _, method := range methods {
+			key := method + " " + path
+			described[key] = true
+			description, ok := routeDescriptions[key]
+			if !ok {
+				log.Printf("Warning: route %s has no description", key)
+			}
+			routes = append(routes, routeInfo{Method: method, Path: path, Description: description})
+		}
+		return nil
+	})
+
+	for key := range routeDescriptions {
+		if !described[key] {
+			log.Printf("Warning: route %s is described but not registered", key)
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return routes
+}
+
+// Routes lists the routes of the API with their methods and a short description
+func (h *Handlers) Routes(w http.ResponseWriter, r *http.Request) {
+	basePath := h.basePathFor(r)
+	routes := make([]routeInfo, len(h.routes))
+	for i, route := range h.routes {
+		route.Path = basePath + route.Path
+		routes[i] = route
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routes)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// parseClock parses an "HH:MM" time of day into minutes after midnight
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day (HH:MM)", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// validateSchedule checks the format of a bookmark's VisibleFrom and VisibleTo
+func validateSchedule(bookmark Bookmark) error {
+	for _, value := range []string{bookmark.VisibleFrom, bookmark.VisibleTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := parseClock(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visibleAt reports whether a bookmark is within its schedule at now. A missing
+// bound leaves that side open, and a window like 22:00-06:00 wraps past midnight.
+// Equal bounds mean the whole day.
+func visibleAt(bookmark Bookmark, now time.Time) bool {
+	if bookmark.VisibleFrom == "" && bookmark.VisibleTo == "" {
+		return true
+	}
+
+	from, to := 0, 24*60
+	if bookmark.VisibleFrom != "" {
+		value, err := parseClock(bookmark.VisibleFrom)
+		if err != nil {
+			return true
+		}
+		from = value
+	}
+	if bookmark.VisibleTo != "" {
+		value, err := parseClock(bookmark.VisibleTo)
+		if err != nil {
+			return true
+		}
+		to = value
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if from == to {
+		return true
+	}
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// scheduledBookmarks returns the bookmarks visible at now
+func scheduledBookmarks(bookmarks []Bookmark, now time.Time) []Bookmark {
+	visible := make([]Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		if visibleAt(bookmark, now) {
+			visible = append(visible, bookmark)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Scores of the match tiers, highest first
+const (
+	scoreShortcut    = 100 // The query is the shortcut
+	scorePrefix      = 75  // The name starts with the query
+	scoreWordPrefix  = 50  // A word in the name starts with the query
+	scoreContains    = 25  // The name contains the query
+	scoreSubsequence = 10  // The letters of the query appear in order in the name
+)
+
+// matchScore ranks how well query matches a name and shortcut, 0 meaning it
+// doesn't. With startWith only shortcut and name prefix matches count, like
+// FuzzySuggestionsStartWith on the dashboard. query must be lowercase.
+func matchScore(query, name, shortcut string, startWith bool) int {
+	if query == "" {
+		return 0
+	}
+	name = strings.ToLower(name)
+	switch {
+	case shortcut != "" && strings.ToLower(shortcut) == query:
+		return scoreShortcut
+	case strings.HasPrefix(name, query):
+		return scorePrefix
+	case startWith:
+		return 0
+	case hasWordPrefix(name, query):
+		return scoreWordPrefix
+	case strings.Contains(name, query):
+		return scoreContains
+	case isSubsequence(query, name):
+		return scoreSubsequence
+	}
+	return 0
+}
+
+// hasWordPrefix reports whether a word of name other than the first starts with query
+func hasWordPrefix(name, query string) bool {
+	previous := ' '
+	for i, r := range name {
+		if i > 0 && !unicode.IsLetter(previous) && !unicode.IsDigit(previous) && strings.HasPrefix(name[i:], query) {
+			return true
+		}
+		previous = r
+	}
+	return false
+}
+
+// isSubsequence reports whether the runes of query appear in text in order
+func isSubsequence(query, text string) bool {
+	remaining := []rune(query)
+	for _, r := range text {
+		if len(remaining) > 0 && r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+// searchResult is a bookmark matching a search, with the page it's on
+type searchResult struct {
+	Page     int      `json:"page"`
+	Bookmark Bookmark `json:"bookmark"`
+	Score    int      `json:"score"`
+}
+
+// Search returns the bookmarks matching ?q= by shortcut or name, best first: an
+// exact shortcut, then names starting with the query, names with a word starting
+// with it, names containing it and names containing its letters in order. Ties
+// go to the most visited bookmark. ?page= limits the search to a page unless
+// GlobalShortcuts is on, ?startWith= overrides FuzzySuggestionsStartWith and
+// ?limit= caps the count (1-200, default 20).
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := strings.ToLower(strings.TrimSpace(params.Get("q")))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Query is required")
+		return
+	}
+
+	pageID := 0
+	if pageIDStr := params.Get("page"); pageIDStr != "" {
+		value, err := strconv.Atoi(pageIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		pageID = value
+	}
+
+	limit := 20
+	if limitStr := params.Get("limit"); limitStr != "" {
+		value, err := strconv.Atoi(limitStr)
+		if err != nil || value < 1 || value > 200 {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid limit, must be between 1 and 200")
+			return
+		}
+		limit = value
+	}
+
+	settings := h.settingsFor(r)
+	startWith := settings.FuzzySuggestionsStartWith
+	if startWithStr := params.Get("startWith"); startWithStr != "" {
+		startWith = startWithStr == "true"
+	}
+	global := settings.GlobalShortcuts || pageID == 0
+
+	results := []searchResult{}
+	for _, page := range h.store.GetPages() {
+		if !global && page.ID != pageID {
+			continue
+		}
+		bookmarks := visibleBookmarks(h.store.GetBookmarksByPage(page.ID))
+		if settings.RespectSchedules {
+			bookmarks = scheduledBookmarks(bookmarks, time.Now())
+		}
+		for _, bookmark := range bookmarks {
+			if score := matchScore(query, bookmark.Name, bookmark.Shortcut, startWith); score > 0 {
+				results = append(results, searchResult{Page: page.ID, Bookmark: bookmark, Score: score})
+			}
+		}
+	}
+
+	// Among equal scores, more visited bookmarks come first, then those on the
+	// requested page
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Bookmark.VisitCount != results[j].Bookmark.VisitCount {
+			return results[i].Bookmark.VisitCount > results[j].Bookmark.VisitCount
+		}
+		return results[i].Page == pageID && results[j].Page != pageID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// seedBundle is the data a fresh instance starts with when SEED_FILE is set. The
+// file can be a single page export (/api/pages/{id}/full), an array of them, or a
+// snapshot (/api/snapshot) that also carries settings, colors and finders.
+type seedBundle struct {
+	PageWithBookmarks
+	Pages    []PageWithBookmarks `json:"pages"`
+	Settings json.RawMessage     `json:"settings"` // Fields left out keep their defaults
+	Colors   json.RawMessage     `json:"colors"`
+	Finders  []Finder            `json:"finders"`
+}
+
+// loadSeedBundle reads the file named by SEED_FILE, returning nil when it isn't set
+func loadSeedBundle() (*seedBundle, error) {
+	seedFile := os.Getenv("SEED_FILE")
+	if seedFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(seedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle seedBundle
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &bundle.Pages)
+	} else {
+		err = json.Unmarshal(trimmed, &bundle)
+		if err == nil && (bundle.Page.Name != "" || bundle.Bookmarks != nil) {
+			bundle.Pages = append([]PageWithBookmarks{bundle.PageWithBookmarks}, bundle.Pages...)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", seedFile, err)
+	}
+	if len(bundle.Pages) == 0 {
+		return nil, fmt.Errorf("%s: no pages found", seedFile)
+	}
+	return &bundle, nil
+}
+
+// seedFromFile fills an empty store from SEED_FILE and reports whether it did.
+// A seed file that can't be loaded stops the server rather than silently starting
+// with the sample bookmarks.
+func seedFromFile(store Store) bool {
+	seeded, err := seedStore(store)
+	if err != nil {
+		log.Fatalf("Failed to load seed file: %v", err)
+	}
+	return seeded
+}
+
+// seedStore fills store from SEED_FILE, reporting whether it did, or an error if
+// the file can't be loaded, in which case the store is left alone
+func seedStore(store Store) (bool, error) {
+	bundle, err := loadSeedBundle()
+	if err != nil || bundle == nil {
+		return false, err
+	}
+
+	// Pages without an ID are numbered after the highest one
+	nextID := 1
+	for _, page := range bundle.Pages {
+		nextID = max(nextID, page.Page.ID+1)
+	}
+
+	order := make([]int, 0, len(bundle.Pages))
+	for _, page := range bundle.Pages {
+		if page.Page.ID <= 0 {
+			page.Page.ID = nextID
+			nextID++
+		}
+		if page.Page.Name == "" {
+			page.Page.Name = fmt.Sprintf("Page %d", page.Page.ID)
+		}
+		savePageWithCategories(store, page.Page, page.Categories, page.Bookmarks)
+		order = append(order, page.Page.ID)
+	}
+	store.SavePageOrder(order)
+
+	if bundle.Finders != nil {
+		store.SaveFinders(bundle.Finders)
+	}
+	settings := getDefaultSettings()
+	if bundle.Settings != nil {
+		json.Unmarshal(bundle.Settings, &settings)
+	}
+	settings.CurrentPage = order[0]
+	store.SaveSettings(settings)
+	colors := getDefaultColors()
+	if bundle.Colors != nil {
+		json.Unmarshal(bundle.Colors, &colors)
+	}
+	store.SaveColors(colors)
+
+	log.Printf("Seeded %d page(s) from %s", len(order), os.Getenv("SEED_FILE"))
+	return true, nil
+}
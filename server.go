@@ -0,0 +1,106 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// serverConfig holds the HTTP server limits, configurable via the environment:
+//
+//	READ_TIMEOUT=30s     time to read a whole request, including the body
+//	WRITE_TIMEOUT=60s    time to write a response
+//	IDLE_TIMEOUT=120s    how long keep-alive connections stay open between requests
+//	MAX_HEADER_BYTES=1048576    largest accepted request headers
+//	MAX_BODY_BYTES=67108864     largest accepted request body
+type serverConfig struct {
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	idleTimeout    time.Duration
+	maxHeaderBytes int
+	maxBodyBytes   int64
+}
+
+// envDuration reads a duration such as "45s" or "2m" from the environment. A bare
+// number is taken as seconds and 0 disables the timeout.
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Printf("Warning: invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// envBytes reads a positive byte count from the environment
+func envBytes(name string, fallback int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		log.Printf("Warning: invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return size
+}
+
+// loadServerConfig reads the server limits from the environment
+func loadServerConfig() serverConfig {
+	return serverConfig{
+		readTimeout:    envDuration("READ_TIMEOUT", 30*time.Second),
+		writeTimeout:   envDuration("WRITE_TIMEOUT", 60*time.Second),
+		idleTimeout:    envDuration("IDLE_TIMEOUT", 120*time.Second),
+		maxHeaderBytes: int(envBytes("MAX_HEADER_BYTES", 1<<20)),
+		maxBodyBytes:   envBytes("MAX_BODY_BYTES", 64<<20),
+	}
+}
+
+// newServer returns an http.Server for addr with the configured limits. Headers
+// always have to arrive within 10 seconds, whatever READ_TIMEOUT is, so slow
+// clients can't hold connections open.
+func newServer(addr string, handler http.Handler, config serverConfig) *http.Server {
+	headerTimeout := 10 * time.Second
+	if config.readTimeout > 0 && config.readTimeout < headerTimeout {
+		headerTimeout = config.readTimeout
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           limitBody(handler, config.maxBodyBytes),
+		ReadHeaderTimeout: headerTimeout,
+		ReadTimeout:       config.readTimeout,
+		WriteTimeout:      config.writeTimeout,
+		IdleTimeout:       config.idleTimeout,
+		MaxHeaderBytes:    config.maxHeaderBytes,
+	}
+}
+
+// limitBody caps the size of every request body
+func limitBody(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withoutDeadlines lifts the server's read and write timeouts for handlers that
+// legitimately run long: the event stream, backups and imports, and pings of slow
+// hosts. Their own limits still apply.
+func withoutDeadlines(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		controller := http.NewResponseController(w)
+		controller.SetReadDeadline(time.Time{})
+		controller.SetWriteDeadline(time.Time{})
+		next(w, r)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultShortcutPattern is used when Settings.ShortcutPattern is empty. Letters
+// and digits only, so shortcuts work as is in /go/{shortcut} URLs.
+const defaultShortcutPattern = `^[A-Za-z0-9]+$`
+
+// compileShortcutPattern compiles the shortcut pattern from settings, or the
+// default pattern when it's empty
+func compileShortcutPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = defaultShortcutPattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// shortcutValidator returns a function that checks shortcuts against the pattern
+// from settings. An invalid pattern falls back to the default one.
+func shortcutValidator(settings Settings) func(shortcut string) error {
+	pattern, err := compileShortcutPattern(settings.ShortcutPattern)
+	if err != nil {
+		pattern = regexp.MustCompile(defaultShortcutPattern)
+	}
+	return func(shortcut string) error {
+		if shortcut == "" {
+			return nil
+		}
+		// Interleave mode reads a leading slash as the start of a shortcut search
+		if strings.HasPrefix(shortcut, "/") {
+			return fmt.Errorf("shortcut %q can't start with \"/\"", shortcut)
+		}
+		if !pattern.MatchString(shortcut) {
+			return fmt.Errorf("shortcut %q doesn't match %s", shortcut, pattern)
+		}
+		return nil
+	}
+}
+
+// validateShortcuts checks the shortcuts of bookmarks against the pattern from settings
+func validateShortcuts(bookmarks []Bookmark, settings Settings) error {
+	validateShortcut := shortcutValidator(settings)
+	for _, bookmark := range bookmarks {
+		if err := validateShortcut(bookmark.Shortcut); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shortcutKeyFunc returns the function that maps shortcuts to the key under which
+// they collide: the shortcut itself with CaseSensitiveShortcuts, else its lowercase
+func shortcutKeyFunc(settings Settings) func(shortcut string) string {
+	if settings.CaseSensitiveShortcuts {
+		return func(shortcut string) string { return shortcut }
+	}
+	return strings.ToLower
+}
+
+// indexedShortcut is a bookmark in the shortcut index along with its page
+type indexedShortcut struct {
+	Page     int      `json:"page"`
+	Bookmark Bookmark `json:"bookmark"`
+}
+
+// shortcutIndex maps shortcuts to the bookmarks that use them across all pages.
+// It's built on the first lookup and dropped whenever data is saved through the API.
+type shortcutIndex struct {
+	store   Store
+	mutex   sync.Mutex
+	built   bool
+	entries map[string][]indexedShortcut // By lowercase shortcut, in page order
+}
+
+func newShortcutIndex(store Store, events *eventHub) *shortcutIndex {
+	index := &shortcutIndex{store: store}
+	events.Listen(func(changeEvent) { index.invalidate() })
+	return index
+}
+
+func (si *shortcutIndex) invalidate() {
+	si.mutex.Lock()
+	si.built = false
+	si.entries = nil
+	si.mutex.Unlock()
+}
+
+// build must be called with the mutex held
+func (si *shortcutIndex) build() {
+	si.entries = make(map[string][]indexedShortcut)
+	for _, page := range si.store.GetPages() {
+		for _, bookmark := range si.store.GetBookmarksByPage(page.ID) {
+			if bookmark.Shortcut == "" || bookmark.Hidden {
+				continue
+			}
+			key := strings.ToLower(bookmark.Shortcut)
+			si.entries[key] = append(si.entries[key], indexedShortcut{Page: page.ID, Bookmark: bookmark})
+		}
+	}
+	si.built = true
+}
+
+// Lookup returns the bookmark for shortcut, preferring one on pageID. Bookmarks
+// on other pages only match when global is set, and the case must match when
+// caseSensitive is set.
+func (si *shortcutIndex) Lookup(shortcut string, pageID int, global, caseSensitive bool) (indexedShortcut, bool) {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+
+	if !si.built {
+		si.build()
+	}
+
+	var matches []indexedShortcut
+	for _, match := range si.entries[strings.ToLower(shortcut)] {
+		if !caseSensitive || match.Bookmark.Shortcut == shortcut {
+			matches = append(matches, match)
+		}
+	}
+	for _, match := range matches {
+		if match.Page == pageID {
+			return match, true
+		}
+	}
+	if global && len(matches) > 0 {
+		return matches[0], true
+	}
+	return indexedShortcut{}, false
+}
+
+// ResolveShortcut returns the bookmark a shortcut opens as {"page": n, "bookmark": {...}}.
+// The optional page parameter is the page the shortcut was typed on; bookmarks on
+// other pages are found when GlobalShortcuts is enabled.
+func (h *Handlers) ResolveShortcut(w http.ResponseWriter, r *http.Request) {
+	shortcut := r.URL.Query().Get("shortcut")
+	if shortcut == "" {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Shortcut is required")
+		return
+	}
+
+	pageID := 0
+	if pageIDStr := r.URL.Query().Get("page"); pageIDStr != "" {
+		value, err := strconv.Atoi(pageIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		pageID = value
+	}
+
+	settings := h.settingsFor(r)
+	global := settings.GlobalShortcuts || pageID == 0
+	match, ok := h.shortcuts.Lookup(shortcut, pageID, global, settings.CaseSensitiveShortcuts)
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, codeNotFound, "shortcut not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
+}
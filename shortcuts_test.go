@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestDefaultShortcutPattern(t *testing.T) {
+	validate := shortcutValidator(Settings{})
+	for _, shortcut := range []string{"", "gh", "GH2", "a1b2"} {
+		if err := validate(shortcut); err != nil {
+			t.Errorf("shortcut %q rejected: %v", shortcut, err)
+		}
+	}
+	for _, shortcut := range []string{"gh-p", "a.b", "a?b", "a#b", "a%20", "a b", "/gh", "é"} {
+		if validate(shortcut) == nil {
+			t.Errorf("shortcut %q accepted by the default pattern", shortcut)
+		}
+	}
+}
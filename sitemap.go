@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// sitemapEntry is one bookmark in the flat sitemap, with the page and category it
+// belongs to
+type sitemapEntry struct {
+	Page     int    `json:"page"`
+	PageName string `json:"pageName"`
+	Category string `json:"category"` // Category name, or its ID when the page doesn't list it
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Shortcut string `json:"shortcut"`
+}
+
+// Sitemap returns every bookmark of every page, hidden ones included, as a flat
+// list. Entries follow the page order, then the category order of each page, then
+// the bookmark order, so the output only changes when the data does.
+func (h *Handlers) Sitemap(w http.ResponseWriter, r *http.Request) {
+	entries := []sitemapEntry{}
+	for _, page := range h.store.GetPages() {
+		pageWithBookmarks, err := h.store.GetPageWithBookmarks(page.ID)
+		if err != nil {
+			continue
+		}
+
+		position := make(map[string]int)
+		names := make(map[string]string)
+		for i, category := range pageWithBookmarks.Categories {
+			position[category.ID] = i
+			names[category.ID] = category.Name
+		}
+
+		// Bookmarks of unknown categories go last, grouped by category
+		grouped := make([][]sitemapEntry, len(pageWithBookmarks.Categories))
+		for _, bookmark := range pageWithBookmarks.Bookmarks {
+			index, ok := position[bookmark.Category]
+			if !ok {
+				index = len(grouped)
+				position[bookmark.Category] = index
+				grouped = append(grouped, nil)
+			}
+			category := names[bookmark.Category]
+			if category == "" {
+				category = bookmark.Category
+			}
+			grouped[index] = append(grouped[index], sitemapEntry{
+				Page:     page.ID,
+				PageName: page.Name,
+				Category: category,
+				Name:     bookmark.Name,
+				URL:      bookmark.URL,
+				Shortcut: bookmark.Shortcut,
+			})
+		}
+		for _, group := range grouped {
+			entries = append(entries, group...)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshot is everything the dashboard needs to render, for clients that keep an
+// offline copy
+type snapshot struct {
+	Pages    []PageWithBookmarks `json:"pages"` // In display order
+	Settings Settings            `json:"settings"`
+	Colors   ColorTheme          `json:"colors"`
+	Finders  []Finder            `json:"finders"`
+}
+
+// notModified sets the ETag header and reports whether the client already has that
+// version, either through If-None-Match or an etag query parameter, in which case
+// it has written a 304 response
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	known := r.URL.Query().Get("etag")
+	if known != "" && strings.Trim(known, `"`) == strings.Trim(etag, `"`) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// pageETag identifies the current version of a page's bookmarks and categories
+func pageETag(page Page) string {
+	return fmt.Sprintf(`"page-%d-%d"`, page.ID, page.UpdatedAt)
+}
+
+// currentPageETag returns the ETag of a page as it is now, and false when there's
+// no such page
+func (h *Handlers) currentPageETag(pageID int) (string, bool) {
+	for _, page := range h.store.GetPages() {
+		if page.ID == pageID {
+			return pageETag(page), true
+		}
+	}
+	return "", false
+}
+
+// ifMatchFails reports whether the request has an If-Match header and none of its
+// ETags is etag. Requests without the header always pass, so clients that don't
+// track versions keep working.
+func ifMatchFails(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || (candidate == "*" && etag != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshotETag is derived from the latest UpdatedAt across pages, settings and
+// colors. The page IDs are included as well because deleting or reordering pages
+// doesn't stamp anything, and so are the finders, which have no UpdatedAt.
+func snapshotETag(pages []Page, settings Settings, colors ColorTheme, finders []Finder) string {
+	latest := max(settings.UpdatedAt, colors.UpdatedAt)
+	hash := fnv.New32a()
+	for _, page := range pages {
+		latest = max(latest, page.UpdatedAt)
+		fmt.Fprintf(hash, "%d,", page.ID)
+	}
+	json.NewEncoder(hash).Encode(finders)
+	return fmt.Sprintf(`"snapshot-%d-%x"`, latest, hash.Sum32())
+}
+
+// Snapshot returns all pages with their categories and bookmarks, the settings,
+// colors and finders in one response
+func (h *Handlers) Snapshot(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+
+	pages := h.store.GetPages()
+	settings := h.settingsFor(r)
+	colors := h.colorsFor(r)
+	finders := h.store.GetFinders()
+	if notModified(w, r, snapshotETag(pages, settings, colors, finders)) {
+		return
+	}
+
+	data := snapshot{
+		Pages:    make([]PageWithBookmarks, 0, len(pages)),
+		Settings: settings,
+		Colors:   colors,
+		Finders:  finders,
+	}
+	for _, page := range pages {
+		pageWithBookmarks, err := h.store.GetPageWithBookmarks(page.ID)
+		if err != nil {
+			continue
+		}
+		data.Pages = append(data.Pages, pageWithBookmarks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// startupData is what the dashboard needs for its first render, see All
+type startupData struct {
+	Page       int              `json:"page"` // Page the categories and bookmarks belong to
+	Settings   settingsResponse `json:"settings"`
+	Pages      []Page           `json:"pages"`
+	Colors     ColorTheme       `json:"colors"`
+	Finders    []Finder         `json:"finders"`
+	Categories []Category       `json:"categories"`
+	Bookmarks  []Bookmark       `json:"bookmarks"`
+}
+
+// All returns the settings, pages, colors and finders together with the categories
+// and visible bookmarks of ?page=, or of the current page without it, so the
+// dashboard can start with one request instead of several
+func (h *Handlers) All(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	pages := h.store.GetPages()
+	settings := h.settingsFor(r)
+	colors := h.colorsFor(r)
+	finders := h.store.GetFinders()
+
+	pageID := settings.CurrentPage
+	if pageIDStr := r.URL.Query().Get("page"); pageIDStr != "" {
+		var err error
+		if pageID, err = strconv.Atoi(pageIDStr); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidPageID, "Invalid page ID")
+			return
+		}
+		if !h.store.PageExists(pageID) {
+			writeJSONError(w, http.StatusNotFound, codePageNotFound, "Page not found")
+			return
+		}
+	} else if !h.store.PageExists(pageID) && len(pages) > 0 {
+		pageID = pages[0].ID
+		settings.CurrentPage = pageID
+	}
+
+	// Schedules change what's visible over time, so the minute goes into the ETag
+	// besides the UpdatedAt of everything else
+	serverSettings := h.store.GetSettings()
+	hash := fnv.New32a()
+	if serverSettings.RespectSchedules {
+		fmt.Fprint(hash, time.Now().Format("15:04"))
+	}
+	etag := fmt.Sprintf(`"all-%d-%s-%x"`, pageID, strings.Trim(snapshotETag(pages, settings, colors, finders), `"`), hash.Sum32())
+	if notModified(w, r, etag) {
+		return
+	}
+
+	bookmarks := visibleBookmarks(h.store.GetBookmarksByPage(pageID))
+	if serverSettings.RespectSchedules {
+		bookmarks = scheduledBookmarks(bookmarks, time.Now())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(startupData{
+		Page:       pageID,
+		Settings:   h.settingsResponse(r, settings),
+		Pages:      pages,
+		Colors:     colors,
+		Finders:    finders,
+		Categories: h.store.GetCategoriesByPage(pageID),
+		Bookmarks:  bookmarks,
+	})
+}
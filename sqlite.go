@@ -0,0 +1,1047 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables used by SQLiteStore. Bookmarks, categories and
+// pages keep their searchable fields in dedicated columns and the full record as
+// JSON in the data column, so new model fields round-trip without a schema change.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS pages (
+	id   INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS page_order (
+	position INTEGER PRIMARY KEY,
+	page_id  INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS categories (
+	page_id  INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	id       TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (page_id, position)
+);
+CREATE TABLE IF NOT EXISTS bookmarks (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	page_id  INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	name     TEXT NOT NULL,
+	url      TEXT NOT NULL,
+	shortcut TEXT NOT NULL,
+	category TEXT NOT NULL,
+	data     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS bookmarks_page ON bookmarks (page_id, position);
+CREATE INDEX IF NOT EXISTS bookmarks_url ON bookmarks (url);
+CREATE INDEX IF NOT EXISTS bookmarks_shortcut ON bookmarks (shortcut);
+CREATE TABLE IF NOT EXISTS finders (
+	position   INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	search_url TEXT NOT NULL,
+	shortcut   TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS settings (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS colors (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+`
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx so helpers can run
+// inside or outside a transaction
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SQLiteStore implements Store on top of a single SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at dbPath, creates the schema
+// and, on first run, imports the JSON files in dataDir if there are any
+func NewSQLiteStore(dbPath, dataDir string) (*SQLiteStore, error) {
+	store, err := openSQLiteStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if store.isEmpty() {
+		if err := store.initialize(dataDir); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// openSQLiteStore opens the database at dbPath and creates the schema without
+// adding any data
+func openSQLiteStore(dbPath string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// isEmpty reports whether the database holds no pages and no settings yet
+func (s *SQLiteStore) isEmpty() bool {
+	var count int
+	err := s.db.QueryRow(`SELECT (SELECT COUNT(*) FROM pages) + (SELECT COUNT(*) FROM settings)`).Scan(&count)
+	return err == nil && count == 0
+}
+
+// initialize fills an empty database, importing the JSON files in dataDir when
+// present, then SEED_FILE, and falling back to the same defaults the file store writes
+func (s *SQLiteStore) initialize(dataDir string) error {
+	matches, _ := filepath.Glob(filepath.Join(dataDir, "bookmarks-*.json"))
+	if len(matches) > 0 {
+		log.Printf("Importing JSON data from %s into SQLite", dataDir)
+		copyStoreData(newFileStore(dataDir), s)
+		return nil
+	}
+	if seedFromFile(s) {
+		return nil
+	}
+
+	defaultPage := getDefaultMainPage()
+	s.SaveCategoriesByPage(defaultPage.Page.ID, defaultPage.Categories)
+	s.SavePage(defaultPage.Page, defaultPage.Bookmarks)
+	s.SavePageOrder([]int{defaultPage.Page.ID})
+	s.SaveSettings(getDefaultSettings())
+	s.SaveColors(getDefaultColors())
+	return nil
+}
+
+// withTx runs fn inside a transaction, committing on success
+func (s *SQLiteStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) pageExists(q sqlQuerier, pageID int) bool {
+	var count int
+	if err := q.QueryRow(`SELECT COUNT(*) FROM pages WHERE id = ?`, pageID).Scan(&count); err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// savePageRow inserts or updates the page metadata, stamping its UpdatedAt
+func (s *SQLiteStore) savePageRow(q sqlQuerier, page Page) error {
+	page.UpdatedAt = nowMillis()
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`INSERT INTO pages (id, name, data) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, data = excluded.data`,
+		page.ID, page.Name, string(data))
+	return err
+}
+
+// touchPage stamps the page's UpdatedAt after its categories or bookmarks change
+func (s *SQLiteStore) touchPage(q sqlQuerier, pageID int) error {
+	var data string
+	if err := q.QueryRow(`SELECT data FROM pages WHERE id = ?`, pageID).Scan(&data); err != nil {
+		return err
+	}
+	var page Page
+	if err := json.Unmarshal([]byte(data), &page); err != nil {
+		return err
+	}
+	return s.savePageRow(q, page)
+}
+
+// ensurePage creates a page with the default categories if it doesn't exist yet,
+// mirroring the file store creating bookmarks-{pageID}.json on first write
+func (s *SQLiteStore) ensurePage(q sqlQuerier, pageID int) error {
+	if s.pageExists(q, pageID) {
+		return nil
+	}
+	if err := s.savePageRow(q, Page{ID: pageID, Name: fmt.Sprintf("Page %d", pageID)}); err != nil {
+		return err
+	}
+	return s.replaceCategories(q, pageID, getDefaultNewPageCategories())
+}
+
+func (s *SQLiteStore) getBookmarks(q sqlQuerier, pageID int) ([]Bookmark, error) {
+	rows, err := q.Query(`SELECT data FROM bookmarks WHERE page_id = ? ORDER BY position`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var bookmark Bookmark
+		if err := json.Unmarshal([]byte(data), &bookmark); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, rows.Err()
+}
+
+func (s *SQLiteStore) insertBookmark(q sqlQuerier, pageID, position int, bookmark Bookmark) error {
+	data, err := json.Marshal(bookmark)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`INSERT INTO bookmarks (page_id, position, name, url, shortcut, category, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		pageID, position, bookmark.Name, bookmark.URL, bookmark.Shortcut, bookmark.Category, string(data))
+	return err
+}
+
+func (s *SQLiteStore) replaceBookmarks(q sqlQuerier, pageID int, bookmarks []Bookmark) error {
+	if _, err := q.Exec(`DELETE FROM bookmarks WHERE page_id = ?`, pageID); err != nil {
+		return err
+	}
+	for i, bookmark := range bookmarks {
+		if err := s.insertBookmark(q, pageID, i, bookmark); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) getCategories(q sqlQuerier, pageID int) ([]Category, error) {
+	rows, err := q.Query(`SELECT data FROM categories WHERE page_id = ? ORDER BY position`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var category Category
+		if err := json.Unmarshal([]byte(data), &category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (s *SQLiteStore) replaceCategories(q sqlQuerier, pageID int, categories []Category) error {
+	if _, err := q.Exec(`DELETE FROM categories WHERE page_id = ?`, pageID); err != nil {
+		return err
+	}
+	for i, category := range categories {
+		data, err := json.Marshal(category)
+		if err != nil {
+			return err
+		}
+		if _, err := q.Exec(`INSERT INTO categories (page_id, position, id, name, data) VALUES (?, ?, ?, ?, ?)`,
+			pageID, i, category.ID, category.Name, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetBookmarksByPage(pageID int) []Bookmark {
+	bookmarks, err := s.getBookmarks(s.db, pageID)
+	if err != nil {
+		log.Printf("SQLite: error reading bookmarks for page %d: %v", pageID, err)
+		return []Bookmark{}
+	}
+	return bookmarks
+}
+
+func (s *SQLiteStore) GetAllBookmarks() []Bookmark {
+	var allBookmarks []Bookmark
+
+	// Collect bookmarks from all pages in page order
+	for _, page := range s.GetPages() {
+		allBookmarks = append(allBookmarks, s.GetBookmarksByPage(page.ID)...)
+	}
+
+	return allBookmarks
+}
+
+func (s *SQLiteStore) GetBookmarksRange(offset, limit int) ([]Bookmark, int) {
+	items := []Bookmark{}
+	total := 0
+
+	// Read the page order first, the single connection is held by the transaction below
+	pages := s.GetPages()
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		for _, page := range pages {
+			var count int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM bookmarks WHERE page_id = ?`, page.ID).Scan(&count); err != nil {
+				return err
+			}
+
+			// Fetch only the part of this page that overlaps the window
+			pageOffset := offset - total
+			if pageOffset < 0 {
+				pageOffset = 0
+			}
+			if remaining := limit - len(items); remaining > 0 && pageOffset < count {
+				rows, err := tx.Query(`SELECT data FROM bookmarks WHERE page_id = ? ORDER BY position LIMIT ? OFFSET ?`,
+					page.ID, remaining, pageOffset)
+				if err != nil {
+					return err
+				}
+				for rows.Next() {
+					var data string
+					var bookmark Bookmark
+					if err := rows.Scan(&data); err != nil {
+						rows.Close()
+						return err
+					}
+					if err := json.Unmarshal([]byte(data), &bookmark); err != nil {
+						rows.Close()
+						return err
+					}
+					items = append(items, bookmark)
+				}
+				rows.Close()
+				if err := rows.Err(); err != nil {
+					return err
+				}
+			}
+
+			total += count
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("SQLite: error reading bookmarks range: %v", err)
+		return []Bookmark{}, 0
+	}
+
+	return items, total
+}
+
+func (s *SQLiteStore) SaveBookmarksByPage(pageID int, bookmarks []Bookmark) {
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := s.ensurePage(tx, pageID); err != nil {
+			return err
+		}
+		if err := s.replaceBookmarks(tx, pageID, bookmarks); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+	if err != nil {
+		log.Printf("SQLite: error saving bookmarks for page %d: %v", pageID, err)
+	}
+}
+
+// SaveBookmarksIfVersion replaces the page's bookmarks if matches accepts the
+// stored page, in one transaction
+func (s *SQLiteStore) SaveBookmarksIfVersion(pageID int, bookmarks []Bookmark, matches func(Page) bool) ([]Bookmark, error) {
+	var previous []Bookmark
+	err := s.withTx(func(tx *sql.Tx) error {
+		var data string
+		err := tx.QueryRow(`SELECT data FROM pages WHERE id = ?`, pageID).Scan(&data)
+		if err == sql.ErrNoRows {
+			return errVersionMismatch
+		}
+		if err != nil {
+			return err
+		}
+		var page Page
+		if err := json.Unmarshal([]byte(data), &page); err != nil {
+			return err
+		}
+		if !matches(page) {
+			return errVersionMismatch
+		}
+
+		if previous, err = s.getBookmarks(tx, pageID); err != nil {
+			return err
+		}
+		if err := s.replaceBookmarks(tx, pageID, bookmarks); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+	return previous, err
+}
+
+func (s *SQLiteStore) AddBookmarkToPage(pageID int, bookmark Bookmark) {
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := s.ensurePage(tx, pageID); err != nil {
+			return err
+		}
+		var position int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM bookmarks WHERE page_id = ?`, pageID).Scan(&position); err != nil {
+			return err
+		}
+		if err := s.insertBookmark(tx, pageID, position, bookmark); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+	if err != nil {
+		log.Printf("SQLite: error adding bookmark to page %d: %v", pageID, err)
+	}
+}
+
+func (s *SQLiteStore) DeleteBookmarkFromPage(pageID int, bookmarkToDelete Bookmark) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if !s.pageExists(tx, pageID) {
+			return fmt.Errorf("page not found")
+		}
+
+		// Remove only the first match, like the file store
+		var rowID int64
+		err := tx.QueryRow(`SELECT id FROM bookmarks WHERE page_id = ? AND name = ? AND url = ? ORDER BY position LIMIT 1`,
+			pageID, bookmarkToDelete.Name, bookmarkToDelete.URL).Scan(&rowID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("bookmark not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM bookmarks WHERE id = ?`, rowID); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+}
+
+func (s *SQLiteStore) GetCategoriesByPage(pageID int) []Category {
+	categories, err := s.getCategories(s.db, pageID)
+	if err != nil {
+		log.Printf("SQLite: error reading categories for page %d: %v", pageID, err)
+		return []Category{}
+	}
+	return categories
+}
+
+// SetCategoryCollapsed changes only the collapsed flag of one category
+func (s *SQLiteStore) SetCategoryCollapsed(pageID int, categoryID string, collapsed bool) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var data string
+		err := tx.QueryRow(`SELECT data FROM categories WHERE page_id = ? AND id = ?`, pageID, categoryID).Scan(&data)
+		if err == sql.ErrNoRows {
+			return errCategoryNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var category Category
+		if err := json.Unmarshal([]byte(data), &category); err != nil {
+			return err
+		}
+		category.Collapsed = collapsed
+		updated, err := json.Marshal(category)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE categories SET data = ? WHERE page_id = ? AND id = ?`, string(updated), pageID, categoryID); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+}
+
+// ReorderCategories puts the page's categories in the order of ids
+func (s *SQLiteStore) ReorderCategories(pageID int, ids []string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		categories, err := s.getCategories(tx, pageID)
+		if err != nil {
+			return err
+		}
+		ordered, err := orderCategories(categories, ids)
+		if err != nil {
+			return err
+		}
+		if err := s.replaceCategories(tx, pageID, ordered); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+}
+
+// AssignCategory moves the page's bookmarks whose URL is in urls into categoryID
+func (s *SQLiteStore) AssignCategory(pageID int, categoryID string, urls []string) ([]Bookmark, error) {
+	var changed []Bookmark
+	err := s.withTx(func(tx *sql.Tx) error {
+		if !s.pageExists(tx, pageID) {
+			return errPageNotFound
+		}
+		categories, err := s.getCategories(tx, pageID)
+		if err != nil {
+			return err
+		}
+		bookmarks, err := s.getBookmarks(tx, pageID)
+		if err != nil {
+			return err
+		}
+		if changed, err = assignCategory(categories, bookmarks, categoryID, urls); err != nil || len(changed) == 0 {
+			return err
+		}
+		if err := s.replaceBookmarks(tx, pageID, bookmarks); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+	return changed, err
+}
+
+// ClearPage removes the page's bookmarks, and optionally its categories, in one
+// transaction
+func (s *SQLiteStore) ClearPage(pageID int, categories bool) (PageWithBookmarks, error) {
+	var previous PageWithBookmarks
+	err := s.withTx(func(tx *sql.Tx) error {
+		var err error
+		if previous, err = s.getPageWithBookmarks(tx, pageID); err != nil {
+			return err
+		}
+		if err := s.replaceBookmarks(tx, pageID, []Bookmark{}); err != nil {
+			return err
+		}
+		if categories {
+			if err := s.replaceCategories(tx, pageID, []Category{}); err != nil {
+				return err
+			}
+		}
+		return s.touchPage(tx, pageID)
+	})
+	return previous, err
+}
+
+// RepairCategories moves the page's bookmarks whose category is missing into
+// fallback in one transaction
+func (s *SQLiteStore) RepairCategories(pageID int, fallback string) ([]Bookmark, error) {
+	var moved []Bookmark
+	err := s.withTx(func(tx *sql.Tx) error {
+		if !s.pageExists(tx, pageID) {
+			return errPageNotFound
+		}
+		categories, err := s.getCategories(tx, pageID)
+		if err != nil {
+			return err
+		}
+		bookmarks, err := s.getBookmarks(tx, pageID)
+		if err != nil {
+			return err
+		}
+		if categories, moved = repairCategories(categories, bookmarks, fallback); len(moved) == 0 {
+			return nil
+		}
+		if err := s.replaceCategories(tx, pageID, categories); err != nil {
+			return err
+		}
+		if err := s.replaceBookmarks(tx, pageID, bookmarks); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+	return moved, err
+}
+
+// IncrementVisit adds one to the visit count of the first bookmark on the page
+// with name and url, leaving the page's UpdatedAt alone
+func (s *SQLiteStore) IncrementVisit(pageID int, name, url string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var id int64
+		var data string
+		err := tx.QueryRow(`SELECT id, data FROM bookmarks WHERE page_id = ? AND name = ? AND url = ? ORDER BY position LIMIT 1`,
+			pageID, name, url).Scan(&id, &data)
+		if err == sql.ErrNoRows {
+			return errBookmarkNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var bookmark Bookmark
+		if err := json.Unmarshal([]byte(data), &bookmark); err != nil {
+			return err
+		}
+		bookmark.VisitCount++
+		updated, err := json.Marshal(bookmark)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE bookmarks SET data = ? WHERE id = ?`, string(updated), id)
+		return err
+	})
+}
+
+// DeleteCategory removes a category, moving its bookmarks to reassignTo
+func (s *SQLiteStore) DeleteCategory(pageID int, categoryID, reassignTo string) ([]Bookmark, error) {
+	var moved []Bookmark
+	err := s.withTx(func(tx *sql.Tx) error {
+		categories, err := s.getCategories(tx, pageID)
+		if err != nil {
+			return err
+		}
+		bookmarks, err := s.getBookmarks(tx, pageID)
+		if err != nil {
+			return err
+		}
+		remaining, removed, err := removeCategory(categories, bookmarks, categoryID, reassignTo)
+		moved = removed
+		if err != nil {
+			return err
+		}
+		if err := s.replaceCategories(tx, pageID, remaining); err != nil {
+			return err
+		}
+		if len(moved) > 0 {
+			if err := s.replaceBookmarks(tx, pageID, bookmarks); err != nil {
+				return err
+			}
+		}
+		return s.touchPage(tx, pageID)
+	})
+	return moved, err
+}
+
+// SaveCategoriesByPage replaces the page's categories, creating the page if needed,
+// and remaps bookmarks to the new category IDs like the file store does
+func (s *SQLiteStore) SaveCategoriesByPage(pageID int, categories []Category) {
+	assignCategoryIDs(categories)
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		if !s.pageExists(tx, pageID) {
+			if err := s.savePageRow(tx, Page{ID: pageID, Name: fmt.Sprintf("Page %d", pageID)}); err != nil {
+				return err
+			}
+			return s.replaceCategories(tx, pageID, categories)
+		}
+
+		oldCategories, err := s.getCategories(tx, pageID)
+		if err != nil {
+			return err
+		}
+		bookmarks, err := s.getBookmarks(tx, pageID)
+		if err != nil {
+			return err
+		}
+
+		remapBookmarkCategories(oldCategories, categories, bookmarks)
+
+		if err := s.replaceBookmarks(tx, pageID, bookmarks); err != nil {
+			return err
+		}
+		if err := s.replaceCategories(tx, pageID, categories); err != nil {
+			return err
+		}
+		return s.touchPage(tx, pageID)
+	})
+	if err != nil {
+		log.Printf("SQLite: error saving categories for page %d: %v", pageID, err)
+	}
+}
+
+func (s *SQLiteStore) GetFinders() []Finder {
+	rows, err := s.db.Query(`SELECT name, search_url, shortcut FROM finders ORDER BY position`)
+	if err != nil {
+		log.Printf("SQLite: error reading finders: %v", err)
+		return []Finder{}
+	}
+	defer rows.Close()
+
+	finders := []Finder{}
+	for rows.Next() {
+		var finder Finder
+		if err := rows.Scan(&finder.Name, &finder.SearchUrl, &finder.Shortcut); err != nil {
+			log.Printf("SQLite: error reading finders: %v", err)
+			return []Finder{}
+		}
+		finders = append(finders, finder)
+	}
+	return finders
+}
+
+func (s *SQLiteStore) SaveFinders(finders []Finder) {
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM finders`); err != nil {
+			return err
+		}
+		for i, finder := range finders {
+			if _, err := tx.Exec(`INSERT INTO finders (position, name, search_url, shortcut) VALUES (?, ?, ?, ?)`,
+				i, finder.Name, finder.SearchUrl, finder.Shortcut); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("SQLite: error saving finders: %v", err)
+	}
+}
+
+func (s *SQLiteStore) GetPages() []Page {
+	rows, err := s.db.Query(`SELECT data FROM pages`)
+	if err != nil {
+		log.Printf("SQLite: error reading pages: %v", err)
+		return []Page{{ID: 1, Name: "main"}}
+	}
+
+	pageMap := make(map[int]Page)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			continue
+		}
+		var page Page
+		if err := json.Unmarshal([]byte(data), &page); err != nil {
+			continue
+		}
+		pageMap[page.ID] = page
+	}
+	rows.Close()
+
+	if len(pageMap) == 0 {
+		return []Page{{ID: 1, Name: "main"}}
+	}
+
+	// Add pages missing from the stored order and persist the result
+	order, changed := reconcilePageOrder(pageMap, s.GetPageOrder())
+	if changed {
+		s.SavePageOrder(order)
+	}
+
+	return orderPages(pageMap, order)
+}
+
+func (s *SQLiteStore) PageExists(pageID int) bool {
+	return s.pageExists(s.db, pageID)
+}
+
+func (s *SQLiteStore) GetPagesWithCounts() []PageSummary {
+	pages := s.GetPages()
+
+	countRows := func(table string) map[int]int {
+		counts := make(map[int]int)
+		rows, err := s.db.Query(`SELECT page_id, COUNT(*) FROM ` + table + ` GROUP BY page_id`)
+		if err != nil {
+			log.Printf("SQLite: error counting %s: %v", table, err)
+			return counts
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var pageID, count int
+			if err := rows.Scan(&pageID, &count); err == nil {
+				counts[pageID] = count
+			}
+		}
+		return counts
+	}
+	bookmarkCounts := countRows("bookmarks")
+	categoryCounts := countRows("categories")
+
+	summaries := make([]PageSummary, len(pages))
+	for i, page := range pages {
+		summaries[i] = PageSummary{
+			Page:          page,
+			BookmarkCount: bookmarkCounts[page.ID],
+			CategoryCount: categoryCounts[page.ID],
+		}
+	}
+	return summaries
+}
+
+// GetPageWithBookmarks reads the page, its categories and bookmarks in one transaction
+func (s *SQLiteStore) GetPageWithBookmarks(pageID int) (PageWithBookmarks, error) {
+	var pageWithBookmarks PageWithBookmarks
+	err := s.withTx(func(tx *sql.Tx) error {
+		var err error
+		pageWithBookmarks, err = s.getPageWithBookmarks(tx, pageID)
+		return err
+	})
+	if err != nil {
+		return PageWithBookmarks{}, err
+	}
+	return pageWithBookmarks, nil
+}
+
+// getPageWithBookmarks reads a page with its categories and bookmarks, returning
+// errPageNotFound when there's no such page
+func (s *SQLiteStore) getPageWithBookmarks(q sqlQuerier, pageID int) (PageWithBookmarks, error) {
+	var pageWithBookmarks PageWithBookmarks
+	var data string
+	err := q.QueryRow(`SELECT data FROM pages WHERE id = ?`, pageID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return PageWithBookmarks{}, errPageNotFound
+	}
+	if err != nil {
+		return PageWithBookmarks{}, err
+	}
+	if err := json.Unmarshal([]byte(data), &pageWithBookmarks.Page); err != nil {
+		return PageWithBookmarks{}, err
+	}
+
+	if pageWithBookmarks.Categories, err = s.getCategories(q, pageID); err != nil {
+		return PageWithBookmarks{}, err
+	}
+	if pageWithBookmarks.Bookmarks, err = s.getBookmarks(q, pageID); err != nil {
+		return PageWithBookmarks{}, err
+	}
+	return pageWithBookmarks, nil
+}
+
+// MergePages merges the source page into the target with mergePageInto, deletes
+// the source and removes it from the page order, in one transaction
+func (s *SQLiteStore) MergePages(sourceID, targetID int) (PageWithBookmarks, error) {
+	var target PageWithBookmarks
+	err := s.withTx(func(tx *sql.Tx) error {
+		source, err := s.getPageWithBookmarks(tx, sourceID)
+		if err != nil {
+			return err
+		}
+		if target, err = s.getPageWithBookmarks(tx, targetID); err != nil {
+			return err
+		}
+
+		merged := mergePageInto(target, source)
+		if err := s.replaceCategories(tx, targetID, merged.Categories); err != nil {
+			return err
+		}
+		if err := s.replaceBookmarks(tx, targetID, merged.Bookmarks); err != nil {
+			return err
+		}
+		if err := s.touchPage(tx, targetID); err != nil {
+			return err
+		}
+		if err := s.deletePage(tx, sourceID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM page_order WHERE page_id = ?`, sourceID)
+		return err
+	})
+	if err != nil {
+		return PageWithBookmarks{}, err
+	}
+	return target, nil
+}
+
+func (s *SQLiteStore) GetPageOrder() []int {
+	rows, err := s.db.Query(`SELECT page_id FROM page_order ORDER BY position`)
+	if err != nil {
+		log.Printf("SQLite: error reading page order: %v", err)
+		return []int{}
+	}
+	defer rows.Close()
+
+	order := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return []int{}
+		}
+		order = append(order, id)
+	}
+	return order
+}
+
+func (s *SQLiteStore) SavePageOrder(order []int) {
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM page_order`); err != nil {
+			return err
+		}
+		for i, id := range order {
+			if _, err := tx.Exec(`INSERT INTO page_order (position, page_id) VALUES (?, ?)`, i, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("SQLite: error saving page order: %v", err)
+	}
+}
+
+// SavePages writes the metadata of every page and the page order in one transaction
+func (s *SQLiteStore) SavePages(pages []Page) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM page_order`); err != nil {
+			return err
+		}
+		for i, page := range pages {
+			if err := s.ensurePage(tx, page.ID); err != nil {
+				return err
+			}
+			if err := s.savePageRow(tx, page); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO page_order (position, page_id) VALUES (?, ?)`, i, page.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SavePage writes the page metadata and bookmarks, preserving existing categories
+func (s *SQLiteStore) SavePage(page Page, bookmarks []Bookmark) {
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := s.ensurePage(tx, page.ID); err != nil {
+			return err
+		}
+		if err := s.savePageRow(tx, page); err != nil {
+			return err
+		}
+		return s.replaceBookmarks(tx, page.ID, bookmarks)
+	})
+	if err != nil {
+		log.Printf("SQLite: error saving page %d: %v", page.ID, err)
+	}
+}
+
+func (s *SQLiteStore) RenamePage(pageID int, name string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var data string
+		if err := tx.QueryRow(`SELECT data FROM pages WHERE id = ?`, pageID).Scan(&data); err != nil {
+			return err
+		}
+		var page Page
+		if err := json.Unmarshal([]byte(data), &page); err != nil {
+			return err
+		}
+		page.Name = name
+		return s.savePageRow(tx, page)
+	})
+}
+
+// SetPageFavicon changes only the favicon of a page
+func (s *SQLiteStore) SetPageFavicon(pageID int, favicon string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var data string
+		if err := tx.QueryRow(`SELECT data FROM pages WHERE id = ?`, pageID).Scan(&data); err != nil {
+			return err
+		}
+		var page Page
+		if err := json.Unmarshal([]byte(data), &page); err != nil {
+			return err
+		}
+		page.Favicon = favicon
+		return s.savePageRow(tx, page)
+	})
+}
+
+func (s *SQLiteStore) DeletePage(pageID int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if !s.pageExists(tx, pageID) {
+			return fmt.Errorf("page not found")
+		}
+		return s.deletePage(tx, pageID)
+	})
+}
+
+// deletePage removes a page with its categories and bookmarks
+func (s *SQLiteStore) deletePage(q sqlQuerier, pageID int) error {
+	if _, err := q.Exec(`DELETE FROM bookmarks WHERE page_id = ?`, pageID); err != nil {
+		return err
+	}
+	if _, err := q.Exec(`DELETE FROM categories WHERE page_id = ?`, pageID); err != nil {
+		return err
+	}
+	_, err := q.Exec(`DELETE FROM pages WHERE id = ?`, pageID)
+	return err
+}
+
+func (s *SQLiteStore) GetSettings() Settings {
+	var data string
+	if err := s.db.QueryRow(`SELECT data FROM settings WHERE id = 1`).Scan(&data); err != nil {
+		return getDefaultSettings()
+	}
+
+	var settings Settings
+	json.Unmarshal([]byte(data), &settings)
+
+	// Set default language if empty
+	if settings.Language == "" {
+		settings.Language = "en"
+	}
+
+	return settings
+}
+
+func (s *SQLiteStore) SaveSettings(settings Settings) {
+	settings.UpdatedAt = nowMillis()
+	data, _ := json.Marshal(settings)
+	if _, err := s.db.Exec(`INSERT INTO settings (id, data) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data`, string(data)); err != nil {
+		log.Printf("SQLite: error saving settings: %v", err)
+	}
+}
+
+func (s *SQLiteStore) GetColors() ColorTheme {
+	var data string
+	if err := s.db.QueryRow(`SELECT data FROM colors WHERE id = 1`).Scan(&data); err != nil {
+		return getDefaultColors()
+	}
+
+	var colors ColorTheme
+	if err := json.Unmarshal([]byte(data), &colors); err != nil {
+		return getDefaultColors()
+	}
+
+	// Ensure custom themes map is initialized
+	if colors.Custom == nil {
+		colors.Custom = make(map[string]ThemeColors)
+	}
+
+	return colors
+}
+
+func (s *SQLiteStore) SaveColors(colors ColorTheme) {
+	colors.UpdatedAt = nowMillis()
+	data, _ := json.Marshal(colors)
+	if _, err := s.db.Exec(`INSERT INTO colors (id, data) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data`, string(data)); err != nil {
+		log.Printf("SQLite: error saving colors: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStoreImportsDataDirOutsideDBDir(t *testing.T) {
+	dataDir := t.TempDir()
+	files := newFileStore(dataDir)
+	files.SavePage(Page{ID: 1, Name: "main"}, []Bookmark{{Name: "Example", URL: "https://example.com"}})
+	files.SavePageOrder([]int{1})
+
+	// The database lives somewhere other than the data directory
+	dbPath := filepath.Join(t.TempDir(), "db", "thinkdashboard.db")
+	store, err := NewSQLiteStore(dbPath, dataDir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	bookmarks := store.GetBookmarksByPage(1)
+	if len(bookmarks) != 1 || bookmarks[0].URL != "https://example.com" {
+		t.Errorf("bookmarks = %+v, want the one from %s", bookmarks, dataDir)
+	}
+}
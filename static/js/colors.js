@@ -27,9 +27,14 @@ async function loadSettings() {
             const deviceSettings = localStorage.getItem('dashboardSettings');
             settings = deviceSettings ? JSON.parse(deviceSettings) : {};
         } else {
-            const response = await fetch('/api/settings');
+            const response = await fetch('api/settings');
             settings = await response.json();
         }
+        if (deviceSpecific) {
+            // Read-only mode is decided by the server
+            const response = await fetch('api/settings');
+            settings.readOnly = (await response.json()).readOnly;
+        }
     } catch (error) {
         console.error('Error loading settings:', error);
         settings = {};
@@ -119,7 +124,7 @@ function initTabs() {
 // Load colors from API
 async function loadColors() {
     try {
-        const response = await fetch('/api/colors');
+        const response = await fetch('api/colors');
         if (!response.ok) throw new Error('Failed to load colors');
         
         colorsData = await response.json();
@@ -240,7 +245,7 @@ function applyColorsToPreview() {
 // Save colors to API
 async function saveColors() {
     try {
-        const response = await fetch('/api/colors', {
+        const response = await fetch('api/colors', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json'
@@ -271,10 +276,10 @@ async function saveColors() {
 
 // Reload the theme CSS to apply changes
 function reloadThemeCSS() {
-    const link = document.querySelector('link[href="/api/theme.css"]');
+    const link = document.querySelector('link[href="api/theme.css"]');
     if (link) {
         const newLink = link.cloneNode();
-        newLink.href = '/api/theme.css?' + new Date().getTime();
+        newLink.href = 'api/theme.css?' + new Date().getTime();
         link.parentNode.replaceChild(newLink, link);
     }
 }
@@ -291,7 +296,7 @@ async function resetColors() {
     if (!confirmed) return;
     
     try {
-        const response = await fetch('/api/colors/reset', {
+        const response = await fetch('api/colors/reset', {
             method: 'POST'
         });
         
@@ -433,6 +438,9 @@ document.addEventListener('DOMContentLoaded', async () => {
     
     // Save button
     document.getElementById('save-colors-btn').addEventListener('click', saveColors);
+    if (settings.readOnly) {
+        document.getElementById('save-colors-btn').style.display = 'none';
+    }
     
     // Reset button
     document.getElementById('reset-colors-btn').addEventListener('click', resetColors);
@@ -222,6 +222,8 @@ class ConfigManager {
 
         const saveBtn = document.getElementById('save-btn');
         if (saveBtn) saveBtn.addEventListener('click', () => this.saveChanges());
+        // Nothing can be saved when the server is read-only
+        if (saveBtn && this.settingsData.readOnly) saveBtn.style.display = 'none';
 
         const resetBtn = document.getElementById('reset-btn');
         if (resetBtn) resetBtn.addEventListener('click', () => this.resetToDefaults());
@@ -564,7 +566,8 @@ class ConfigManager {
             this.ui.showNotification(this.language.t('config.bookmarkMoved'), 'success');
         } catch (error) {
             console.error('Error moving bookmark:', error);
-            this.ui.showNotification(this.language.t('config.errorMovingBookmark'), 'error');
+            const message = error.conflict ? 'config.bookmarksChangedElsewhere' : 'config.errorMovingBookmark';
+            this.ui.showNotification(this.language.t(message), 'error');
         }
     }
 
@@ -599,6 +602,11 @@ class ConfigManager {
             }
             
             await this.data.savePages(this.pagesData);
+            // Saving categories and pages changes the version of the pages
+            await this.data.refreshPageVersion(this.currentPageId);
+            if (this.currentCategoriesPageId && this.currentCategoriesPageId !== this.currentPageId) {
+                await this.data.refreshPageVersion(this.currentCategoriesPageId);
+            }
             
             if (this.deviceSpecific) {
                 // Don't save global settings in localStorage
@@ -616,7 +624,8 @@ class ConfigManager {
             this.ui.showNotification(this.language.t('config.configSaved'), 'success');
         } catch (error) {
             console.error('Error saving configuration:', error);
-            this.ui.showNotification(this.language.t('config.errorSavingConfig'), 'error');
+            const message = error.conflict ? 'config.bookmarksChangedElsewhere' : 'config.errorSavingConfig';
+            this.ui.showNotification(this.language.t(message), 'error');
         }
     }
 
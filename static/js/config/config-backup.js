@@ -78,7 +78,7 @@ class ConfigBackup {
             backupBtn.disabled = true;
 
             // Fetch the backup
-            const response = await fetch('/api/backup', {
+            const response = await fetch('api/backup', {
                 method: 'GET',
             });
 
@@ -187,8 +187,9 @@ class ConfigBackup {
     /**
      * Perform the import operation
      * @param {JSZip} zip
+     * @param {boolean} verify - Check the files against the backup's checksums
      */
-    async performImport(zip) {
+    async performImport(zip, verify = true) {
         try {
             const formData = new FormData();
 
@@ -202,13 +203,32 @@ class ConfigBackup {
             }
 
             // Send to backend
-            const response = await fetch('/api/import', {
+            const response = await fetch(verify ? 'api/import' : 'api/import?verify=false', {
                 method: 'POST',
                 body: formData
             });
 
             if (!response.ok) {
-                throw new Error(`Import failed: ${response.statusText}`);
+                const body = await response.json().catch(() => null);
+                const error = body && body.error;
+
+                // The backup was changed or damaged after it was created, let the user decide
+                if (error && error.code === 'CHECKSUM_MISMATCH' && window.AppModal) {
+                    console.warn('Import verification failed:', error.message);
+                    const confirmed = await window.AppModal.confirm({
+                        title: this.t('config.importChecksumTitle'),
+                        message: this.t('config.importChecksumMessage'),
+                        confirmText: this.t('config.importAnyway'),
+                        cancelText: this.t('config.cancelImport'),
+                        confirmClass: 'danger'
+                    });
+                    if (confirmed) {
+                        await this.performImport(zip, false);
+                    }
+                    return;
+                }
+
+                throw new Error(`Import failed: ${error ? error.message : response.statusText}`);
             }
 
             // Show success message
@@ -67,6 +67,14 @@ class ConfigBookmarks {
                     <input type="checkbox" id="bookmark-checkStatus-${index}" name="bookmark-checkStatus-${index}" ${bookmark.checkStatus ? 'checked' : ''} data-bookmark-key="${index}" data-field="checkStatus">
                     <span class="checkbox-text">${this.t('config.status')}</span>
                 </label>
+                <label class="checkbox-label">
+                    <input type="checkbox" id="bookmark-hidden-${index}" name="bookmark-hidden-${index}" ${bookmark.hidden ? 'checked' : ''} data-bookmark-key="${index}" data-field="hidden">
+                    <span class="checkbox-text">${this.t('config.hidden')}</span>
+                </label>
+                <label class="checkbox-label">
+                    <input type="checkbox" id="bookmark-pinned-${index}" name="bookmark-pinned-${index}" ${bookmark.pinned ? 'checked' : ''} data-bookmark-key="${index}" data-field="pinned">
+                    <span class="checkbox-text">${this.t('config.pinned')}</span>
+                </label>
             </div>
             <button type="button" class="btn btn-danger" onclick="configManager.removeBookmark(${index})">${this.t('config.remove')}</button>
         `;
@@ -82,7 +90,7 @@ class ConfigBookmarks {
                 const field = e.target.getAttribute('data-field');
                 
                 // Update the bookmark object directly via stored reference
-                if (field === 'checkStatus') {
+                if (field === 'checkStatus' || field === 'hidden' || field === 'pinned') {
                     bookmark[field] = e.target.checked;
                 } else {
                     bookmark[field] = e.target.value;
@@ -107,7 +115,7 @@ class ConfigBookmarks {
                     formData.append('icon', file);
 
                     try {
-                        const response = await fetch('/api/icon', {
+                        const response = await fetch('api/icon', {
                             method: 'POST',
                             body: formData
                         });
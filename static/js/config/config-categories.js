@@ -12,7 +12,7 @@ class ConfigCategories {
     /**
      * Render categories list
      * @param {Array} categories
-     * @param {Function} generateId - Function to generate ID from name
+     * @param {Function} generateId - Not used anymore, kept for compatibility
      */
     render(categories, generateId) {
         const container = document.getElementById('categories-list');
@@ -60,14 +60,11 @@ class ConfigCategories {
         `;
 
         // Add event listener for name changes
+        // The ID is stable, renaming only changes the name so bookmarks keep their category
         const nameInput = div.querySelector('input[data-field="name"]');
         nameInput.addEventListener('input', (e) => {
             // Update the category object directly via stored reference
             category.name = e.target.value;
-            category.id = generateId(e.target.value);
-            // Update the data attribute with new ID
-            e.target.setAttribute('data-category-id', category.id);
-            div.setAttribute('data-category-id', category.id);
         });
 
         return div;
@@ -119,13 +116,26 @@ class ConfigCategories {
             return null;
         }
         const newCategory = {
-            id: generateId(`category-${categories.length + 1}`),
+            id: this.createId(categories),
             name: `${this.t('config.newCategoryPrefix')} ${categories.length + 1}`
         };
         categories.push(newCategory);
         return newCategory;
     }
 
+    /**
+     * Create a random category ID that isn't used by any of the categories
+     * @param {Array} categories
+     * @returns {string}
+     */
+    createId(categories) {
+        let id;
+        do {
+            id = 'cat-' + Math.floor(Math.random() * 0x100000000).toString(16).padStart(8, '0');
+        } while (categories.some(category => category.id === id));
+        return id;
+    }
+
     /**
      * Remove a category (with confirmation)
      * @param {Array} categories
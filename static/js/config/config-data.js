@@ -6,6 +6,8 @@
 class ConfigData {
     constructor(storage) {
         this.storage = storage;
+        // ETag of each page's bookmarks as last loaded or saved, sent as If-Match
+        this.pageVersions = {};
     }
 
     /**
@@ -16,9 +18,9 @@ class ConfigData {
     async loadData(deviceSpecific) {
         try {
             const [bookmarksRes, pagesRes, settingsRes] = await Promise.all([
-                fetch('/api/bookmarks'),
-                fetch('/api/pages'),
-                fetch('/api/settings')
+                fetch('api/bookmarks'),
+                fetch('api/pages'),
+                fetch('api/settings')
             ]);
 
             const bookmarks = await bookmarksRes.json();
@@ -38,6 +40,8 @@ class ConfigData {
                 // Always use font settings from server, regardless of device-specific
                 settings.enableCustomFont = serverSettings.enableCustomFont;
                 settings.customFontPath = serverSettings.customFontPath;
+                // Read-only mode is decided by the server
+                settings.readOnly = serverSettings.readOnly;
             } else {
                 settings = serverSettings;
             }
@@ -49,34 +53,81 @@ class ConfigData {
         }
     }
 
+    /**
+     * Get the message of an error response, which the API sends as
+     * {"error": {"code": "...", "message": "..."}}
+     * @param {Response} response
+     * @returns {Promise<string>}
+     */
+    async errorMessage(response) {
+        const text = await response.text();
+        try {
+            const body = JSON.parse(text);
+            if (body.error && body.error.message) {
+                return body.error.message;
+            }
+        } catch (e) {
+            // Not JSON, use the text as is
+        }
+        return text;
+    }
+
     /**
      * Save bookmarks to server
      * @param {Array} bookmarks
      * @param {string} pageId - Optional page ID for page-specific bookmarks
      */
     async saveBookmarks(bookmarks, pageId = null) {
-        const url = pageId ? `/api/bookmarks?page=${pageId}` : '/api/bookmarks';
+        const url = pageId ? `api/bookmarks?page=${pageId}` : 'api/bookmarks';
+        const headers = { 'Content-Type': 'application/json' };
+        if (pageId && this.pageVersions[pageId]) {
+            headers['If-Match'] = this.pageVersions[pageId];
+        }
         const response = await fetch(url, {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers,
             body: JSON.stringify(bookmarks)
         });
         
         if (!response.ok) {
-            const errorText = await response.text();
-            throw new Error(`Failed to save bookmarks: ${errorText}`);
+            const errorText = await this.errorMessage(response);
+            const error = new Error(`Failed to save bookmarks: ${errorText}`);
+            // The page was changed elsewhere since it was loaded
+            error.conflict = response.status === 409;
+            throw error;
         }
         
+        if (pageId) {
+            this.pageVersions[pageId] = response.headers.get('ETag');
+        }
         return await response.json();
     }
 
+    /**
+     * Take the page's current version after saving other parts of it, such as
+     * its categories, which change the version too
+     * @param {string} pageId
+     */
+    async refreshPageVersion(pageId) {
+        const res = await fetch(`api/bookmarks?page=${pageId}&includeHidden=true`);
+        if (res.ok) {
+            this.pageVersions[pageId] = res.headers.get('ETag');
+        }
+    }
+
     /**
      * Load bookmarks for a specific page
      * @param {string} pageId
      * @returns {Promise<Array>}
      */
     async loadBookmarksByPage(pageId) {
-        const res = await fetch(`/api/bookmarks?page=${pageId}`);
+        // Hidden bookmarks are managed here, so they must not be dropped on save
+        const res = await fetch(`api/bookmarks?page=${pageId}&includeHidden=true`);
+        // Pages that haven't been saved yet have no bookmarks
+        if (res.status === 404) {
+            return [];
+        }
+        this.pageVersions[pageId] = res.headers.get('ETag');
         return await res.json();
     }
 
@@ -86,7 +137,7 @@ class ConfigData {
      * @param {string|null} pageId
      */
     async saveCategoriesByPage(categories, pageId = null) {
-        const url = pageId ? `/api/categories?page=${pageId}` : '/api/categories';
+        const url = pageId ? `api/categories?page=${pageId}` : 'api/categories';
         const response = await fetch(url, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
@@ -94,7 +145,7 @@ class ConfigData {
         });
         
         if (!response.ok) {
-            const errorText = await response.text();
+            const errorText = await this.errorMessage(response);
             throw new Error(`Failed to save categories: ${errorText}`);
         }
         
@@ -106,7 +157,7 @@ class ConfigData {
      * @param {string|null} pageId
      */
     async loadCategoriesByPage(pageId = null) {
-        const url = pageId ? `/api/categories?page=${pageId}` : '/api/categories';
+        const url = pageId ? `api/categories?page=${pageId}` : 'api/categories';
         const res = await fetch(url);
         return await res.json();
     }
@@ -116,14 +167,14 @@ class ConfigData {
      * @param {Array} pages
      */
     async savePages(pages) {
-        const response = await fetch('/api/pages', {
+        const response = await fetch('api/pages', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify(pages)
         });
         
         if (!response.ok) {
-            const errorText = await response.text();
+            const errorText = await this.errorMessage(response);
             throw new Error(`Failed to save pages: ${errorText}`);
         }
         
@@ -135,7 +186,7 @@ class ConfigData {
      * @param {number} pageId
      */
     async deletePage(pageId) {
-        const response = await fetch(`/api/pages/${pageId}`, {
+        const response = await fetch(`api/pages/${pageId}`, {
             method: 'DELETE'
         });
         
@@ -149,14 +200,14 @@ class ConfigData {
      * @param {Object} settings
      */
     async saveSettings(settings) {
-        const response = await fetch('/api/settings', {
+        const response = await fetch('api/settings', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify(settings)
         });
         
         if (!response.ok) {
-            const errorText = await response.text();
+            const errorText = await this.errorMessage(response);
             throw new Error(`Failed to save settings: ${errorText}`);
         }
         
@@ -168,7 +219,7 @@ class ConfigData {
      * @returns {Promise<Object>}
      */
     async loadServerSettings() {
-        const settingsRes = await fetch('/api/settings');
+        const settingsRes = await fetch('api/settings');
         return await settingsRes.json();
     }
 
@@ -177,7 +228,7 @@ class ConfigData {
      * @returns {Promise<Array>}
      */
     async loadFinders() {
-        const res = await fetch('/api/finders');
+        const res = await fetch('api/finders');
         return await res.json();
     }
 
@@ -186,14 +237,14 @@ class ConfigData {
      * @param {Array} finders
      */
     async saveFinders(finders) {
-        const response = await fetch('/api/finders', {
+        const response = await fetch('api/finders', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify(finders)
         });
         
         if (!response.ok) {
-            const errorText = await response.text();
+            const errorText = await this.errorMessage(response);
             throw new Error(`Failed to save finders: ${errorText}`);
         }
         
@@ -27,7 +27,9 @@ class ConfigFont {
 
         // Create a font-face rule dynamically
         const fontName = 'CustomFont';
-        const fontFace = new FontFace(fontName, `url(${fontPath}?t=${Date.now()})`);
+        // Stored paths start with /data/, resolve them against the page's base path
+        const fontURL = fontPath.replace(/^\//, '');
+        const fontFace = new FontFace(fontName, `url(${fontURL}?t=${Date.now()})`);
         fontFace.load().then((loadedFace) => {
             document.fonts.add(loadedFace);
             // Update the CSS variable to use the custom font
@@ -45,7 +47,7 @@ class ConfigFont {
         const formData = new FormData();
         formData.append('font', file);
 
-        const response = await fetch('/api/font', {
+        const response = await fetch('api/font', {
             method: 'POST',
             body: formData
         });
@@ -25,7 +25,8 @@ class ConfigLanguage {
      */
     async loadTranslations(lang) {
         try {
-            const response = await fetch(`/locales/${lang}.json`);
+            // Missing keys fall back to English on the server
+            const response = await fetch(`api/locales/${encodeURIComponent(lang)}`);
             if (response.ok) {
                 this.translations = await response.json();
                 this.currentLanguage = lang;
@@ -97,6 +98,19 @@ class ConfigLanguage {
         // Set current value
         languageSelect.value = this.currentLanguage;
 
+        // Add languages installed on the server that aren't built in
+        fetch('api/locales')
+            .then(response => response.ok ? response.json() : [])
+            .then(languages => {
+                languages.filter(lang => !this.availableLanguages[lang]).forEach(lang => {
+                    const option = document.createElement('option');
+                    option.value = lang;
+                    option.textContent = lang;
+                    languageSelect.appendChild(option);
+                });
+                languageSelect.value = this.currentLanguage;
+            })
+            .catch(() => {});
     }
 
 
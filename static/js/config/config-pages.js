@@ -70,9 +70,18 @@ class ConfigPages {
             ? `<button type="button" class="btn btn-danger" disabled title="${this.t('config.cannotRemoveDefaultPage')}">${this.t('config.remove')}</button>`
             : `<button type="button" class="btn btn-danger" onclick="configManager.removePage(${index})">${this.t('config.remove')}</button>`;
         
+        const clearFaviconButton = page.favicon
+            ? `<button type="button" class="btn btn-danger btn-small btn-clear-icon" title="${this.t('config.clearPageFavicon')}">×</button>`
+            : '';
+
         div.innerHTML = `
             <span class="drag-handle js-drag-handle" title="Drag to reorder">⠿</span>
             <input type="text" id="page-name-${index}" name="page-name-${index}" value="${page.name}" placeholder="${this.t('config.pageNamePlaceholder')}" data-page-id="${page.id}" data-field="name">
+            <div class="bookmark-icon-upload page-favicon-upload">
+                <input type="file" id="page-favicon-${index}" name="page-favicon-${index}" accept=".ico,.png,.jpg,.gif" style="display: none;">
+                <button type="button" class="btn btn-secondary btn-small ${page.favicon ? 'has-icon' : ''}" onclick="document.getElementById('page-favicon-${index}').click()" title="${this.t('config.uploadPageFaviconTooltip')}">↑</button>
+                ${clearFaviconButton}
+            </div>
             ${removeButton}
         `;
 
@@ -83,9 +92,89 @@ class ConfigPages {
             page.name = e.target.value;
         });
 
+        const faviconInput = div.querySelector(`#page-favicon-${index}`);
+        faviconInput.addEventListener('change', (e) => {
+            const file = e.target.files[0];
+            if (file) {
+                this.uploadFavicon(page, file, div);
+            }
+        });
+        const clearButton = div.querySelector('.page-favicon-upload .btn-clear-icon');
+        if (clearButton) {
+            clearButton.addEventListener('click', () => this.clearFavicon(page, div));
+        }
+
         return div;
     }
 
+    /**
+     * Upload the favicon of a saved page
+     * @param {Object} page
+     * @param {File} file
+     * @param {HTMLElement} element - The page's list item
+     */
+    async uploadFavicon(page, file, element) {
+        const formData = new FormData();
+        formData.append('favicon', file);
+
+        try {
+            const response = await fetch(`api/pages/${page.id}/favicon`, {
+                method: 'POST',
+                body: formData
+            });
+            const result = await response.json();
+            if (!response.ok) {
+                throw new Error(result.error ? result.error.message : 'Upload failed');
+            }
+            page.favicon = result.path;
+            this.refreshFaviconButtons(page, element);
+        } catch (error) {
+            console.error('Error uploading page favicon:', error);
+            alert('Error uploading favicon');
+        }
+    }
+
+    /**
+     * Remove the favicon of a page, so it uses the global one
+     * @param {Object} page
+     * @param {HTMLElement} element - The page's list item
+     */
+    async clearFavicon(page, element) {
+        try {
+            const response = await fetch(`api/pages/${page.id}/favicon`, { method: 'DELETE' });
+            if (!response.ok) {
+                throw new Error('Failed to remove favicon');
+            }
+            delete page.favicon;
+            this.refreshFaviconButtons(page, element);
+        } catch (error) {
+            console.error('Error removing page favicon:', error);
+        }
+    }
+
+    /**
+     * Show whether a page has a favicon in its list item
+     * @param {Object} page
+     * @param {HTMLElement} element
+     */
+    refreshFaviconButtons(page, element) {
+        const container = element.querySelector('.page-favicon-upload');
+        container.querySelector('.btn-secondary').classList.toggle('has-icon', !!page.favicon);
+
+        let clearButton = container.querySelector('.btn-clear-icon');
+        if (page.favicon && !clearButton) {
+            clearButton = document.createElement('button');
+            clearButton.type = 'button';
+            clearButton.className = 'btn btn-danger btn-small btn-clear-icon';
+            clearButton.title = this.t('config.clearPageFavicon');
+            clearButton.textContent = '×';
+            clearButton.addEventListener('click', () => this.clearFavicon(page, element));
+            container.appendChild(clearButton);
+        } else if (!page.favicon && clearButton) {
+            clearButton.remove();
+        }
+    }
+
     /**
      * Initialize page reordering
      * @param {Array} pages
@@ -15,9 +15,11 @@ class ConfigSettings {
      */
     async loadCustomThemes() {
         try {
-            const response = await fetch('/api/colors/custom-themes');
+            const response = await fetch('api/colors/custom-themes');
             if (response.ok) {
-                this.customThemes = await response.json();
+                // The API lists { id, name } in display order; keep it as an ordered id -> name map
+                const themes = await response.json();
+                this.customThemes = Object.fromEntries(themes.map(theme => [theme.id, theme.name]));
                 // Expose a normalized list of custom theme ids for other modules
                 window.CustomThemeIds = Array.isArray(this.customThemes)
                     ? this.customThemes
@@ -302,7 +304,7 @@ class ConfigSettings {
                     formData.append('favicon', file);
 
                     try {
-                        const response = await fetch('/api/favicon', {
+                        const response = await fetch('api/favicon', {
                             method: 'POST',
                             body: formData
                         });
@@ -555,6 +557,24 @@ class ConfigSettings {
             });
         }
 
+        // Shortcut pattern input
+        const shortcutPatternInput = document.getElementById('shortcut-pattern-input');
+        if (shortcutPatternInput) {
+            shortcutPatternInput.value = settings.shortcutPattern || '';
+            shortcutPatternInput.addEventListener('input', (e) => {
+                settings.shortcutPattern = e.target.value;
+            });
+        }
+
+        // Allow custom schemes checkbox
+        const allowCustomSchemesCheckbox = document.getElementById('allow-custom-schemes-checkbox');
+        if (allowCustomSchemesCheckbox) {
+            allowCustomSchemesCheckbox.checked = settings.allowCustomSchemes || false;
+            allowCustomSchemesCheckbox.addEventListener('change', (e) => {
+                settings.allowCustomSchemes = e.target.checked;
+            });
+        }
+
         // Enable fuzzy suggestions checkbox
         const enableFuzzySuggestionsCheckbox = document.getElementById('enable-fuzzy-suggestions-checkbox');
         if (enableFuzzySuggestionsCheckbox) {
@@ -643,6 +663,10 @@ class ConfigSettings {
         if (showStatusLoadingCheckbox) settings.showStatusLoading = showStatusLoadingCheckbox.checked;
         if (skipFastPingCheckbox) settings.skipFastPing = skipFastPingCheckbox.checked;
         if (globalShortcutsCheckbox) settings.globalShortcuts = globalShortcutsCheckbox.checked;
+        const shortcutPatternInput = document.getElementById('shortcut-pattern-input');
+        if (shortcutPatternInput) settings.shortcutPattern = shortcutPatternInput.value.trim();
+        const allowCustomSchemesCheckbox = document.getElementById('allow-custom-schemes-checkbox');
+        if (allowCustomSchemesCheckbox) settings.allowCustomSchemes = allowCustomSchemesCheckbox.checked;
         if (enableCustomTitleCheckbox) settings.enableCustomTitle = enableCustomTitleCheckbox.checked;
         if (customTitleInput) settings.customTitle = customTitleInput.value;
         if (showPageInTitleCheckbox) settings.showPageInTitle = showPageInTitleCheckbox.checked;
@@ -868,6 +892,10 @@ class ConfigSettings {
             theme: 'dark',
             openInNewTab: true,
             columnsPerRow: 3,
+            columnsPerRowMobile: 0,
+            columnsPerRowWide: 0,
+            mobileBreakpoint: 767,
+            wideBreakpoint: 1920,
             fontSize: 'm',
             showBackgroundDots: true,
             showTitle: true,
@@ -915,7 +943,7 @@ class ConfigSettings {
      */
     async saveSettingsToServer(settings) {
         try {
-            await fetch('/api/settings', {
+            await fetch('api/settings', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify(settings)
@@ -4,6 +4,7 @@ class Dashboard {
         this.bookmarks = [];
         this.allBookmarks = []; // For global shortcuts
         this.finders = [];
+        this.pinned = []; // Pinned bookmarks of every page, for the favorites bar
         this.categories = [];
         this.collapsedCategories = {};
         this.pages = [];
@@ -13,6 +14,10 @@ class Dashboard {
             theme: 'dark',
             openInNewTab: true,
             columnsPerRow: 3,
+            columnsPerRowMobile: 0,
+            columnsPerRowWide: 0,
+            mobileBreakpoint: 767,
+            wideBreakpoint: 1920,
             fontSize: 'm',
             showBackgroundDots: true,
             showTitle: true,
@@ -37,6 +42,10 @@ class Dashboard {
         this.statusMonitorInitialized = false;
         this.keyboardNavigation = null;
         this.swipeNavigation = null;
+        this.events = null;
+        this.pendingChanges = new Set();
+        this.changeTimer = null;
+        this.settingsSavedAt = 0;
         this.language = new ConfigLanguage();
         this.init();
     }
@@ -54,6 +63,8 @@ class Dashboard {
         this.renderPageNavigation();
         this.renderDashboard();
         this.setupPageShortcuts();
+        await this.loadPinnedBookmarks();
+        this.subscribeToChanges();
         
         // Add hash change listener for navigation
         window.addEventListener('hashchange', () => {
@@ -72,17 +83,15 @@ class Dashboard {
 
     async loadData() {
         try {
-            const [pagesRes, settingsRes, findersRes] = await Promise.all([
-                fetch('/api/pages'),
-                fetch('/api/settings'),
-                fetch('/api/finders')
-            ]);
-
-            this.pages = await pagesRes.json();
-            this.finders = await findersRes.json();
+            // Everything for the first render in one request
+            const allRes = await fetch('api/all');
+            const startup = await allRes.json();
+
+            this.pages = startup.pages;
+            this.finders = startup.finders;
             
             // Load settings from server first
-            const serverSettings = await settingsRes.json();
+            const serverSettings = startup.settings;
             
             // Load settings from localStorage or server based on device-specific flag
             const deviceSpecific = localStorage.getItem('deviceSpecificSettings') === 'true';
@@ -110,8 +119,9 @@ class Dashboard {
             }
             this.currentPageId = initialPageId;
             
-            // Load bookmarks and categories for initial page
-            await this.loadPageBookmarks(this.currentPageId);
+            // Load bookmarks and categories for initial page, which came along
+            // unless the URL points at another one
+            await this.loadPageBookmarks(this.currentPageId, startup);
             
             // If global shortcuts is enabled, load all bookmarks for search
             if (this.settings.globalShortcuts) {
@@ -133,15 +143,32 @@ class Dashboard {
         localStorage.setItem('collapsedCategories', JSON.stringify(this.collapsedCategories));
     }
 
-    async loadPageBookmarks(pageId) {
+    /**
+     * Show a page's bookmarks and categories
+     * @param {number} pageId
+     * @param {Object} [preloaded] - Response of /api/all, used instead of fetching when it's for this page
+     */
+    async loadPageBookmarks(pageId, preloaded = null) {
         try {
-            const [bookmarksRes, categoriesRes] = await Promise.all([
-                fetch(`/api/bookmarks?page=${pageId}`),
-                fetch(`/api/categories?page=${pageId}`)
-            ]);
-            
-            this.bookmarks = await bookmarksRes.json();
-            this.categories = (await categoriesRes.json()).map(cat => ({ ...cat, name: this.language.t(cat.name) || cat.name }));
+            let bookmarks, categories;
+            if (preloaded && preloaded.page === pageId) {
+                ({ bookmarks, categories } = preloaded);
+            } else {
+                const [bookmarksRes, categoriesRes] = await Promise.all([
+                    fetch(`api/bookmarks?page=${pageId}`),
+                    fetch(`api/categories?page=${pageId}`)
+                ]);
+
+                if (!bookmarksRes.ok) {
+                    throw new Error(`Page ${pageId} not found`);
+                }
+
+                bookmarks = await bookmarksRes.json();
+                categories = await categoriesRes.json();
+            }
+
+            this.bookmarks = bookmarks;
+            this.categories = categories.map(cat => ({ ...cat, name: this.language.t(cat.name) || cat.name }));
             this.currentPageId = pageId;
             
             // Update URL hash
@@ -158,6 +185,7 @@ class Dashboard {
             
             // Update document title with page name if enabled
             this.updateDocumentTitle();
+            this.updateFavicon();
 
             // Update search component and render
             if (this.searchComponent) {
@@ -176,7 +204,7 @@ class Dashboard {
 
     async loadAllBookmarks() {
         try {
-            const allBookmarksRes = await fetch('/api/bookmarks?all=true');
+            const allBookmarksRes = await fetch('api/bookmarks?all=true');
             this.allBookmarks = await allBookmarksRes.json();
             
             // Update search component with all bookmarks
@@ -188,9 +216,100 @@ class Dashboard {
         }
     }
 
+    /**
+     * Follow /api/events so changes saved elsewhere, e.g. on the config page or
+     * another device, show up without a reload. EventSource reconnects by itself
+     * and replays what was missed through Last-Event-ID.
+     */
+    subscribeToChanges() {
+        if (typeof EventSource === 'undefined') return;
+
+        this.events = new EventSource('api/events');
+        this.events.onmessage = (message) => {
+            let event;
+            try {
+                event = JSON.parse(message.data);
+            } catch (e) {
+                return;
+            }
+            // A save usually publishes several events, handle them together
+            this.pendingChanges.add(event.type === 'bookmarks' || event.type === 'categories'
+                ? `${event.type}:${event.page || ''}`
+                : event.type);
+            clearTimeout(this.changeTimer);
+            this.changeTimer = setTimeout(() => this.applyChanges(), 300);
+        };
+    }
+
+    async applyChanges() {
+        const changes = this.pendingChanges;
+        this.pendingChanges = new Set();
+
+        // Settings and colors change the whole page, an import may change anything.
+        // Settings this dashboard saved itself are already applied.
+        const ownSettings = Date.now() - this.settingsSavedAt < 5000;
+        if (changes.has('import') || changes.has('colors') || (changes.has('settings') && !ownSettings)) {
+            window.location.reload();
+            return;
+        }
+
+        try {
+            if (changes.has('pages')) {
+                this.pages = await (await fetch('api/pages')).json();
+                this.renderPageNavigation();
+                if (!this.pages.some(p => p.id === this.currentPageId) && this.pages.length > 0) {
+                    await this.loadPageBookmarks(this.pages[0].id);
+                }
+            }
+            if (changes.has('finders')) {
+                this.finders = await (await fetch('api/finders')).json();
+                if (this.searchComponent) {
+                    this.updateSearchComponent();
+                }
+            }
+
+            const pageChanged = [...changes].some(change =>
+                change === `bookmarks:${this.currentPageId}` || change === `categories:${this.currentPageId}`);
+            if (pageChanged) {
+                await this.loadPageBookmarks(this.currentPageId);
+            }
+            if ([...changes].some(change => change.startsWith('bookmarks:')) || changes.has('pages')) {
+                await this.loadPinnedBookmarks();
+                if (this.settings.globalShortcuts) {
+                    await this.loadAllBookmarks();
+                }
+            }
+        } catch (error) {
+            console.error('Error applying changes:', error);
+        }
+    }
+
+    async loadPinnedBookmarks() {
+        try {
+            const res = await fetch('api/bookmarks/pinned');
+            this.pinned = res.ok ? await res.json() : [];
+        } catch (error) {
+            console.error('Error loading pinned bookmarks:', error);
+            this.pinned = [];
+        }
+        this.renderFavoritesBar();
+    }
+
+    renderFavoritesBar() {
+        const bar = document.getElementById('favorites-bar');
+        if (!bar) return;
+
+        bar.innerHTML = '';
+        this.pinned.forEach(bookmark => {
+            bar.appendChild(this.createBookmarkElement(bookmark));
+        });
+        bar.hidden = this.pinned.length === 0;
+    }
+
     async saveSettings() {
+        this.settingsSavedAt = Date.now();
         try {
-            const response = await fetch('/api/settings', {
+            const response = await fetch('api/settings', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
@@ -247,6 +366,16 @@ class Dashboard {
         document.title = title;
     }
 
+    updateFavicon() {
+        // The page favicon URL falls back to the global favicon on the server,
+        // in the size of each sized icon link
+        if (!this.currentPageId) return;
+        document.querySelectorAll('link[rel="icon"]').forEach(link => {
+            const size = parseInt(link.getAttribute('sizes'), 10);
+            link.href = `api/pages/${this.currentPageId}/favicon` + (size ? `?size=${size}` : '');
+        });
+    }
+
     renderPageNavigation() {
         const container = document.getElementById('page-navigation');
         if (!container) return;
@@ -281,10 +410,16 @@ class Dashboard {
         // Control date visibility and set up if visible
         this.updateDateVisibility();
 
-        // Apply theme - use classList to preserve other classes
+        // Apply theme - use classList to preserve other classes. A random theme is
+        // picked by the server on every request, so keep the one the page was
+        // rendered with
+        let theme = this.settings.theme;
+        if (this.settings.randomThemeOnLoad) {
+            theme = document.documentElement.getAttribute('data-theme') || this.settings.effectiveTheme || theme;
+        }
         document.body.classList.remove('dark', 'light');
-        document.body.classList.add(this.settings.theme);
-        document.body.setAttribute('data-theme', this.settings.theme);
+        document.body.classList.add(theme);
+        document.body.setAttribute('data-theme', theme);
         document.body.setAttribute('data-show-title', this.settings.showTitle);
         document.body.setAttribute('data-show-date', this.settings.showDate);
         document.body.setAttribute('data-show-config-button', this.settings.showConfigButton);
@@ -314,9 +449,35 @@ class Dashboard {
         this.updatePageTabsVisibility();
 
         // Apply columns setting
+        this.applyColumns();
+        if (!this.columnsResizeListener) {
+            this.columnsResizeListener = () => this.applyColumns();
+            window.addEventListener('resize', this.columnsResizeListener);
+        }
+    }
+
+    applyColumns() {
         const grid = document.getElementById('dashboard-layout');
-        if (grid) {
-            grid.className = `dashboard-grid columns-${this.settings.columnsPerRow}`;
+        if (!grid) return;
+
+        grid.className = `dashboard-grid columns-${this.settings.columnsPerRow}`;
+
+        // Mobile and wide column counts override the responsive stylesheet, which
+        // uses !important for narrow screens
+        const width = window.innerWidth;
+        const mobileBreakpoint = this.settings.mobileBreakpoint || 767;
+        const wideBreakpoint = this.settings.wideBreakpoint || 1920;
+        let columns = 0;
+        if (this.settings.columnsPerRowMobile && width <= mobileBreakpoint) {
+            columns = this.settings.columnsPerRowMobile;
+        } else if (this.settings.columnsPerRowWide && width >= wideBreakpoint) {
+            columns = this.settings.columnsPerRowWide;
+        }
+
+        if (columns) {
+            grid.style.setProperty('grid-template-columns', `repeat(${columns}, 1fr)`, 'important');
+        } else {
+            grid.style.removeProperty('grid-template-columns');
         }
     }
 
@@ -539,7 +700,10 @@ class Dashboard {
     createCategoryElement(category, bookmarks) {
         const categoryDiv = document.createElement('div');
         categoryDiv.className = 'category';
-        const isCollapsed = this.settings.alwaysCollapseCategories ? true : (this.collapsedCategories[category.id] || false);
+        // The collapsed state is kept on the server, or in this browser when the
+        // server is read-only
+        const storedCollapsed = this.settings.readOnly ? this.collapsedCategories[category.id] : category.collapsed;
+        const isCollapsed = this.settings.alwaysCollapseCategories ? true : (storedCollapsed || false);
         categoryDiv.setAttribute('data-collapsed', isCollapsed ? 'true' : 'false');
 
         // Category title
@@ -549,8 +713,17 @@ class Dashboard {
         titleElement.addEventListener('click', () => {
             const isCollapsed = categoryDiv.getAttribute('data-collapsed') === 'true';
             categoryDiv.setAttribute('data-collapsed', isCollapsed ? 'false' : 'true');
-            this.collapsedCategories[category.id] = !isCollapsed;
-            this.saveCollapsedStates();
+            category.collapsed = !isCollapsed;
+            if (this.settings.readOnly) {
+                this.collapsedCategories[category.id] = !isCollapsed;
+                this.saveCollapsedStates();
+                return;
+            }
+            fetch(`api/categories/collapse?page=${this.currentPageId}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ id: category.id, collapsed: !isCollapsed })
+            }).catch(error => console.error('Error saving collapsed state:', error));
         });
         categoryDiv.appendChild(titleElement);
 
@@ -576,9 +749,14 @@ class Dashboard {
         // Add icon if exists and showIcons is enabled
         if (bookmark.icon && this.settings.showIcons) {
             const iconImg = document.createElement('img');
-            iconImg.src = `/data/icons/${bookmark.icon}`;
+            iconImg.src = `data/icons/${bookmark.icon}`;
             iconImg.className = 'bookmark-icon';
             iconImg.alt = '';
+            // Fall back to the bookmark's initials if the icon is missing
+            iconImg.onerror = () => {
+                iconImg.onerror = null;
+                iconImg.src = `api/icon/letter?name=${encodeURIComponent(bookmark.name)}`;
+            };
             link.appendChild(iconImg);
         }
         
@@ -600,7 +778,9 @@ class Dashboard {
         });
         
         // Set target for new tab if openInNewTab is enabled and HyprMode is not
-        if (this.settings.openInNewTab) {
+        // A per-bookmark openInNewTab overrides the global setting
+        const openInNewTab = typeof bookmark.openInNewTab === 'boolean' ? bookmark.openInNewTab : this.settings.openInNewTab;
+        if (openInNewTab) {
             link.target = '_blank';
             link.rel = 'noopener noreferrer';
         }
@@ -675,7 +855,7 @@ class Dashboard {
             if (!configLink) {
                 configLink = document.createElement('div');
                 configLink.className = 'config-link';
-                configLink.innerHTML = `<a href="/config">${this.language.t('dashboard.config')}</a>`;
+                configLink.innerHTML = `<a href="config">${this.language.t('dashboard.config')}</a>`;
 
                 // Add to header at the end (use safe header container)
                 const header = this.getHeaderContainer();
@@ -100,13 +100,13 @@ class SearchCommandColumns {
         } else {
             // For server settings, we need to fetch current settings, update columnsPerRow, and save back
             try {
-                const response = await fetch('/api/settings');
+                const response = await fetch('api/settings');
                 if (response.ok) {
                     const currentSettings = await response.json();
                     currentSettings.columnsPerRow = parseInt(columns);
 
                     // Save updated settings to server
-                    await fetch('/api/settings', {
+                    await fetch('api/settings', {
                         method: 'POST',
                         headers: { 'Content-Type': 'application/json' },
                         body: JSON.stringify(currentSettings)
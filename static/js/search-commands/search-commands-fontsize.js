@@ -96,13 +96,13 @@ class SearchCommandFontSize {
         } else {
             // For server settings, we need to fetch current settings, update fontSize, and save back
             try {
-                const response = await fetch('/api/settings');
+                const response = await fetch('api/settings');
                 if (response.ok) {
                     const currentSettings = await response.json();
                     currentSettings.fontSize = fontSize;
 
                     // Save updated settings to server
-                    await fetch('/api/settings', {
+                    await fetch('api/settings', {
                         method: 'POST',
                         headers: { 'Content-Type': 'application/json' },
                         body: JSON.stringify(currentSettings)
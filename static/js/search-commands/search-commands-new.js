@@ -156,7 +156,7 @@ class SearchCommandNew {
 
     async updateCategoriesForPage(pageId) {
         try {
-            const response = await fetch(`/api/categories?page=${pageId}`);
+            const response = await fetch(`api/categories?page=${pageId}`);
             if (response.ok) {
                 const categories = await response.json();
                 
@@ -366,7 +366,7 @@ class SearchCommandNew {
         const pageId = parseInt(formData.get('page'));
 
         try {
-            const response = await fetch('/api/bookmarks/add', {
+            const response = await fetch('api/bookmarks/add', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({
@@ -131,7 +131,7 @@ class SearchCommandRemove {
             // Get current page ID from dashboard
             const currentPageId = window.dashboardInstance ? window.dashboardInstance.currentPageId : 1;
 
-            const response = await fetch('/api/bookmarks', {
+            const response = await fetch('api/bookmarks', {
                 method: 'DELETE',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({
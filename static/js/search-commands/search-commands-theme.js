@@ -20,9 +20,11 @@ class SearchCommandTheme {
     async loadThemes() {
         try {
             // Load custom themes from API
-            const response = await fetch('/api/colors/custom-themes');
+            const response = await fetch('api/colors/custom-themes');
             if (response.ok) {
-                this.customThemes = await response.json();
+                // The API lists { id, name } in display order; keep it as an ordered id -> name map
+                const themes = await response.json();
+                this.customThemes = Object.fromEntries(themes.map(theme => [theme.id, theme.name]));
             }
         } catch (error) {
             console.error('Error loading custom themes:', error);
@@ -140,14 +142,14 @@ class SearchCommandTheme {
         } else {
             // For server settings, we need to fetch current settings, update theme, and save back
             try {
-                const response = await fetch('/api/settings');
+                const response = await fetch('api/settings');
                 if (response.ok) {
                     const currentSettings = await response.json();
                     currentSettings.theme = theme;
                     showBackgroundDots = currentSettings.showBackgroundDots !== false;
 
                     // Save updated settings to server
-                    await fetch('/api/settings', {
+                    await fetch('api/settings', {
                         method: 'POST',
                         headers: { 'Content-Type': 'application/json' },
                         body: JSON.stringify(currentSettings)
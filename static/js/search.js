@@ -352,7 +352,7 @@ class SearchComponent {
                     if ('config'.startsWith(query.toLowerCase())) {
                         this.searchMatches.push({ 
                             shortcut: 'config', 
-                            bookmark: { name: this.language ? this.language.t('dashboard.configuration') : 'Configuration', url: '/config' }, 
+                            bookmark: { name: this.language ? this.language.t('dashboard.configuration') : 'Configuration', url: 'config' }, 
                             type: 'config' 
                         });
                     }
@@ -361,7 +361,7 @@ class SearchComponent {
                     if ('colors'.startsWith(query.toLowerCase())) {
                         this.searchMatches.push({ 
                             shortcut: 'colors', 
-                            bookmark: { name: this.language ? this.language.t('dashboard.colorCustomization') : 'Color Customization', url: '/colors' }, 
+                            bookmark: { name: this.language ? this.language.t('dashboard.colorCustomization') : 'Color Customization', url: 'colors' }, 
                             type: 'colors' 
                         });
                     }
@@ -721,7 +721,9 @@ class SearchComponent {
                 const link = document.createElement('a');
                 link.href = bookmark.url;
                 link.style.display = 'none'; // Hide the link
-                if (this.settings.openInNewTab) {
+                // A per-bookmark openInNewTab overrides the global setting
+                const openInNewTab = typeof bookmark.openInNewTab === 'boolean' ? bookmark.openInNewTab : this.settings.openInNewTab;
+                if (openInNewTab) {
                     link.target = '_blank';
                     link.rel = 'noopener noreferrer';
                 } else {
@@ -742,7 +744,7 @@ class SearchComponent {
         
         // Navigate to config page
         setTimeout(() => {
-            window.location.href = '/config';
+            window.location.href = 'config';
         }, 100);
     }
 
@@ -754,7 +756,7 @@ class SearchComponent {
         
         // Navigate to colors page
         setTimeout(() => {
-            window.location.href = '/colors';
+            window.location.href = 'colors';
         }, 100);
     }
 
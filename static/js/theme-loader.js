@@ -338,21 +338,7 @@
         });
     }
     
-    // Apply custom font
-    document.addEventListener('DOMContentLoaded', function() {
-        const enableCustomFont = document.documentElement.getAttribute('data-enable-custom-font') === 'true';
-        const customFontPath = document.documentElement.getAttribute('data-custom-font-path');
-        if (enableCustomFont && customFontPath) {
-            const fontName = 'CustomFont';
-            const fontFace = new FontFace(fontName, `url(${customFontPath}?t=${Date.now()})`);
-            fontFace.load().then(function(loadedFace) {
-                document.fonts.add(loadedFace);
-                document.documentElement.style.setProperty('--font-family-main', `'${fontName}', monospace`);
-            }).catch(function(error) {
-                console.error('Error loading custom font:', error);
-            });
-        }
-    });
+    // The custom font is applied by /api/font.css
     
     // Export functions for use by other scripts (e.g., config.js)
     window.ThemeLoader = {
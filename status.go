@@ -2,155 +2,325 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
 )
 
+// findRegisteredBookmark returns the bookmark whose URL matches rawURL, tolerating
+// trivial differences such as a trailing slash or the case of the host
+func (h *Handlers) findRegisteredBookmark(rawURL string) (Bookmark, bool) {
+	for _, bookmark := range h.store.GetAllBookmarks() {
+		if !bookmark.Hidden && sameBookmarkURL(bookmark.URL, rawURL) {
+			return bookmark, true
+		}
+	}
+	return Bookmark{}, false
+}
+
+// pingError replies like writeJSONError, also reporting the URL as offline for
+// status monitors that only look at the status field
+func pingError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  apiError{Code: code, Message: message},
+		"status": "offline",
+		"ping":   nil,
+	})
+}
+
 // PingURL checks the status and response time of a bookmark URL
 func (h *Handlers) PingURL(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers first
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	h.setCORSHeaders(w, r)
 
 	// Get URL from query parameter
 	urlParam := r.URL.Query().Get("url")
 	if urlParam == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":  "URL parameter is required",
-			"status": "offline",
-			"ping":   nil,
-		})
+		pingError(w, http.StatusBadRequest, codeInvalidRequest, "URL parameter is required")
 		return
 	}
 
 	// Parse and validate URL
 	parsedURL, err := url.Parse(urlParam)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":  "Invalid URL",
-			"status": "offline",
-			"ping":   nil,
-		})
+		pingError(w, http.StatusBadRequest, codeInvalidURL, "Invalid URL")
 		return
 	}
 
 	// Validate that the URL belongs to a registered bookmark
-	allBookmarks := h.store.GetAllBookmarks()
-	isValidBookmark := false
-	for _, bookmark := range allBookmarks {
-		if bookmark.URL == urlParam {
-			isValidBookmark = true
-			break
-		}
-	}
+	bookmark, isValidBookmark := h.findRegisteredBookmark(urlParam)
 	if !isValidBookmark {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":  "URL is not a registered bookmark",
-			"status": "offline",
-			"ping":   nil,
-		})
+		pingError(w, http.StatusBadRequest, codeInvalidURL, "URL is not a registered bookmark")
 		return
 	}
 
-	// Extract host and port
-	host := parsedURL.Hostname()
-	port := parsedURL.Port()
-	if port == "" {
-		if parsedURL.Scheme == "https" {
-			port = "443"
-		} else {
-			port = "80"
+	// Get skipFastPing query parameter
+	skipFastPing := r.URL.Query().Get("skipFastPing") != ""
+
+	targetURL, err := h.pingTarget(bookmark, parsedURL)
+	if err != nil {
+		status, code := http.StatusBadRequest, codeInvalidURL
+		if err == errPingTargetDenied {
+			status, code = http.StatusForbidden, codeURLNotAllowed
 		}
+		pingError(w, status, code, err.Error())
+		return
 	}
 
-	// Get skipFastPing query parameter
-	skipFastPing := r.URL.Query().Get("skipFastPing")
+	result := h.pingBookmark(bookmark, targetURL, skipFastPing)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+var (
+	errPingSchemeUnsupported = errors.New("Only http and https URLs can be checked")
+	errPingTargetDenied      = errors.New("URL target is not allowed")
+)
+
+// pingTarget returns the URL to probe for a bookmark at bookmarkURL: its status
+// URL or its own health check URL when it has one. Custom-scheme bookmarks (ssh://, file://...)
+// can't be checked, and targets the server isn't allowed to connect to are refused.
+func (h *Handlers) pingTarget(bookmark Bookmark, bookmarkURL *url.URL) (*url.URL, error) {
+	targetURL := bookmarkURL
+	if usesJSONStatus(bookmark) {
+		statusURL, err := url.Parse(bookmark.StatusURL)
+		if err != nil {
+			return nil, err
+		}
+		targetURL = statusURL
+	} else if bookmark.CheckStatus && bookmark.HealthURL != "" {
+		healthURL, err := url.Parse(bookmark.HealthURL)
+		if err == nil {
+			targetURL = healthURL
+		}
+	}
+
+	if targetURL.Scheme != "http" && targetURL.Scheme != "https" {
+		return nil, errPingSchemeUnsupported
+	}
+	if err := h.pingPolicy.checkHost(targetURL.Hostname()); err != nil {
+		return nil, errPingTargetDenied
+	}
+	return targetURL, nil
+}
+
+// pingBookmark probes targetURL for bookmark, sharing the probe with concurrent
+// identical requests, and records the probe's result in the ping history once
+func (h *Handlers) pingBookmark(bookmark Bookmark, targetURL *url.URL, skipFastPing bool) pingResult {
+	settings := h.store.GetSettings()
+	mode := settings.PingMode
+	if usesJSONStatus(bookmark) {
+		// Bookmarks with a status URL take their status from another monitor
+		mode = "json"
+	}
+	options := pingOptions{
+		Mode:         mode,
+		StatusPath:   bookmark.StatusPath,
+		SkipFastPing: skipFastPing,
+		UserAgent:    settings.PingUserAgent,
+		Headers:      bookmark.PingHeaders,
+		Policy:       h.pingPolicy,
+	}
+	return h.pings.Do(pingKey(targetURL, options), func() pingResult {
+		result := h.probeURL(targetURL, options)
+		// Only the request that probed records and reports, so a burst of tabs counts as one check
+		if bookmark.CheckStatus {
+			h.pingHistory.Record(bookmark.URL, result)
+		}
+		if webhookURL := statusWebhookFor(bookmark, settings); bookmark.CheckStatus && webhookURL != "" {
+			h.notifier.Observe(bookmark, result, webhookURL)
+		}
+		return result
+	})
+}
+
+// pingResult is the JSON body returned by PingURL
+type pingResult struct {
+	Status           string     `json:"status"`                     // "online" or "offline"
+	Ping             *int64     `json:"ping"`                       // Response time in ms, null when offline
+	HTTPCode         int        `json:"httpCode,omitempty"`         // Status code when an HTTP request was made
+	TLSNotAfter      *time.Time `json:"tlsNotAfter,omitempty"`      // Expiry of the server certificate (https only)
+	TLSExpiresInDays *int       `json:"tlsExpiresInDays,omitempty"` // Days until the certificate expires, negative once expired
+	TLSValid         *bool      `json:"tlsValid,omitempty"`         // Whether the certificate chain verifies for the host
+}
+
+func onlineResult(start time.Time) pingResult {
+	elapsed := time.Since(start).Milliseconds()
+	// Ensure minimum of 1ms for display purposes
+	if elapsed < 1 {
+		elapsed = 1
+	}
+	return pingResult{Status: "online", Ping: &elapsed}
+}
+
+func offlineResult() pingResult {
+	return pingResult{Status: "offline"}
+}
 
-	// Start timing
+// withResponse adds the HTTP status code and, for https, the certificate details
+// of resp to the result
+func withResponse(result pingResult, resp *http.Response) pingResult {
+	if resp == nil {
+		return result
+	}
+
+	result.HTTPCode = resp.StatusCode
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		notAfter := resp.TLS.PeerCertificates[0].NotAfter
+		days := int(math.Floor(time.Until(notAfter).Hours() / 24))
+		valid := verifyCertificateChain(resp.TLS, resp.Request.URL.Hostname())
+		result.TLSNotAfter = &notAfter
+		result.TLSExpiresInDays = &days
+		result.TLSValid = &valid
+	}
+
+	return result
+}
+
+// verifyCertificateChain verifies the peer certificates of a connection that was
+// made with InsecureSkipVerify against the system roots and the given host name
+func verifyCertificateChain(state *tls.ConnectionState, host string) bool {
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+	})
+	return err == nil
+}
+
+// pingOptions controls how probeURL checks a URL
+type pingOptions struct {
+	Mode         string            // "tcp", "head", "get" or "json"
+	StatusPath   string            // Where "json" mode reads the status, see parseStatusPath
+	SkipFastPing bool              // Skip the TCP connect in "tcp" mode
+	UserAgent    string            // User-Agent for HTTP requests, empty for the default
+	Headers      map[string]string // Extra headers for HTTP requests
+	Policy       targetPolicy      // Which addresses may be connected to
+}
+
+// probeURL checks targetURL using the given ping mode:
+//   - "tcp" (default): TCP connect to host:port, falling back to an HTTP GET
+//     that treats any non-5xx response as online
+//   - "head": HTTP HEAD to the full URL, retried as GET when HEAD isn't allowed;
+//     only 2xx/3xx responses count as online
+//   - "get": HTTP GET to the full URL; only 2xx/3xx responses count as online
+//   - "json": HTTP GET to a status endpoint whose JSON response tells whether the
+//     service is up, see jsonStatusUp
+func (h *Handlers) probeURL(targetURL *url.URL, opts pingOptions) pingResult {
+	h.pingLimit.Acquire()
+	defer h.pingLimit.Release()
+
+	// Start timing, after waiting for a slot so the wait doesn't count as latency
 	start := time.Now()
 
-	if skipFastPing == "" {
+	switch opts.Mode {
+	case "head":
+		resp, err := doPingRequest("HEAD", targetURL.String(), opts)
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp, err = doPingRequest("GET", targetURL.String(), opts)
+		}
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 400 {
+			return withResponse(onlineResult(start), resp)
+		}
+		return withResponse(offlineResult(), resp)
+	case "get":
+		resp, err := doPingRequest("GET", targetURL.String(), opts)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 400 {
+			return withResponse(onlineResult(start), resp)
+		}
+		return withResponse(offlineResult(), resp)
+	case "json":
+		return probeJSONStatus(targetURL.String(), opts, start)
+	}
+
+	if !opts.SkipFastPing {
+		// Extract host and port
+		host := targetURL.Hostname()
+		port := targetURL.Port()
+		if port == "" {
+			if targetURL.Scheme == "https" {
+				port = "443"
+			} else {
+				port = "80"
+			}
+		}
+
 		// Try TCP connection first (fast ping)
 		address := net.JoinHostPort(host, port)
-		conn, err := net.DialTimeout("tcp", address, 2*time.Second)
-
+		conn, err := opts.Policy.dialer(2*time.Second).Dial("tcp", address)
 		if err == nil {
 			conn.Close()
-			elapsed := time.Since(start).Milliseconds()
-			// Ensure minimum of 1ms for display purposes
-			if elapsed < 1 {
-				elapsed = 1
-			}
-
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"status": "online",
-				"ping":   elapsed,
-			})
-			return
+			return onlineResult(start)
 		}
 	}
 
 	// If TCP fails (or fast ping disabled), try a quick HTTP request as fallback
+	resp, err := doPingRequest("GET", targetURL.String(), opts)
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 500 {
+		return withResponse(onlineResult(start), resp)
+	}
+
+	// Offline
+	return withResponse(offlineResult(), resp)
+}
+
+// doPingRequest performs a single HTTP request with the short ping timeouts.
+// The response body is closed before returning; only the status is of interest.
+func doPingRequest(method, targetURL string, opts pingOptions) (*http.Response, error) {
+	resp, err := pingRequest(method, targetURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// pingRequest performs a single HTTP request with the short ping timeouts, leaving
+// the response body for the caller to close. Header values may carry credentials
+// and must never be logged.
+func pingRequest(method, targetURL string, opts pingOptions) (*http.Response, error) {
 	client := &http.Client{
 		Timeout: 3 * time.Second,
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: true,
 			},
-			DialContext: (&net.Dialer{
-				Timeout: 2 * time.Second,
-			}).DialContext,
+			DialContext:           opts.Policy.dialer(2 * time.Second).DialContext,
 			TLSHandshakeTimeout:   2 * time.Second,
 			ResponseHeaderTimeout: 2 * time.Second,
 		},
 	}
 
-	req, err := http.NewRequest("GET", urlParam, nil)
+	req, err := http.NewRequest(method, targetURL, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status": "offline",
-			"ping":   nil,
-		})
-		return
+		return nil, err
 	}
 
 	// Add User-Agent header to avoid being blocked by some servers
-	req.Header.Set("User-Agent", "ThinkDashboard-Ping/1.0")
-
-	resp, err := client.Do(req)
-	if resp != nil {
-		defer resp.Body.Close()
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = "ThinkDashboard-Ping/1.0"
 	}
+	req.Header.Set("User-Agent", userAgent)
 
-	elapsed := time.Since(start).Milliseconds()
-	// Ensure minimum of 1ms for display purposes
-	if elapsed < 1 {
-		elapsed = 1
-	}
-
-	if err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 500 {
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status": "online",
-			"ping":   elapsed,
-		})
-		return
+	// Per-bookmark headers, e.g. for services behind header-based auth
+	for name, value := range opts.Headers {
+		req.Header.Set(name, value)
 	}
 
-	// Offline
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "offline",
-		"ping":   nil,
-	})
+	return client.Do(req)
 }
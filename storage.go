@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// storageKinds are the groups GET /api/storage breaks the data directory into
+var storageKinds = []string{"bookmarks", "settings", "database", "icons", "fonts", "backups", "logs", "other"}
+
+// storageUsage is the size and number of files of one group
+type storageUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+}
+
+// resetBackupPrefix is resetBackupDir relative to the data directory, where the
+// reset backups and the device settings archived with them are kept
+var resetBackupPrefix = func() string {
+	relPath, _ := filepath.Rel("data", resetBackupDir)
+	return filepath.ToSlash(relPath) + "/"
+}()
+
+// storageKind classifies a file in the data directory by what it holds
+func storageKind(relPath string) string {
+	relPath = filepath.ToSlash(relPath)
+	name := filepath.Base(relPath)
+	switch {
+	case strings.Contains(name, ".corrupt-"), strings.HasPrefix(relPath, resetBackupPrefix):
+		return "backups"
+	case strings.HasPrefix(relPath, "icons/"), strings.HasPrefix(relPath, faviconVariantDir+"/"), strings.HasPrefix(name, "favicon."), strings.HasPrefix(name, "favicon-page-"):
+		return "icons"
+	case strings.HasPrefix(relPath, "fonts/"), relPath == "fonts.json":
+		return "fonts"
+	case relPath == "pages.json":
+		return "bookmarks"
+	case relPath == "settings.json", relPath == "colors.json", relPath == "finders.json":
+		return "settings"
+	case strings.HasSuffix(name, ".db"), strings.HasSuffix(name, ".db-wal"), strings.HasSuffix(name, ".db-shm"):
+		return "database"
+	case strings.HasPrefix(name, "audit.log"), name == "ping-history.json":
+		return "logs"
+	}
+	if _, ok := bookmarksFilePageID(relPath); ok {
+		return "bookmarks"
+	}
+	return "other"
+}
+
+// Storage reports how much space the data directory takes, in total and broken
+// down into bookmarks, settings, the SQLite database, icons, fonts, backups and
+// preserved copies of corrupt files, logs and anything else
+func (h *Handlers) Storage(w http.ResponseWriter, r *http.Request) {
+	dataDir := "data"
+	kinds := make(map[string]*storageUsage)
+	for _, kind := range storageKinds {
+		kinds[kind] = &storageUsage{}
+	}
+	total := storageUsage{}
+
+	err := filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		usage := kinds[storageKind(relPath)]
+		usage.Bytes += info.Size()
+		usage.Files++
+		total.Bytes += info.Size()
+		total.Files++
+		return nil
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to read data directory")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"totalBytes": total.Bytes,
+		"totalFiles": total.Files,
+		"kinds":      kinds,
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestStorageKind(t *testing.T) {
+	for relPath, want := range map[string]string{
+		"bookmarks-1.json":                    "bookmarks",
+		"pages.json":                          "bookmarks",
+		"settings.json":                       "settings",
+		"icons/github.png":                    "icons",
+		"fonts/0123456789abcdef.woff2":        "fonts",
+		"thinkdashboard.db":                   "database",
+		"audit.log.1":                         "logs",
+		"colors.json.corrupt-20260101-120000": "backups",
+		"backups/reset-20260101-120000.zip":   "backups",
+		"backups/reset-20260101-120000-devices/0123456789abcdef0123456789abcdef/settings.json": "backups",
+		"notes.txt": "other",
+	} {
+		if got := storageKind(relPath); got != want {
+			t.Errorf("storageKind(%q) = %q, want %q", relPath, got, want)
+		}
+	}
+}
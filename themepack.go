@@ -0,0 +1,430 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// themePackMaxSize caps the size of an uploaded theme pack
+const themePackMaxSize = 10 << 20 // 10MB
+
+// themePackManifest describes a theme pack, stored in it as manifest.json
+type themePackManifest struct {
+	Kind       string `json:"kind"`       // Always "themepack", to tell it apart from backups
+	Version    string `json:"version"`    // App version that created the pack
+	ExportedAt string `json:"exportedAt"` // RFC 3339 timestamp
+}
+
+// themePackSettings is the subset of Settings that makes up the look of the
+// dashboard, stored in a theme pack as settings.json
+type themePackSettings struct {
+	Theme               string `json:"theme"`
+	FontSize            string `json:"fontSize"`
+	ColumnsPerRow       int    `json:"columnsPerRow"`
+	ColumnsPerRowMobile int    `json:"columnsPerRowMobile"`
+	ColumnsPerRowWide   int    `json:"columnsPerRowWide"`
+	MobileBreakpoint    int    `json:"mobileBreakpoint"`
+	WideBreakpoint      int    `json:"wideBreakpoint"`
+	ShowBackgroundDots  bool   `json:"showBackgroundDots"`
+	ShowTitle           bool   `json:"showTitle"`
+	ShowDate            bool   `json:"showDate"`
+	ShowIcons           bool   `json:"showIcons"`
+	AnimationsEnabled   bool   `json:"animationsEnabled"`
+	EnableCustomTitle   bool   `json:"enableCustomTitle"`
+	CustomTitle         string `json:"customTitle"`
+	EnableCustomFavicon bool   `json:"enableCustomFavicon"`
+	EnableCustomFont    bool   `json:"enableCustomFont"`
+	CustomFontPath      string `json:"customFontPath"`
+	HeadingFont         string `json:"headingFont"`
+	BodyFont            string `json:"bodyFont"`
+	MonoFont            string `json:"monoFont"`
+}
+
+func themePackSettingsOf(settings Settings) themePackSettings {
+	return themePackSettings{
+		Theme:               settings.Theme,
+		FontSize:            settings.FontSize,
+		ColumnsPerRow:       settings.ColumnsPerRow,
+		ColumnsPerRowMobile: settings.ColumnsPerRowMobile,
+		ColumnsPerRowWide:   settings.ColumnsPerRowWide,
+		MobileBreakpoint:    settings.MobileBreakpoint,
+		WideBreakpoint:      settings.WideBreakpoint,
+		ShowBackgroundDots:  settings.ShowBackgroundDots,
+		ShowTitle:           settings.ShowTitle,
+		ShowDate:            settings.ShowDate,
+		ShowIcons:           settings.ShowIcons,
+		AnimationsEnabled:   settings.AnimationsEnabled,
+		EnableCustomTitle:   settings.EnableCustomTitle,
+		CustomTitle:         settings.CustomTitle,
+		EnableCustomFavicon: settings.EnableCustomFavicon,
+		EnableCustomFont:    settings.EnableCustomFont,
+		CustomFontPath:      settings.CustomFontPath,
+		HeadingFont:         settings.HeadingFont,
+		BodyFont:            settings.BodyFont,
+		MonoFont:            settings.MonoFont,
+	}
+}
+
+// applyTo copies the theme pack settings over settings, leaving the rest alone
+func (t themePackSettings) applyTo(settings *Settings) {
+	settings.Theme = t.Theme
+	settings.FontSize = t.FontSize
+	settings.ColumnsPerRow = t.ColumnsPerRow
+	settings.ColumnsPerRowMobile = t.ColumnsPerRowMobile
+	settings.ColumnsPerRowWide = t.ColumnsPerRowWide
+	settings.MobileBreakpoint = t.MobileBreakpoint
+	settings.WideBreakpoint = t.WideBreakpoint
+	settings.ShowBackgroundDots = t.ShowBackgroundDots
+	settings.ShowTitle = t.ShowTitle
+	settings.ShowDate = t.ShowDate
+	settings.ShowIcons = t.ShowIcons
+	settings.AnimationsEnabled = t.AnimationsEnabled
+	settings.EnableCustomTitle = t.EnableCustomTitle
+	settings.CustomTitle = t.CustomTitle
+	settings.EnableCustomFavicon = t.EnableCustomFavicon
+	settings.EnableCustomFont = t.EnableCustomFont
+	settings.CustomFontPath = t.CustomFontPath
+	settings.HeadingFont = t.HeadingFont
+	settings.BodyFont = t.BodyFont
+	settings.MonoFont = t.MonoFont
+}
+
+// faviconExtensions are the favicon types the favicon uploads accept, with the content
+// type http.DetectContentType reports for each
+var faviconExtensions = map[string]string{
+	".ico": "image/x-icon",
+	".png": "image/png",
+	".jpg": "image/jpeg",
+	".gif": "image/gif",
+}
+
+// fontSignatures are the first bytes of each font format
+var fontSignatures = map[string][]string{
+	".woff2": {"wOF2"},
+	".woff":  {"wOFF"},
+	".ttf":   {"\x00\x01\x00\x00", "true"},
+	".otf":   {"OTTO", "\x00\x01\x00\x00"},
+}
+
+// isFontFile reports whether content starts like a font of the given extension
+func isFontFile(ext string, content []byte) bool {
+	for _, signature := range fontSignatures[ext] {
+		if bytes.HasPrefix(content, []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dataFileFromURL returns the path on disk of a /data/ URL such as
+// CustomFaviconPath, or "" for anything else
+func dataFileFromURL(urlPath string) string {
+	if !strings.HasPrefix(urlPath, "/data/") || strings.Contains(urlPath, "..") {
+		return ""
+	}
+	return filepath.Join("data", filepath.FromSlash(strings.TrimPrefix(urlPath, "/data/")))
+}
+
+// ExportThemePack downloads the colors, display settings, fonts and favicon as a
+// zip that another instance can apply with ImportThemePack. Bookmarks and pages
+// are never included.
+func (h *Handlers) ExportThemePack(w http.ResponseWriter, r *http.Request) {
+	settings := h.settingsFor(r)
+	colors := h.colorsFor(r)
+	colors.UpdatedAt = 0
+
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	add := func(name string, content []byte) error {
+		file, err := zipWriter.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(content)
+		return err
+	}
+	addJSON := func(name string, v interface{}) error {
+		content, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, content)
+	}
+
+	err := addJSON("manifest.json", themePackManifest{
+		Kind:       "themepack",
+		Version:    version,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err == nil {
+		err = addJSON("settings.json", themePackSettingsOf(settings))
+	}
+	if err == nil {
+		err = addJSON("colors.json", colors)
+	}
+
+	// The favicon and the fonts in use are copied from the data directory under
+	// the same names, with the library entries of the fonts so they keep their IDs
+	// and names. The legacy custom font is kept under its own name too.
+	packFiles := make(map[string]bool)
+	var fonts []customFont
+	for _, id := range []string{settings.HeadingFont, settings.BodyFont, settings.MonoFont} {
+		if font, ok := h.fonts.Get(id); ok && id != "" && !packFiles["fonts/"+path.Base(font.Path)] {
+			packFiles["fonts/"+path.Base(font.Path)] = true
+			fonts = append(fonts, font)
+		}
+	}
+	for _, urlPath := range []string{settings.CustomFontPath, settings.CustomFaviconPath} {
+		if dataFileFromURL(urlPath) != "" {
+			packFiles[strings.TrimPrefix(urlPath, "/data/")] = true
+		}
+	}
+	for _, font := range h.fonts.List() {
+		if font.Path == settings.CustomFontPath && !slices.Contains(fonts, font) {
+			fonts = append(fonts, font)
+		}
+	}
+	if err == nil {
+		_, err = h.addBackupFiles(zipWriter, backupChecksums{}, "data", func(relPath string) bool {
+			return packFiles[filepath.ToSlash(relPath)]
+		})
+	}
+	if err == nil && len(fonts) > 0 {
+		err = addJSON("fonts.json", fonts)
+	}
+
+	if err == nil {
+		err = zipWriter.Close()
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to create theme pack")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=thinkdashboard-themepack.zip")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// readThemePack reads the files of a theme pack zip, rejecting anything a pack
+// doesn't contain
+func readThemePack(content []byte) (map[string][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("not a zip file")
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		name := file.Name
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		valid := false
+		switch {
+		case name == "manifest.json" || name == "settings.json" || name == "colors.json" || name == "fonts.json":
+			valid = true
+		case strings.HasPrefix(name, "favicon."):
+			_, valid = faviconExtensions[path.Ext(name)]
+		case strings.HasPrefix(name, "font.") || (strings.HasPrefix(name, "fonts/") && strings.Count(name, "/") == 1):
+			_, valid = fontFormats[path.Ext(name)]
+		}
+		if !valid || strings.Contains(name, "..") {
+			return nil, fmt.Errorf("unexpected file %s", name)
+		}
+
+		opened, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("can't read %s", name)
+		}
+		data, err := io.ReadAll(io.LimitReader(opened, themePackMaxSize+1))
+		opened.Close()
+		if err != nil || len(data) > themePackMaxSize {
+			return nil, fmt.Errorf("can't read %s", name)
+		}
+		if _, isFont := fontFormats[path.Ext(name)]; isFont && !isFontFile(path.Ext(name), data) {
+			return nil, fmt.Errorf("%s is not a font file", name)
+		}
+		files[name] = data
+	}
+
+	var manifest themePackManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil || manifest.Kind != "themepack" {
+		return nil, fmt.Errorf("missing theme pack manifest")
+	}
+	return files, nil
+}
+
+// ImportThemePack applies a theme pack made by ExportThemePack. The zip is sent as
+// the request body or as the "file" field of a multipart form. Every piece is
+// checked before anything is written; settings outside the pack and bookmarks
+// are left alone, and custom themes are added to the existing ones.
+func (h *Handlers) ImportThemePack(w http.ResponseWriter, r *http.Request) {
+	body, err := importBody(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "No theme pack provided")
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(body, themePackMaxSize+1))
+	if err != nil || len(content) > themePackMaxSize {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "Theme pack is too large")
+		return
+	}
+
+	files, err := readThemePack(content)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: %v", err))
+		return
+	}
+
+	settings := h.settingsFor(r)
+	if data, ok := files["settings.json"]; ok {
+		pack := themePackSettingsOf(settings)
+		if err := json.Unmarshal(data, &pack); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid theme pack: settings.json is not valid")
+			return
+		}
+		pack.applyTo(&settings)
+		if err := validateDisplaySettings(&settings); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: %v", err))
+			return
+		}
+	}
+
+	var colors *ColorTheme
+	if data, ok := files["colors.json"]; ok {
+		var packColors ColorTheme
+		if err := json.Unmarshal(data, &packColors); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid theme pack: colors.json is not valid")
+			return
+		}
+		for key, theme := range packColors.Custom {
+			if !customThemeIDPattern.MatchString(key) || key == "light" || key == "dark" {
+				writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: %q is not a valid theme ID", key))
+				return
+			}
+			if err := validateThemeColors(theme); err != nil {
+				writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: theme %s: %v", key, err))
+				return
+			}
+		}
+		for name, theme := range map[string]ThemeColors{"light": packColors.Light, "dark": packColors.Dark} {
+			if err := validateThemeColors(theme); err != nil {
+				writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: theme %s: %v", name, err))
+				return
+			}
+		}
+		current := h.colorsFor(r)
+		current.Light = packColors.Light
+		current.Dark = packColors.Dark
+		if current.Custom == nil {
+			current.Custom = make(map[string]ThemeColors)
+		}
+		for key, theme := range packColors.Custom {
+			current.Custom[key] = theme
+		}
+		colors = &current
+	}
+
+	// The theme must exist once the pack's colors are in
+	if files["settings.json"] != nil {
+		themeColors := h.colorsFor(r)
+		if colors != nil {
+			themeColors = *colors
+		}
+		if err := validateTheme(settings.Theme, themeColors); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: %v", err))
+			return
+		}
+	}
+
+	var favicon, faviconExt string
+	for name := range files {
+		if ext := path.Ext(name); strings.HasPrefix(name, "favicon.") {
+			if http.DetectContentType(files[name]) != faviconExtensions[ext] {
+				writeJSONError(w, http.StatusBadRequest, codeInvalidFile, fmt.Sprintf("Invalid theme pack: %s is not a %s image", name, strings.TrimPrefix(ext, ".")))
+				return
+			}
+			favicon, faviconExt = name, ext
+		}
+	}
+
+	// Fonts are added to the library, which names them by content, so role
+	// settings still point at the same IDs
+	var packFonts []customFont
+	if data, ok := files["fonts.json"]; ok {
+		json.Unmarshal(data, &packFonts)
+	}
+	for name, data := range files {
+		if !strings.HasPrefix(name, "fonts/") {
+			continue
+		}
+		fontName := strings.TrimSuffix(path.Base(name), path.Ext(name))
+		for _, font := range packFonts {
+			if path.Base(font.Path) == path.Base(name) && font.Name != "" {
+				fontName = font.Name
+			}
+		}
+		if _, err := h.fonts.Add(fontName, path.Ext(name), data); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to save font")
+			return
+		}
+	}
+	for name, data := range files {
+		if !strings.HasPrefix(name, "font.") {
+			continue
+		}
+		font, err := h.fonts.Add("font", path.Ext(name), data)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to save font")
+			return
+		}
+		settings.CustomFontPath = font.Path
+	}
+	// Roles whose font didn't come along fall back to the main font
+	for _, role := range []*string{&settings.HeadingFont, &settings.BodyFont, &settings.MonoFont} {
+		if _, ok := h.fonts.Get(*role); !ok {
+			*role = ""
+		}
+	}
+	if settings.CustomFontPath != "" && !slices.ContainsFunc(h.fonts.List(), func(font customFont) bool { return font.Path == settings.CustomFontPath }) {
+		settings.CustomFontPath = ""
+	}
+
+	if favicon != "" {
+		if err := os.WriteFile(filepath.Join("data", "favicon"+faviconExt), files[favicon], 0644); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Failed to save favicon")
+			return
+		}
+		settings.CustomFaviconPath = "/data/favicon" + faviconExt
+	}
+
+	if err := h.saveSettingsFor(r, settings); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving settings")
+		return
+	}
+	h.events.Publish("settings", 0)
+	if colors != nil {
+		if err := h.saveColorsFor(r, *colors); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving colors")
+			return
+		}
+		h.events.Publish("colors", 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// customThemeIDPattern is what a custom theme ID may look like; it ends up in
+// the data-theme attribute and in theme.css
+var customThemeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validateTheme checks that theme is light, dark or one of the custom themes in colors
+func validateTheme(theme string, colors ColorTheme) error {
+	if theme == "light" || theme == "dark" {
+		return nil
+	}
+	if _, ok := colors.Custom[theme]; !ok {
+		return fmt.Errorf("unknown theme %q", theme)
+	}
+	return nil
+}
+
+// cssColorPattern matches the color values theme.css accepts: hex colors, rgb(),
+// rgba(), hsl() and hsla() with plain numbers, and named colors
+var cssColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|(rgb|rgba|hsl|hsla)\([0-9.,%/ ]+\)|[A-Za-z]+)$`)
+
+// validateThemeColors checks that every color of a theme is a plain CSS color, so
+// nothing else ends up in theme.css. Empty colors fall back to the defaults.
+func validateThemeColors(theme ThemeColors) error {
+	for _, color := range []struct{ name, value string }{
+		{"textPrimary", theme.TextPrimary},
+		{"textSecondary", theme.TextSecondary},
+		{"textTertiary", theme.TextTertiary},
+		{"backgroundPrimary", theme.BackgroundPrimary},
+		{"backgroundSecondary", theme.BackgroundSecondary},
+		{"backgroundDots", theme.BackgroundDots},
+		{"backgroundModal", theme.BackgroundModal},
+		{"borderPrimary", theme.BorderPrimary},
+		{"borderSecondary", theme.BorderSecondary},
+		{"accentSuccess", theme.AccentSuccess},
+		{"accentWarning", theme.AccentWarning},
+		{"accentError", theme.AccentError},
+	} {
+		if color.value != "" && !cssColorPattern.MatchString(color.value) {
+			return fmt.Errorf("%s is not a color: %q", color.name, color.value)
+		}
+	}
+	return nil
+}
+
+// customThemeSummary is one entry of GET /api/colors/custom-themes
+type customThemeSummary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// customThemeIDs returns the IDs of the custom themes in display order: those in
+// colors.Order first, then any others sorted by ID
+func customThemeIDs(colors ColorTheme) []string {
+	ids := make([]string, 0, len(colors.Custom))
+	listed := make(map[string]bool)
+	for _, id := range colors.Order {
+		if _, ok := colors.Custom[id]; ok && !listed[id] {
+			listed[id] = true
+			ids = append(ids, id)
+		}
+	}
+	var rest []string
+	for id := range colors.Custom {
+		if !listed[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	return append(ids, rest...)
+}
+
+// RenameCustomTheme changes the ID and/or name of a custom theme. A new ID keeps
+// the theme's place in the order and is followed by the theme setting if the
+// theme is the active one.
+func (h *Handlers) RenameCustomTheme(w http.ResponseWriter, r *http.Request) {
+	themeID := mux.Vars(r)["id"]
+	var req struct {
+		ID   string  `json:"id"`   // New ID, empty to keep it
+		Name *string `json:"name"` // New name, omitted to keep it
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+
+	colors := h.colorsFor(r)
+	theme, ok := colors.Custom[themeID]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, codeNotFound, "Custom theme not found")
+		return
+	}
+	newID := themeID
+	if req.ID != "" && req.ID != themeID {
+		newID = req.ID
+		if !customThemeIDPattern.MatchString(newID) || newID == "light" || newID == "dark" {
+			writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "Theme IDs are up to 64 letters, digits, - and _, other than light and dark")
+			return
+		}
+		if _, taken := colors.Custom[newID]; taken {
+			writeJSONError(w, http.StatusConflict, codeConflict, "A custom theme with that ID already exists")
+			return
+		}
+	}
+	if req.Name != nil {
+		theme.Name = *req.Name
+	}
+
+	colors.Order = customThemeIDs(colors)
+	for i, id := range colors.Order {
+		if id == themeID {
+			colors.Order[i] = newID
+		}
+	}
+	delete(colors.Custom, themeID)
+	colors.Custom[newID] = theme
+	if err := h.saveColorsFor(r, colors); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving colors")
+		return
+	}
+	h.events.Publish("colors", 0)
+
+	if settings := h.settingsFor(r); newID != themeID && settings.Theme == themeID {
+		settings.Theme = newID
+		if err := h.saveSettingsFor(r, settings); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving settings")
+			return
+		}
+		h.events.Publish("settings", 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customThemeSummary{ID: newID, Name: theme.Name})
+}
+
+// ReorderCustomThemes sets the display order of the custom themes to the order
+// of the theme IDs in the body, which must name every custom theme once
+func (h *Handlers) ReorderCustomThemes(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON")
+		return
+	}
+
+	colors := h.colorsFor(r)
+	seen := make(map[string]bool)
+	valid := true
+	for _, id := range ids {
+		if _, ok := colors.Custom[id]; !ok || seen[id] {
+			valid = false
+			break
+		}
+		seen[id] = true
+	}
+	if !valid || len(seen) != len(colors.Custom) {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidRequest, "The IDs must be those of the custom themes, each once")
+		return
+	}
+
+	colors.Order = ids
+	if err := h.saveColorsFor(r, colors); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Error saving colors")
+		return
+	}
+	h.events.Publish("colors", 0)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// trimTolerance is how far a channel may differ from the background color and
+// still count as margin, so JPEG noise around a solid border is trimmed too
+const trimTolerance = 0x0c00
+
+// maxTrimSide is the largest width or height of an image that gets trimmed, so a
+// small file can't expand into a huge image in memory
+const maxTrimSide = 4096
+
+// errNothingToTrim is returned when an image has no margins, or nothing but margin
+var errNothingToTrim = errors.New("nothing to trim")
+
+// isMargin reports whether c belongs to the border around an image whose corner
+// color is background. Any fully transparent pixel counts when the corner is
+// transparent.
+func isMargin(c, background color.Color) bool {
+	r1, g1, b1, a1 := c.RGBA()
+	r2, g2, b2, a2 := background.RGBA()
+	if a2 == 0 {
+		return a1 == 0
+	}
+	near := func(x, y uint32) bool {
+		if x > y {
+			return x-y <= trimTolerance
+		}
+		return y-x <= trimTolerance
+	}
+	return near(r1, r2) && near(g1, g2) && near(b1, b2) && near(a1, a2)
+}
+
+// contentBounds returns the smallest rectangle holding every pixel of img that
+// isn't margin
+func contentBounds(img image.Image) image.Rectangle {
+	bounds := img.Bounds()
+	background := img.At(bounds.Min.X, bounds.Min.Y)
+
+	content := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if isMargin(img.At(x, y), background) {
+				continue
+			}
+			content = content.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return content
+}
+
+// trimImage crops the uniform or transparent margins around a PNG, JPEG or GIF
+// and re-encodes it in its original format. Animated GIFs are left alone.
+func trimImage(content []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width > maxTrimSide || config.Height > maxTrimSide {
+		return nil, fmt.Errorf("images larger than %dx%d can't be trimmed", maxTrimSide, maxTrimSide)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if format == "gif" {
+		animation, err := gif.DecodeAll(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		if len(animation.Image) > 1 {
+			return nil, errors.New("animated GIFs can't be trimmed")
+		}
+	}
+
+	crop := contentBounds(img)
+	if crop.Empty() || crop == img.Bounds() {
+		return nil, errNothingToTrim
+	}
+
+	trimmed := image.NewNRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(trimmed, trimmed.Bounds(), img, crop.Min, draw.Src)
+
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, trimmed)
+	case "jpeg":
+		err = jpeg.Encode(&buf, trimmed, &jpeg.Options{Quality: 90})
+	case "gif":
+		err = gif.Encode(&buf, trimmed, nil)
+	default:
+		err = errors.New("unsupported image format " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latestReleaseURL is the GitHub API endpoint for the newest published release
+const latestReleaseURL = "https://api.github.com/repos/MatiasDesuu/ThinkDashboard/releases/latest"
+
+// updateChecker periodically looks up the latest release when UPDATE_CHECK=true.
+// It only reports whether a newer version exists and never updates anything.
+type updateChecker struct {
+	enabled   bool
+	mutex     sync.RWMutex
+	latest    string
+	checkedAt time.Time
+}
+
+func newUpdateChecker() *updateChecker {
+	checker := &updateChecker{
+		enabled: strings.ToLower(os.Getenv("UPDATE_CHECK")) == "true",
+	}
+	if checker.enabled {
+		go checker.run()
+	}
+	return checker
+}
+
+// run checks for updates once a day, retrying sooner after failures
+func (c *updateChecker) run() {
+	for {
+		interval := 24 * time.Hour
+		if err := c.check(); err != nil {
+			log.Printf("Update check failed: %v", err)
+			interval = time.Hour
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (c *updateChecker) check() error {
+	req, err := http.NewRequest("GET", latestReleaseURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "ThinkDashboard/"+version)
+
+	// The default transport honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.latest = release.TagName
+	c.checkedAt = time.Now()
+	c.mutex.Unlock()
+	return nil
+}
+
+// parseVersion parses "v1.2.3" style versions into their numeric parts
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	// Ignore pre-release and build suffixes
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+
+	var parts []int
+	for _, part := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// isNewerVersion reports whether latest is a higher version than current.
+// Versions that can't be parsed, such as development builds, never compare newer.
+func isNewerVersion(current, latest string) bool {
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	latestParts, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+
+	for i := 0; i < len(currentParts) || i < len(latestParts); i++ {
+		var c, l int
+		if i < len(currentParts) {
+			c = currentParts[i]
+		}
+		if i < len(latestParts) {
+			l = latestParts[i]
+		}
+		if l != c {
+			return l > c
+		}
+	}
+	return false
+}
+
+// Update reports whether a newer release is available
+func (h *Handlers) Update(w http.ResponseWriter, r *http.Request) {
+	h.updates.mutex.RLock()
+	latest, checkedAt := h.updates.latest, h.updates.checkedAt
+	h.updates.mutex.RUnlock()
+
+	response := map[string]interface{}{
+		"enabled":         h.updates.enabled,
+		"current":         version,
+		"latest":          latest,
+		"updateAvailable": isNewerVersion(version, latest),
+	}
+	if !checkedAt.IsZero() {
+		response["checkedAt"] = checkedAt.UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"encoding/json"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -14,21 +16,21 @@ func (h *Handlers) UploadFavicon(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form
 	err := r.ParseMultipartForm(10 << 20) // 10 MB max
 	if err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to parse form")
 		return
 	}
 
 	file, header, err := r.FormFile("favicon")
 	if err != nil {
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Error retrieving file")
 		return
 	}
 	defer file.Close()
 
 	// Validate file type (should be image)
-	contentType := header.Header.Get("Content-Type")
-	if contentType != "image/x-icon" && contentType != "image/png" && contentType != "image/jpeg" && contentType != "image/gif" {
-		http.Error(w, "Invalid file type. Only ico, png, jpg, gif allowed", http.StatusBadRequest)
+	ext, ok := faviconExtension(header.Header.Get("Content-Type"))
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid file type. Only ico, png, jpg, gif allowed")
 		return
 	}
 
@@ -38,40 +40,29 @@ func (h *Handlers) UploadFavicon(w http.ResponseWriter, r *http.Request) {
 		os.MkdirAll(dataDir, 0755)
 	}
 
-	// Determine file extension
-	var ext string
-	switch contentType {
-	case "image/x-icon":
-		ext = ".ico"
-	case "image/png":
-		ext = ".png"
-	case "image/jpeg":
-		ext = ".jpg"
-	case "image/gif":
-		ext = ".gif"
-	default:
-		ext = filepath.Ext(header.Filename)
-	}
-
 	// Save file as favicon with appropriate extension
 	faviconPath := filepath.Join(dataDir, "favicon"+ext)
 	dst, err := os.Create(faviconPath)
 	if err != nil {
-		http.Error(w, "Unable to save file", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to save file")
 		return
 	}
 	defer dst.Close()
 
 	_, err = io.Copy(dst, file)
 	if err != nil {
-		http.Error(w, "Unable to save file", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to save file")
 		return
 	}
+	if err := writeFaviconVariants(faviconPath); err != nil {
+		log.Printf("Warning: could not resize the favicon: %v", err)
+	}
 
 	// Update settings with the new favicon path
 	settings := h.store.GetSettings()
 	settings.CustomFaviconPath = "/data/favicon" + ext
 	h.store.SaveSettings(settings)
+	h.events.Publish("settings", 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "path": settings.CustomFaviconPath})
@@ -82,13 +73,13 @@ func (h *Handlers) UploadFont(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form
 	err := r.ParseMultipartForm(10 << 20) // 10 MB max
 	if err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to parse form")
 		return
 	}
 
 	file, header, err := r.FormFile("font")
 	if err != nil {
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Error retrieving file")
 		return
 	}
 	defer file.Close()
@@ -114,16 +105,10 @@ func (h *Handlers) UploadFont(w http.ResponseWriter, r *http.Request) {
 	isValidExt := ext == ".woff" || ext == ".woff2" || ext == ".ttf" || ext == ".otf"
 
 	if !isValidType && !isValidExt {
-		http.Error(w, "Invalid file type. Only woff, woff2, ttf, otf allowed", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid file type. Only woff, woff2, ttf, otf allowed")
 		return
 	}
 
-	// Create data directory if it doesn't exist
-	dataDir := "data"
-	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
-		os.MkdirAll(dataDir, 0755)
-	}
-
 	// Determine file extension
 	switch contentType {
 	case "font/woff", "application/font-woff":
@@ -141,28 +126,27 @@ func (h *Handlers) UploadFont(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Save file as font with appropriate extension
-	fontPath := filepath.Join(dataDir, "font"+ext)
-	dst, err := os.Create(fontPath)
+	content, err := io.ReadAll(file)
 	if err != nil {
-		http.Error(w, "Unable to save file", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to read file")
 		return
 	}
-	defer dst.Close()
 
-	_, err = io.Copy(dst, file)
+	// Fonts are kept side by side in data/fonts, named by their content
+	font, err := h.fonts.Add(strings.TrimSuffix(filename, filepath.Ext(filename)), ext, content)
 	if err != nil {
-		http.Error(w, "Unable to save file", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to save file")
 		return
 	}
 
-	// Update settings with the new font path
+	// The latest upload stays the custom font for settings that predate font roles
 	settings := h.store.GetSettings()
-	settings.CustomFontPath = "/data/font" + ext
+	settings.CustomFontPath = font.Path
 	h.store.SaveSettings(settings)
+	h.events.Publish("settings", 0)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "path": settings.CustomFontPath})
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "path": font.Path, "id": font.ID})
 }
 
 // UploadIcon handles bookmark icon file uploads
@@ -170,13 +154,13 @@ func (h *Handlers) UploadIcon(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form
 	err := r.ParseMultipartForm(10 << 20) // 10 MB max
 	if err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to parse form")
 		return
 	}
 
 	file, header, err := r.FormFile("icon")
 	if err != nil {
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Error retrieving file")
 		return
 	}
 	defer file.Close()
@@ -184,7 +168,7 @@ func (h *Handlers) UploadIcon(w http.ResponseWriter, r *http.Request) {
 	// Validate file type (should be image)
 	contentType := header.Header.Get("Content-Type")
 	if contentType != "image/x-icon" && contentType != "image/png" && contentType != "image/jpeg" && contentType != "image/gif" && contentType != "image/svg+xml" {
-		http.Error(w, "Invalid file type. Only ico, png, jpg, gif, svg allowed", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Invalid file type. Only ico, png, jpg, gif, svg allowed")
 		return
 	}
 
@@ -218,21 +202,31 @@ func (h *Handlers) UploadIcon(w http.ResponseWriter, r *http.Request) {
 	baseName = strings.ReplaceAll(baseName, "/", "")
 	baseName = strings.ReplaceAll(baseName, "\\", "")
 
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, codeInvalidFile, "Unable to read file")
+		return
+	}
+
+	// Optionally crop uniform or transparent margins from raster images. The
+	// trimmed copy gets its own name so it doesn't collide with an untrimmed upload,
+	// and the original is kept if trimming isn't possible.
+	if r.URL.Query().Get("trim") == "true" && (ext == ".png" || ext == ".jpg" || ext == ".gif") {
+		if trimmed, err := trimImage(content); err == nil {
+			content = trimmed
+			baseName += "-trimmed"
+		} else if err != errNothingToTrim {
+			log.Printf("Warning: could not trim icon %s: %v", header.Filename, err)
+		}
+	}
+
 	// Check if file already exists
 	fileName := baseName + ext
 	filePath := filepath.Join(iconsDir, fileName)
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		// File doesn't exist, save it
-		dst, err := os.Create(filePath)
-		if err != nil {
-			http.Error(w, "Unable to save file", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
-
-		_, err = io.Copy(dst, file)
-		if err != nil {
-			http.Error(w, "Unable to save file", http.StatusInternalServerError)
+		if err := os.WriteFile(filePath, content, 0644); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, codeInternalError, "Unable to save file")
 			return
 		}
 	}
@@ -241,3 +235,36 @@ func (h *Handlers) UploadIcon(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "icon": fileName})
 }
+
+// servedDataExtensions are the uploaded file types /data/ serves: icons, the
+// favicon and fonts. Data files such as settings.json and the audit log are not.
+var servedDataExtensions = map[string]bool{
+	".ico": true, ".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true,
+}
+
+// dataFileHandler serves uploads from dir, answering 404 for other files and
+// directory listings
+func dataFileHandler(dir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || !servedDataExtensions[strings.ToLower(path.Ext(r.URL.Path))] {
+			http.NotFound(w, r)
+			return
+		}
+		if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))); err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// Uploaded and cached icons may be SVGs from any bookmarked site
+		if strings.ToLower(path.Ext(r.URL.Path)) == ".svg" {
+			w.Header().Set("Content-Security-Policy", svgContentSecurityPolicy)
+		}
+		// Versioned URLs, such as the fonts in /api/font.css, never change
+		if r.URL.Query().Has("v") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
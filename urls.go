@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// normalizeBookmarkURL lowercases the scheme and host and strips default ports,
+// preserving the path, query and fragment. Trailing slashes are only removed when
+// stripTrailingSlash is set since some routes depend on them. URLs that can't be
+// parsed are returned unchanged.
+func normalizeBookmarkURL(rawURL string, stripTrailingSlash bool) string {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return rawURL
+	}
+
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+
+	host := strings.ToLower(parsedURL.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	port := parsedURL.Port()
+	if (parsedURL.Scheme == "http" && port == "80") || (parsedURL.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	parsedURL.Host = host
+
+	if stripTrailingSlash {
+		parsedURL.Path = strings.TrimRight(parsedURL.Path, "/")
+		parsedURL.RawPath = strings.TrimRight(parsedURL.RawPath, "/")
+	}
+
+	return parsedURL.String()
+}
+
+// dedupeBookmarks removes bookmarks whose URL already appeared earlier in the list,
+// keeping the first occurrence
+func dedupeBookmarks(bookmarks []Bookmark) []Bookmark {
+	seen := make(map[string]bool)
+	result := make([]Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		if bookmark.URL != "" && seen[bookmark.URL] {
+			continue
+		}
+		seen[bookmark.URL] = true
+		result = append(result, bookmark)
+	}
+	return result
+}
+
+// bookmarkURLKey returns a comparison key for rawURL that ignores differences in
+// scheme/host case, default ports, trailing slashes and fragments
+func bookmarkURLKey(rawURL string) string {
+	normalized := normalizeBookmarkURL(rawURL, true)
+	if i := strings.Index(normalized, "#"); i >= 0 {
+		normalized = normalized[:i]
+	}
+	return normalized
+}
+
+// sameBookmarkURL reports whether a and b refer to the same bookmark URL
+func sameBookmarkURL(a, b string) bool {
+	return a == b || bookmarkURLKey(a) == bookmarkURLKey(b)
+}
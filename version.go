@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.buildTime=2024-01-01T00:00:00Z"
+var (
+	version   = "dev"
+	commit    = ""
+	buildTime = ""
+)
+
+// buildInfo returns the commit and build time, falling back to the VCS information
+// recorded by the Go toolchain when they weren't set through ldflags
+func buildInfo() (string, string) {
+	revision, modified := commit, buildTime
+	if revision != "" && modified != "" {
+		return revision, modified
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if revision == "" {
+					revision = setting.Value
+				}
+			case "vcs.time":
+				if modified == "" {
+					modified = setting.Value
+				}
+			}
+		}
+	}
+	return revision, modified
+}
+
+// Version returns the running build's version information
+func (h *Handlers) Version(w http.ResponseWriter, r *http.Request) {
+	revision, builtAt := buildInfo()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"version":   version,
+		"commit":    revision,
+		"buildTime": builtAt,
+	})
+}
+
+// Capabilities tells clients which optional server features are active, so one
+// frontend can adapt to differently configured servers
+func (h *Handlers) Capabilities(w http.ResponseWriter, r *http.Request) {
+	storage := "file"
+	if _, ok := h.store.(*SQLiteStore); ok {
+		storage = "sqlite"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"readOnly":    h.readOnly,
+		"authEnabled": false, // No authentication support yet
+		"multiUser":   false,
+		"storage":     storage,
+		"version":     version,
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// statusWebhookConfirmations is how many checks in a row must agree before a
+// status change is reported, so a single failed check doesn't send an alert
+const statusWebhookConfirmations = 2
+
+// statusWebhookPayload is the JSON body posted to a status webhook. content and
+// text carry a readable message for Discord and Slack, ntfy shows the whole body.
+type statusWebhookPayload struct {
+	URL       string    `json:"url"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"` // "online" or "offline"
+	ChangedAt time.Time `json:"changedAt"`
+	Content   string    `json:"content"`
+	Text      string    `json:"text"`
+}
+
+// trackedStatus is the status of one bookmark URL as known to the notifier
+type trackedStatus struct {
+	reported string // Last confirmed status
+	pending  string // Differing status seen in the latest checks
+	count    int    // Checks in a row with the pending status
+	since    time.Time
+}
+
+// statusNotifier watches status check results and posts to a webhook when a
+// bookmark goes offline or comes back. The last known statuses are kept in memory
+// only, so the first check after a restart sets the baseline without a message.
+type statusNotifier struct {
+	mutex    sync.Mutex
+	statuses map[string]*trackedStatus // By bookmarkURLKey
+	policy   targetPolicy
+}
+
+func newStatusNotifier(policy targetPolicy) *statusNotifier {
+	return &statusNotifier{statuses: make(map[string]*trackedStatus), policy: policy}
+}
+
+// Observe records a check of bookmark and posts to webhookURL in the background
+// once a status change has been confirmed
+func (n *statusNotifier) Observe(bookmark Bookmark, result pingResult, webhookURL string) {
+	n.mutex.Lock()
+	key := bookmarkURLKey(bookmark.URL)
+	tracked, ok := n.statuses[key]
+	if !ok {
+		n.statuses[key] = &trackedStatus{reported: result.Status}
+		n.mutex.Unlock()
+		return
+	}
+
+	if result.Status == tracked.reported {
+		tracked.pending, tracked.count = "", 0
+		n.mutex.Unlock()
+		return
+	}
+	if result.Status != tracked.pending {
+		tracked.pending, tracked.count, tracked.since = result.Status, 0, time.Now().UTC()
+	}
+	tracked.count++
+	if tracked.count < statusWebhookConfirmations {
+		n.mutex.Unlock()
+		return
+	}
+	tracked.reported, tracked.pending, tracked.count = result.Status, "", 0
+	changedAt := tracked.since
+	n.mutex.Unlock()
+
+	name := bookmark.Name
+	if name == "" {
+		name = bookmark.URL
+	}
+	message := fmt.Sprintf("%s (%s) is %s", name, bookmark.URL, result.Status)
+	go n.send(webhookURL, statusWebhookPayload{
+		URL:       bookmark.URL,
+		Name:      bookmark.Name,
+		Status:    result.Status,
+		ChangedAt: changedAt,
+		Content:   message,
+		Text:      message,
+	})
+}
+
+// send posts payload to webhookURL. Failures are only logged, the next change
+// is reported as usual.
+func (n *statusNotifier) send(webhookURL string, payload statusWebhookPayload) {
+	body, _ := json.Marshal(payload)
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: n.policy.dialer(5 * time.Second).DialContext},
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err // Without the webhook URL, which often contains a token
+	}
+	if err != nil {
+		log.Printf("Warning: status webhook for %s failed: %v", payload.URL, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Warning: status webhook for %s answered %s", payload.URL, resp.Status)
+	}
+}
+
+// statusWebhookFor returns the webhook for a bookmark's status changes: its own,
+// or the one in settings
+func statusWebhookFor(bookmark Bookmark, settings Settings) string {
+	if bookmark.StatusWebhookURL != "" {
+		return bookmark.StatusWebhookURL
+	}
+	return settings.StatusWebhookURL
+}